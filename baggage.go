@@ -0,0 +1,285 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.elastic.co/apm/internal/wildcard"
+)
+
+const (
+	baggageHeader = "Baggage"
+
+	// baggageLabelPrefix is the prefix added to baggage keys
+	// when recording baggage members as labels.
+	baggageLabelPrefix = "baggage."
+
+	// maxBaggageMembers and maxBaggageBytes are the limits defined by
+	// the W3C Baggage specification. Members beyond these limits are
+	// not propagated.
+	maxBaggageMembers = 180
+	maxBaggageBytes   = 8192
+)
+
+// Baggage holds a set of key/value pairs which are propagated across
+// service boundaries alongside the trace context, as described by the
+// W3C Baggage specification: https://www.w3.org/TR/baggage/.
+//
+// Baggage is immutable: methods which modify baggage return a copy.
+// The zero value is empty baggage, ready to use.
+type Baggage struct {
+	members []BaggageMember
+}
+
+// BaggageMember holds a single baggage key/value pair.
+type BaggageMember struct {
+	// Key holds the baggage member's key, which must be a valid
+	// RFC 7230 token.
+	Key string
+
+	// Value holds the baggage member's value. Values are percent-encoded
+	// when propagated, so any string is valid.
+	Value string
+}
+
+// NewBaggage returns Baggage containing members. If multiple members
+// have the same key, the last one takes precedence.
+func NewBaggage(members ...BaggageMember) Baggage {
+	var b Baggage
+	for _, m := range members {
+		b = b.WithMember(m.Key, m.Value)
+	}
+	return b
+}
+
+// Len returns the number of members in b.
+func (b Baggage) Len() int {
+	return len(b.members)
+}
+
+// Members returns a copy of the members of b, in the order they were added.
+func (b Baggage) Members() []BaggageMember {
+	if len(b.members) == 0 {
+		return nil
+	}
+	return append([]BaggageMember(nil), b.members...)
+}
+
+// Value returns the value of the member with the given key, and a
+// boolean indicating whether or not the member exists.
+func (b Baggage) Value(key string) (string, bool) {
+	for _, m := range b.members {
+		if m.Key == key {
+			return m.Value, true
+		}
+	}
+	return "", false
+}
+
+// WithMember returns a copy of b with the member key set to value,
+// replacing any existing member with the same key.
+func (b Baggage) WithMember(key, value string) Baggage {
+	members := make([]BaggageMember, 0, len(b.members)+1)
+	for _, m := range b.members {
+		if m.Key != key {
+			members = append(members, m)
+		}
+	}
+	members = append(members, BaggageMember{Key: key, Value: value})
+	return Baggage{members: members}
+}
+
+// WithoutMember returns a copy of b without the member with the given key.
+func (b Baggage) WithoutMember(key string) Baggage {
+	if _, ok := b.Value(key); !ok {
+		return b
+	}
+	members := make([]BaggageMember, 0, len(b.members)-1)
+	for _, m := range b.members {
+		if m.Key != key {
+			members = append(members, m)
+		}
+	}
+	return Baggage{members: members}
+}
+
+// String returns b encoded as a W3C baggage header value.
+//
+// Members with invalid keys are omitted, as are any members that
+// would cause the W3C Baggage size limits to be exceeded.
+func (b Baggage) String() string {
+	var buf strings.Builder
+	var n int
+	for _, m := range b.members {
+		if n == maxBaggageMembers {
+			break
+		}
+		if m.Validate() != nil {
+			continue
+		}
+		member := m.Key + "=" + url.PathEscape(m.Value)
+		size := len(member)
+		if n > 0 {
+			size++
+		}
+		if buf.Len()+size > maxBaggageBytes {
+			continue
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(member)
+		n++
+	}
+	return buf.String()
+}
+
+// Validate validates the baggage member.
+//
+// This will return non-nil if the key is not a valid RFC 7230 token.
+func (m BaggageMember) Validate() error {
+	if !isBaggageKey(m.Key) {
+		return fmt.Errorf("invalid baggage key %q", m.Key)
+	}
+	return nil
+}
+
+// isBaggageKey reports whether k is a valid W3C Baggage key,
+// i.e. a non-empty RFC 7230 token.
+func isBaggageKey(k string) bool {
+	if k == "" {
+		return false
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseBaggageHeader parses W3C baggage header values, returning the
+// resulting Baggage. Invalid members, and any member properties, are
+// ignored.
+func parseBaggageHeader(values ...string) Baggage {
+	var members []BaggageMember
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			if i := strings.IndexByte(member, ';'); i >= 0 {
+				member = member[:i]
+			}
+			i := strings.IndexByte(member, '=')
+			if i < 0 {
+				continue
+			}
+			k := strings.TrimSpace(member[:i])
+			v, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+			if err != nil || !isBaggageKey(k) {
+				continue
+			}
+			members = append(members, BaggageMember{Key: k, Value: v})
+		}
+	}
+	return NewBaggage(members...)
+}
+
+// InjectBaggage injects b into carrier as a W3C baggage header.
+// If b has no valid members, InjectBaggage does nothing.
+func InjectBaggage(b Baggage, carrier PropagationCarrier) {
+	if value := b.String(); value != "" {
+		carrier.Set(baggageHeader, value)
+	}
+}
+
+// ExtractBaggage extracts Baggage from the W3C baggage header(s)
+// in carrier. Invalid baggage members are ignored.
+func ExtractBaggage(carrier PropagationCarrier) Baggage {
+	return parseBaggageHeader(carrier.Get(baggageHeader)...)
+}
+
+type baggageContextKey struct{}
+
+// ContextWithBaggage returns a copy of parent in which the given
+// baggage is stored. Baggage in the returned context replaces any
+// baggage in parent; use BaggageFromContext and Baggage.WithMember
+// to add members to existing baggage.
+//
+// Members of the baggage matching the tracer's "baggage to attach"
+// configuration are recorded as labels on transactions subsequently
+// added to the context with ContextWithTransaction, and on spans
+// started with StartSpan or StartSpanOptions.
+func ContextWithBaggage(parent context.Context, b Baggage) context.Context {
+	return context.WithValue(parent, baggageContextKey{}, b)
+}
+
+// BaggageFromContext returns the Baggage in context, if any.
+// If there is no baggage in the context, empty Baggage will
+// be returned.
+func BaggageFromContext(ctx context.Context) Baggage {
+	b, _ := ctx.Value(baggageContextKey{}).(Baggage)
+	return b
+}
+
+// attachBaggage records the members of b which match any of matchers
+// as labels in setLabel, prefixing the keys with "baggage.".
+func attachBaggage(b Baggage, matchers wildcard.Matchers, setLabel func(key string, value interface{})) {
+	if len(matchers) == 0 {
+		return
+	}
+	for _, m := range b.members {
+		if matchers.MatchAny(m.Key) {
+			setLabel(baggageLabelPrefix+m.Key, m.Value)
+		}
+	}
+}
+
+// attachBaggage records the members of b matching the tracer's
+// "baggage to attach" configuration as transaction labels.
+func (tx *Transaction) attachBaggage(b Baggage) {
+	if b.Len() == 0 {
+		return
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.ended() {
+		return
+	}
+	attachBaggage(b, tx.baggageToAttach, tx.Context.SetLabel)
+}
+
+// attachBaggage records the members of b matching the tracer's
+// "baggage to attach" configuration as span labels.
+func (s *Span) attachBaggage(b Baggage) {
+	if b.Len() == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended() {
+		return
+	}
+	attachBaggage(b, s.baggageToAttach, s.Context.SetLabel)
+}
@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/transport/transporttest"
+)
+
+func TestBaggage(t *testing.T) {
+	var b apm.Baggage
+	assert.Equal(t, 0, b.Len())
+	assert.Equal(t, "", b.String())
+
+	b = apm.NewBaggage(
+		apm.BaggageMember{Key: "tenant", Value: "acme"},
+		apm.BaggageMember{Key: "bucket", Value: "a"},
+	)
+	b2 := b.WithMember("bucket", "b c").WithMember("user", "x,y;z")
+	assert.Equal(t, "tenant=acme,bucket=a", b.String())
+	assert.Equal(t, "tenant=acme,bucket=b%20c,user=x%2Cy%3Bz", b2.String())
+
+	value, ok := b2.Value("bucket")
+	assert.True(t, ok)
+	assert.Equal(t, "b c", value)
+	_, ok = b2.Value("missing")
+	assert.False(t, ok)
+
+	b3 := b2.WithoutMember("tenant")
+	assert.Equal(t, []apm.BaggageMember{
+		{Key: "bucket", Value: "b c"},
+		{Key: "user", Value: "x,y;z"},
+	}, b3.Members())
+	assert.Equal(t, 3, b2.Len())
+
+	// Members with invalid keys are not propagated.
+	assert.Error(t, apm.BaggageMember{Key: "a b"}.Validate())
+	assert.Equal(t, "tenant=acme", apm.NewBaggage(
+		apm.BaggageMember{Key: "a b", Value: "c"},
+		apm.BaggageMember{Key: "tenant", Value: "acme"},
+	).String())
+}
+
+func TestBaggageSizeLimit(t *testing.T) {
+	var b apm.Baggage
+	b = b.WithMember("a", strings.Repeat("x", 8000))
+	b = b.WithMember("b", strings.Repeat("y", 200))
+	b = b.WithMember("c", "z")
+	assert.Equal(t, "a="+strings.Repeat("x", 8000)+",c=z", b.String())
+}
+
+func TestBaggageInjectExtract(t *testing.T) {
+	header := make(http.Header)
+	apm.InjectBaggage(apm.Baggage{}, apm.HTTPHeaderCarrier(header))
+	assert.Empty(t, header)
+
+	b := apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "a b"})
+	apm.InjectBaggage(b, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{"Baggage": {"tenant=a%20b"}}, header)
+	assert.Equal(t, b, apm.ExtractBaggage(apm.HTTPHeaderCarrier(header)))
+
+	header = http.Header{"Baggage": {
+		"tenant = acme ;prop=1, invalid, a b=c, bad=%zz",
+		"bucket=b,tenant=other",
+	}}
+	assert.Equal(t, apm.NewBaggage(
+		apm.BaggageMember{Key: "bucket", Value: "b"},
+		apm.BaggageMember{Key: "tenant", Value: "other"},
+	), apm.ExtractBaggage(apm.HTTPHeaderCarrier(header)))
+}
+
+func TestContextWithBaggage(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, apm.Baggage{}, apm.BaggageFromContext(ctx))
+
+	b := apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme"})
+	ctx = apm.ContextWithBaggage(ctx, b)
+	assert.Equal(t, b, apm.BaggageFromContext(ctx))
+}
+
+func TestBaggageToAttach(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	tracer.SetBaggageToAttach("tenant", "exp_*")
+
+	ctx := apm.ContextWithBaggage(context.Background(), apm.NewBaggage(
+		apm.BaggageMember{Key: "tenant", Value: "acme"},
+		apm.BaggageMember{Key: "exp_bucket", Value: "a"},
+		apm.BaggageMember{Key: "secret", Value: "hunter2"},
+	))
+	tx := tracer.StartTransaction("name", "type")
+	ctx = apm.ContextWithTransaction(ctx, tx)
+	span, _ := apm.StartSpan(ctx, "name", "type")
+	span.End()
+	tx.End()
+	tracer.Flush(nil)
+
+	expected := model.IfaceMap{
+		{Key: "baggage_exp_bucket", Value: "a"},
+		{Key: "baggage_tenant", Value: "acme"},
+	}
+	payloads := tracer.Payloads()
+	assert.Equal(t, expected, payloads.Transactions[0].Context.Tags)
+	assert.Equal(t, expected, payloads.Spans[0].Context.Tags)
+}
+
+func TestBaggageToAttachDefault(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	ctx := apm.ContextWithBaggage(context.Background(), apm.NewBaggage(
+		apm.BaggageMember{Key: "tenant", Value: "acme"},
+	))
+	tx := tracer.StartTransaction("name", "type")
+	apm.ContextWithTransaction(ctx, tx)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	assert.Nil(t, payloads.Transactions[0].Context)
+}
+
+func TestBaggageToAttachEnv(t *testing.T) {
+	os.Setenv("ELASTIC_APM_BAGGAGE_TO_ATTACH", "tenant")
+	defer os.Unsetenv("ELASTIC_APM_BAGGAGE_TO_ATTACH")
+
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	ctx := apm.ContextWithBaggage(context.Background(), apm.NewBaggage(
+		apm.BaggageMember{Key: "tenant", Value: "acme"},
+		apm.BaggageMember{Key: "bucket", Value: "a"},
+	))
+	tx := tracer.StartTransaction("name", "type")
+	apm.ContextWithTransaction(ctx, tx)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := transport.Payloads()
+	assert.Equal(t, model.IfaceMap{
+		{Key: "baggage_tenant", Value: "acme"},
+	}, payloads.Transactions[0].Context.Tags)
+}
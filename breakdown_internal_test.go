@@ -108,4 +108,15 @@ func testBreakdownMetricsAlignment(t *testing.T, arch string) {
 	for _, fieldIndex := range spanTimingFieldIndices {
 		assert.Equal(t, int64(0), offsets[fieldIndex]%8)
 	}
+
+	// transactionMetricsMapEntry's size must be a multiple of 8,
+	// and its counts field must be the first field, to ensure
+	// the counts are 64-bit aligned.
+	transactionMetricsMapEntryObj := pkg.Scope().Lookup("transactionMetricsMapEntry")
+	require.NotNil(t, transactionMetricsMapEntryObj)
+	assert.Equal(t, int64(0), cfg.Sizes.Sizeof(transactionMetricsMapEntryObj.Type())%8)
+	_, countsFieldIndex, _ := types.LookupFieldOrMethod(
+		transactionMetricsMapEntryObj.Type(), false, pkg, "counts",
+	)
+	assert.Equal(t, []int{0}, countsFieldIndex)
 }
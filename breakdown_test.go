@@ -21,6 +21,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -103,10 +104,10 @@ func TestBreakdownMetrics_MetricsLimit(t *testing.T) {
 	tracer.Flush(nil)
 	tracer.SendMetrics(nil)
 
-	// Make sure there's just one warning logged.
+	// Make sure there's just one breakdown warning logged.
 	var warnings []apmtest.LogRecord
 	for _, record := range logger.Records {
-		if record.Level == "warning" {
+		if record.Level == "warning" && strings.Contains(record.Message, "breakdown") {
 			warnings = append(warnings, record)
 		}
 	}
@@ -529,6 +530,10 @@ func payloadsBreakdownMetrics(t *transporttest.RecorderTransport) []model.Metric
 		if m.Transaction.Type == "" {
 			continue
 		}
+		if _, ok := m.Samples["transaction.duration.histogram"]; ok {
+			// Ignore transaction metrics.
+			continue
+		}
 		ms = append(ms, m)
 	}
 	return ms
@@ -23,22 +23,34 @@ import (
 
 	sysinfo "github.com/elastic/go-sysinfo"
 	"github.com/elastic/go-sysinfo/types"
+
+	"go.elastic.co/apm/internal/apmhostutil"
 )
 
 // builtinMetricsGatherer is an MetricsGatherer which gathers builtin metrics:
 //   - goroutines
 //   - memstats (allocations, usage, GC, etc.)
+//   - runtime/metrics (GC pauses, scheduler latencies, etc.), where available
 //   - system and process CPU and memory usage
+//   - cgroup memory and CPU limits and usage, where available
 type builtinMetricsGatherer struct {
 	tracer         *Tracer
 	lastSysMetrics sysMetrics
+	runtimeMetrics *runtimeMetrics
+	cgroup         *apmhostutil.CgroupReader
 }
 
 func newBuiltinMetricsGatherer(t *Tracer) *builtinMetricsGatherer {
-	g := &builtinMetricsGatherer{tracer: t}
+	g := &builtinMetricsGatherer{
+		tracer:         t,
+		runtimeMetrics: newRuntimeMetrics(),
+	}
 	if metrics, err := gatherSysMetrics(); err == nil {
 		g.lastSysMetrics = metrics
 	}
+	if cgroup, err := apmhostutil.NewCgroupReader("/"); err == nil {
+		g.cgroup = cgroup
+	}
 	return g
 }
 
@@ -46,8 +58,13 @@ func newBuiltinMetricsGatherer(t *Tracer) *builtinMetricsGatherer {
 func (g *builtinMetricsGatherer) GatherMetrics(ctx context.Context, m *Metrics) error {
 	m.Add("golang.goroutines", nil, float64(runtime.NumGoroutine()))
 	g.gatherSystemMetrics(m)
-	g.gatherMemStatsMetrics(m)
+	if !g.runtimeMetrics.gather(m) {
+		// runtime.ReadMemStats stops the world, so we only
+		// use it when runtime/metrics is unavailable.
+		g.gatherMemStatsMetrics(m)
+	}
 	g.tracer.breakdownMetrics.gather(m)
+	g.tracer.transactionMetrics.gather(m)
 	return nil
 }
 
@@ -59,13 +76,48 @@ func (g *builtinMetricsGatherer) gatherSystemMetrics(m *Metrics) {
 	systemCPU, processCPU := calculateCPUUsage(metrics.cpu, g.lastSysMetrics.cpu)
 	m.Add("system.cpu.total.norm.pct", nil, systemCPU)
 	m.Add("system.process.cpu.total.norm.pct", nil, processCPU)
-	m.Add("system.memory.total", nil, float64(metrics.mem.system.Total))
-	m.Add("system.memory.actual.free", nil, float64(metrics.mem.system.Available))
 	m.Add("system.process.memory.size", nil, float64(metrics.mem.process.Virtual))
 	m.Add("system.process.memory.rss.bytes", nil, float64(metrics.mem.process.Resident))
+
+	memTotal := metrics.mem.system.Total
+	memFree := metrics.mem.system.Available
+	if g.cgroup != nil {
+		if cgroup, err := g.cgroup.Read(); err == nil {
+			gatherCgroupMetrics(m, cgroup)
+			if mem := cgroup.Memory; mem != nil && mem.Limit > 0 && mem.Limit < memTotal {
+				// The process is constrained by a cgroup memory limit,
+				// so report memory relative to the limit rather than
+				// the host, such that memory percentages computed from
+				// these metrics reflect the limit.
+				memTotal = mem.Limit
+				memFree = 0
+				if mem.Usage < mem.Limit {
+					memFree = mem.Limit - mem.Usage
+				}
+			}
+		}
+	}
+	m.Add("system.memory.total", nil, float64(memTotal))
+	m.Add("system.memory.actual.free", nil, float64(memFree))
 	g.lastSysMetrics = metrics
 }
 
+func gatherCgroupMetrics(m *Metrics, cgroup apmhostutil.CgroupMetrics) {
+	if mem := cgroup.Memory; mem != nil {
+		if mem.Limit > 0 {
+			m.Add("system.process.cgroup.memory.mem.limit.bytes", nil, float64(mem.Limit))
+		}
+		m.Add("system.process.cgroup.memory.mem.usage.bytes", nil, float64(mem.Usage))
+	}
+	if cpu := cgroup.CPU; cpu != nil {
+		m.Add("system.process.cgroup.cpu.cfs.quota.us", nil, float64(cpu.QuotaMicros))
+		m.Add("system.process.cgroup.cpu.cfs.period.us", nil, float64(cpu.PeriodMicros))
+		m.Add("system.process.cgroup.cpu.stats.periods", nil, float64(cpu.Periods))
+		m.Add("system.process.cgroup.cpu.stats.throttled.periods", nil, float64(cpu.ThrottledPeriods))
+		m.Add("system.process.cgroup.cpu.stats.throttled.ns", nil, float64(cpu.ThrottledNanos))
+	}
+}
+
 func (g *builtinMetricsGatherer) gatherMemStatsMetrics(m *Metrics) {
 	var mem runtime.MemStats
 	runtime.ReadMemStats(&mem)
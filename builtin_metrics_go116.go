@@ -0,0 +1,241 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.16
+
+package apm
+
+import (
+	"math"
+	"runtime/metrics"
+)
+
+// memStatsRuntimeMetrics holds the runtime/metrics names from which we
+// derive the equivalent of the runtime.MemStats metrics. If any of these
+// are unsupported by the running Go version, we fall back to reading
+// runtime.MemStats, which stops the world.
+var memStatsRuntimeMetrics = []string{
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+	"/gc/cycles/total:gc-cycles",
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/gc/heap/frees:objects",
+	"/gc/heap/goal:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/heap/tiny/allocs:objects",
+	"/memory/classes/heap/free:bytes",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/released:bytes",
+	"/memory/classes/heap/stacks:bytes",
+	"/memory/classes/heap/unused:bytes",
+	"/memory/classes/os-stacks:bytes",
+	"/memory/classes/total:bytes",
+}
+
+// runtimeMetricsGauges maps runtime/metrics names to the names of
+// the single-value metrics we report for them, when supported by
+// the running Go version.
+var runtimeMetricsGauges = map[string]string{
+	"/sched/goroutines-created:goroutines":   "golang.sched.goroutines.created",
+	"/sched/goroutines/runnable:goroutines":  "golang.sched.goroutines.runnable",
+	"/sched/goroutines/running:goroutines":   "golang.sched.goroutines.running",
+	"/sched/goroutines/waiting:goroutines":   "golang.sched.goroutines.waiting",
+	"/sched/goroutines/not-in-go:goroutines": "golang.sched.goroutines.not_in_go",
+	"/sync/mutex/wait/total:seconds":         "golang.sync.mutex.wait.total.seconds",
+}
+
+// runtimeMetricsHistograms maps histogram metric names to the runtime/metrics
+// names from which they may be derived, in order of preference.
+var runtimeMetricsHistograms = map[string][]string{
+	gcPausesHistogramMetricName:       {"/sched/pauses/total/gc:seconds", "/gc/pauses:seconds"},
+	schedLatenciesHistogramMetricName: {"/sched/latencies:seconds"},
+}
+
+const (
+	gcPausesHistogramMetricName       = "golang.heap.gc.pauses.seconds"
+	schedLatenciesHistogramMetricName = "golang.sched.latencies.seconds"
+)
+
+// runtimeMetrics gathers metrics using the runtime/metrics package,
+// which does not stop the world.
+type runtimeMetrics struct {
+	samples []metrics.Sample
+	index   map[string]int
+
+	// memStats records whether all of memStatsRuntimeMetrics
+	// are supported.
+	memStats bool
+
+	// histograms maps histogram metric names to the index of
+	// the corresponding sample, and lastCounts holds the bucket
+	// counts at the previous gathering, for reporting deltas.
+	histograms map[string]int
+	lastCounts map[string][]uint64
+}
+
+func newRuntimeMetrics() *runtimeMetrics {
+	supported := make(map[string]bool)
+	for _, desc := range metrics.All() {
+		supported[desc.Name] = true
+	}
+	r := &runtimeMetrics{
+		index:      make(map[string]int),
+		histograms: make(map[string]int),
+		lastCounts: make(map[string][]uint64),
+		memStats:   true,
+	}
+	add := func(name string) int {
+		if i, ok := r.index[name]; ok {
+			return i
+		}
+		i := len(r.samples)
+		r.samples = append(r.samples, metrics.Sample{Name: name})
+		r.index[name] = i
+		return i
+	}
+	for _, name := range memStatsRuntimeMetrics {
+		if !supported[name] {
+			r.memStats = false
+			break
+		}
+	}
+	if r.memStats {
+		for _, name := range memStatsRuntimeMetrics {
+			add(name)
+		}
+	}
+	for name := range runtimeMetricsGauges {
+		if supported[name] {
+			add(name)
+		}
+	}
+	for metricName, names := range runtimeMetricsHistograms {
+		for _, name := range names {
+			if supported[name] {
+				r.histograms[metricName] = add(name)
+				break
+			}
+		}
+	}
+
+	// Record the initial histogram counts, so the first
+	// gathering reports the counts since the tracer started.
+	metrics.Read(r.samples)
+	for metricName, i := range r.histograms {
+		r.lastCounts[metricName] = append([]uint64(nil), r.samples[i].Value.Float64Histogram().Counts...)
+	}
+	return r
+}
+
+// gather gathers runtime metrics into m, returning true if the
+// runtime.MemStats equivalent metrics were gathered.
+func (r *runtimeMetrics) gather(m *Metrics) bool {
+	metrics.Read(r.samples)
+	for name, metricName := range runtimeMetricsGauges {
+		if i, ok := r.index[name]; ok {
+			m.Add(metricName, nil, runtimeMetricValue(r.samples[i].Value))
+		}
+	}
+	for metricName, i := range r.histograms {
+		h := r.samples[i].Value.Float64Histogram()
+		last := r.lastCounts[metricName]
+		if len(last) != len(h.Counts) {
+			last = make([]uint64, len(h.Counts))
+		}
+		var values []float64
+		var counts []uint64
+		for j, count := range h.Counts {
+			if delta := count - last[j]; delta > 0 {
+				values = append(values, runtimeHistogramBucketValue(h.Buckets[j], h.Buckets[j+1]))
+				counts = append(counts, delta)
+			}
+		}
+		r.lastCounts[metricName] = append(last[:0], h.Counts...)
+		if len(counts) > 0 {
+			m.AddHistogram(metricName, nil, values, counts)
+		}
+	}
+	if !r.memStats {
+		return false
+	}
+
+	value := func(name string) float64 {
+		return runtimeMetricValue(r.samples[r.index[name]].Value)
+	}
+	heapObjects := value("/memory/classes/heap/objects:bytes")
+	heapUnused := value("/memory/classes/heap/unused:bytes")
+	heapFree := value("/memory/classes/heap/free:bytes")
+	heapReleased := value("/memory/classes/heap/released:bytes")
+	tinyAllocs := value("/gc/heap/tiny/allocs:objects")
+
+	m.Add("golang.heap.allocations.mallocs", nil, value("/gc/heap/allocs:objects")+tinyAllocs)
+	m.Add("golang.heap.allocations.frees", nil, value("/gc/heap/frees:objects")+tinyAllocs)
+	m.Add("golang.heap.allocations.objects", nil, value("/gc/heap/objects:objects"))
+	m.Add("golang.heap.allocations.total", nil, value("/gc/heap/allocs:bytes"))
+	m.Add("golang.heap.allocations.allocated", nil, heapObjects)
+	m.Add("golang.heap.allocations.idle", nil, heapFree+heapReleased)
+	m.Add("golang.heap.allocations.active", nil, heapObjects+heapUnused)
+	m.Add("golang.heap.system.total", nil, value("/memory/classes/total:bytes"))
+	m.Add("golang.heap.system.obtained", nil, heapObjects+heapUnused+heapFree+heapReleased)
+	m.Add("golang.heap.system.stack", nil, value("/memory/classes/heap/stacks:bytes")+value("/memory/classes/os-stacks:bytes"))
+	m.Add("golang.heap.system.released", nil, heapReleased)
+	m.Add("golang.heap.gc.next_gc_limit", nil, value("/gc/heap/goal:bytes"))
+	m.Add("golang.heap.gc.total_count", nil, value("/gc/cycles/total:gc-cycles"))
+
+	// runtime/metrics does not provide the exact total GC pause time,
+	// so we estimate it from the GC pauses histogram.
+	var totalPauseSeconds float64
+	if i, ok := r.histograms[gcPausesHistogramMetricName]; ok {
+		h := r.samples[i].Value.Float64Histogram()
+		for j, count := range h.Counts {
+			totalPauseSeconds += float64(count) * runtimeHistogramBucketValue(h.Buckets[j], h.Buckets[j+1])
+		}
+	}
+	m.Add("golang.heap.gc.total_pause.ns", nil, math.Round(totalPauseSeconds*1e9))
+
+	var gcCPUFraction float64
+	if total := value("/cpu/classes/total:cpu-seconds"); total > 0 {
+		gcCPUFraction = value("/cpu/classes/gc/total:cpu-seconds") / total
+	}
+	m.Add("golang.heap.gc.cpu_fraction", nil, gcCPUFraction)
+	return true
+}
+
+// runtimeMetricValue returns the value of a single-value runtime metric.
+func runtimeMetricValue(v metrics.Value) float64 {
+	switch v.Kind() {
+	case metrics.KindUint64:
+		return float64(v.Uint64())
+	case metrics.KindFloat64:
+		return v.Float64()
+	}
+	return 0
+}
+
+// runtimeHistogramBucketValue returns the value to report for a
+// runtime/metrics histogram bucket with the given boundaries: the
+// midpoint of the bucket, or the finite boundary for open buckets.
+func runtimeHistogramBucketValue(lower, upper float64) float64 {
+	switch {
+	case math.IsInf(lower, -1):
+		return upper
+	case math.IsInf(upper, +1):
+		return lower
+	}
+	return lower + (upper-lower)/2
+}
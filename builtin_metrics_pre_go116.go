@@ -0,0 +1,34 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !go1.16
+
+package apm
+
+// runtimeMetrics is a no-op for Go < 1.16, which lacks the
+// runtime/metrics package.
+type runtimeMetrics struct{}
+
+func newRuntimeMetrics() *runtimeMetrics {
+	return &runtimeMetrics{}
+}
+
+// gather returns false, indicating that the runtime.MemStats
+// equivalent metrics must be gathered by reading runtime.MemStats.
+func (*runtimeMetrics) gather(*Metrics) bool {
+	return false
+}
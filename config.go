@@ -29,41 +29,58 @@ import (
 
 	"github.com/pkg/errors"
 
+	"go.elastic.co/apm/internal/apmlog"
 	"go.elastic.co/apm/internal/configutil"
 	"go.elastic.co/apm/internal/wildcard"
 	"go.elastic.co/apm/model"
 )
 
 const (
-	envMetricsInterval             = "ELASTIC_APM_METRICS_INTERVAL"
-	envMaxSpans                    = "ELASTIC_APM_TRANSACTION_MAX_SPANS"
-	envTransactionSampleRate       = "ELASTIC_APM_TRANSACTION_SAMPLE_RATE"
-	envSanitizeFieldNames          = "ELASTIC_APM_SANITIZE_FIELD_NAMES"
-	envCaptureHeaders              = "ELASTIC_APM_CAPTURE_HEADERS"
-	envCaptureBody                 = "ELASTIC_APM_CAPTURE_BODY"
-	envServiceName                 = "ELASTIC_APM_SERVICE_NAME"
-	envServiceVersion              = "ELASTIC_APM_SERVICE_VERSION"
-	envEnvironment                 = "ELASTIC_APM_ENVIRONMENT"
-	envSpanFramesMinDuration       = "ELASTIC_APM_SPAN_FRAMES_MIN_DURATION"
-	envActive                      = "ELASTIC_APM_ACTIVE"
-	envRecording                   = "ELASTIC_APM_RECORDING"
-	envAPIRequestSize              = "ELASTIC_APM_API_REQUEST_SIZE"
-	envAPIRequestTime              = "ELASTIC_APM_API_REQUEST_TIME"
-	envAPIBufferSize               = "ELASTIC_APM_API_BUFFER_SIZE"
-	envMetricsBufferSize           = "ELASTIC_APM_METRICS_BUFFER_SIZE"
-	envDisableMetrics              = "ELASTIC_APM_DISABLE_METRICS"
-	envGlobalLabels                = "ELASTIC_APM_GLOBAL_LABELS"
-	envStackTraceLimit             = "ELASTIC_APM_STACK_TRACE_LIMIT"
-	envCentralConfig               = "ELASTIC_APM_CENTRAL_CONFIG"
-	envBreakdownMetrics            = "ELASTIC_APM_BREAKDOWN_METRICS"
-	envUseElasticTraceparentHeader = "ELASTIC_APM_USE_ELASTIC_TRACEPARENT_HEADER"
+	envMetricsInterval                = "ELASTIC_APM_METRICS_INTERVAL"
+	envMaxSpans                       = "ELASTIC_APM_TRANSACTION_MAX_SPANS"
+	envTransactionSampleRate          = "ELASTIC_APM_TRANSACTION_SAMPLE_RATE"
+	envSanitizeFieldNames             = "ELASTIC_APM_SANITIZE_FIELD_NAMES"
+	envCaptureHeaders                 = "ELASTIC_APM_CAPTURE_HEADERS"
+	envCaptureBody                    = "ELASTIC_APM_CAPTURE_BODY"
+	envServiceName                    = "ELASTIC_APM_SERVICE_NAME"
+	envServiceVersion                 = "ELASTIC_APM_SERVICE_VERSION"
+	envEnvironment                    = "ELASTIC_APM_ENVIRONMENT"
+	envSpanFramesMinDuration          = "ELASTIC_APM_SPAN_FRAMES_MIN_DURATION"
+	envActive                         = "ELASTIC_APM_ACTIVE"
+	envRecording                      = "ELASTIC_APM_RECORDING"
+	envAPIRequestSize                 = "ELASTIC_APM_API_REQUEST_SIZE"
+	envAPIRequestTime                 = "ELASTIC_APM_API_REQUEST_TIME"
+	envAPIBufferSize                  = "ELASTIC_APM_API_BUFFER_SIZE"
+	envMetricsBufferSize              = "ELASTIC_APM_METRICS_BUFFER_SIZE"
+	envDisableMetrics                 = "ELASTIC_APM_DISABLE_METRICS"
+	envMetricsLabelSetsLimit          = "ELASTIC_APM_METRICS_LABEL_SETS_LIMIT"
+	envMetricsLabelSetsPerMetricLimit = "ELASTIC_APM_METRICS_LABEL_SETS_PER_METRIC_LIMIT"
+	envGlobalLabels                   = "ELASTIC_APM_GLOBAL_LABELS"
+	envStackTraceLimit                = "ELASTIC_APM_STACK_TRACE_LIMIT"
+	envCentralConfig                  = "ELASTIC_APM_CENTRAL_CONFIG"
+	envBreakdownMetrics               = "ELASTIC_APM_BREAKDOWN_METRICS"
+	envUseElasticTraceparentHeader    = "ELASTIC_APM_USE_ELASTIC_TRACEPARENT_HEADER"
+	envLogLevel                       = "ELASTIC_APM_LOG_LEVEL"
+	envPropagators                    = "ELASTIC_APM_PROPAGATORS"
+	envBaggageToAttach                = "ELASTIC_APM_BAGGAGE_TO_ATTACH"
 
 	// NOTE(axw) profiling environment variables are experimental.
 	// They may be removed in a future minor version without being
 	// considered a breaking change.
-	envCPUProfileInterval  = "ELASTIC_APM_CPU_PROFILE_INTERVAL"
-	envCPUProfileDuration  = "ELASTIC_APM_CPU_PROFILE_DURATION"
-	envHeapProfileInterval = "ELASTIC_APM_HEAP_PROFILE_INTERVAL"
+	envCPUProfileInterval       = "ELASTIC_APM_CPU_PROFILE_INTERVAL"
+	envCPUProfileDuration       = "ELASTIC_APM_CPU_PROFILE_DURATION"
+	envHeapProfileInterval      = "ELASTIC_APM_HEAP_PROFILE_INTERVAL"
+	envGoroutineProfileInterval = "ELASTIC_APM_GOROUTINE_PROFILE_INTERVAL"
+	envMutexProfileInterval     = "ELASTIC_APM_MUTEX_PROFILE_INTERVAL"
+	envMutexProfileDuration     = "ELASTIC_APM_MUTEX_PROFILE_DURATION"
+	envMutexProfileFraction     = "ELASTIC_APM_MUTEX_PROFILE_FRACTION"
+	envBlockProfileInterval     = "ELASTIC_APM_BLOCK_PROFILE_INTERVAL"
+	envBlockProfileDuration     = "ELASTIC_APM_BLOCK_PROFILE_DURATION"
+	envBlockProfileRate         = "ELASTIC_APM_BLOCK_PROFILE_RATE"
+	envProfileDir               = "ELASTIC_APM_PROFILE_DIR"
+	envProfileDirCompress       = "ELASTIC_APM_PROFILE_DIR_COMPRESS"
+	envProfileDirMaxFiles       = "ELASTIC_APM_PROFILE_DIR_MAX_FILES"
+	envProfileDirMaxAge         = "ELASTIC_APM_PROFILE_DIR_MAX_AGE"
 
 	defaultAPIRequestSize        = 750 * configutil.KByte
 	defaultAPIRequestTime        = 10 * time.Second
@@ -75,6 +92,13 @@ const (
 	defaultCaptureBody           = CaptureBodyOff
 	defaultSpanFramesMinDuration = 5 * time.Millisecond
 	defaultStackTraceLimit       = 50
+	defaultMetricsLabelSetsLimit = 2000
+	defaultMetricLabelSetsLimit  = 200
+
+	defaultSampledProfileDuration = 10 * time.Second
+	defaultMutexProfileFraction   = 5
+	defaultBlockProfileRate       = 0 // nanoseconds; disabled
+	defaultProfileDirMaxFiles     = 100
 
 	minAPIBufferSize     = 10 * configutil.KByte
 	maxAPIBufferSize     = 100 * configutil.MByte
@@ -205,6 +229,10 @@ func initialSanitizedFieldNames() wildcard.Matchers {
 	return configutil.ParseWildcardPatternsEnv(envSanitizeFieldNames, defaultSanitizedFieldNames)
 }
 
+func initialBaggageToAttach() wildcard.Matchers {
+	return configutil.ParseWildcardPatternsEnv(envBaggageToAttach, nil)
+}
+
 func initialCaptureHeaders() (bool, error) {
 	return configutil.ParseBoolEnv(envCaptureHeaders, defaultCaptureHeaders)
 }
@@ -277,6 +305,30 @@ func initialCentralConfigEnabled() (bool, error) {
 	return configutil.ParseBoolEnv(envCentralConfig, true)
 }
 
+func initialMetricsLabelSetsLimits() (total, perMetric int, err error) {
+	total, err = parseIntEnv(envMetricsLabelSetsLimit, defaultMetricsLabelSetsLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	perMetric, err = parseIntEnv(envMetricsLabelSetsPerMetricLimit, defaultMetricLabelSetsLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, perMetric, nil
+}
+
+func parseIntEnv(envKey string, defaultValue int) (int, error) {
+	value := os.Getenv(envKey)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse %s", envKey)
+	}
+	return n, nil
+}
+
 func initialBreakdownMetricsEnabled() (bool, error) {
 	return configutil.ParseBoolEnv(envBreakdownMetrics, true)
 }
@@ -285,6 +337,15 @@ func initialUseElasticTraceparentHeader() (bool, error) {
 	return configutil.ParseBoolEnv(envUseElasticTraceparentHeader, true)
 }
 
+func initialPropagator(propagateLegacyHeader bool) (Propagator, error) {
+	names := configutil.ParseListEnv(envPropagators, ",", []string{"tracecontext"})
+	propagator, err := newPropagator(names, propagateLegacyHeader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", envPropagators)
+	}
+	return propagator, nil
+}
+
 func initialCPUProfileIntervalDuration() (time.Duration, time.Duration, error) {
 	interval, err := configutil.ParseDurationEnv(envCPUProfileInterval, 0)
 	if err != nil || interval <= 0 {
@@ -301,6 +362,68 @@ func initialHeapProfileInterval() (time.Duration, error) {
 	return configutil.ParseDurationEnv(envHeapProfileInterval, 0)
 }
 
+func initialGoroutineProfileInterval() (time.Duration, error) {
+	return configutil.ParseDurationEnv(envGoroutineProfileInterval, 0)
+}
+
+func initialMutexProfileIntervalDuration() (time.Duration, time.Duration, error) {
+	return initialSampledProfileIntervalDuration(envMutexProfileInterval, envMutexProfileDuration)
+}
+
+func initialBlockProfileIntervalDuration() (time.Duration, time.Duration, error) {
+	return initialSampledProfileIntervalDuration(envBlockProfileInterval, envBlockProfileDuration)
+}
+
+// initialSampledProfileIntervalDuration returns the interval and duration
+// for the mutex or block profiles. Unlike CPU profiling, the duration may
+// be zero, in which case the profile is captured without enabling sampling.
+func initialSampledProfileIntervalDuration(intervalEnv, durationEnv string) (time.Duration, time.Duration, error) {
+	interval, err := configutil.ParseDurationEnv(intervalEnv, 0)
+	if err != nil || interval <= 0 {
+		return 0, 0, err
+	}
+	duration, err := configutil.ParseDurationEnv(durationEnv, defaultSampledProfileDuration)
+	if err != nil || duration < 0 {
+		return 0, 0, err
+	}
+	return interval, duration, nil
+}
+
+func initialMutexProfileFraction() (int, error) {
+	return parseIntEnv(envMutexProfileFraction, defaultMutexProfileFraction)
+}
+
+func initialBlockProfileRate() (int, error) {
+	return parseIntEnv(envBlockProfileRate, defaultBlockProfileRate)
+}
+
+// initialProfileDir returns the local profile directory sink
+// configuration, or nil if no profile directory is configured.
+func initialProfileDir() (*profileDir, error) {
+	path := os.Getenv(envProfileDir)
+	if path == "" {
+		return nil, nil
+	}
+	compress, err := configutil.ParseBoolEnv(envProfileDirCompress, true)
+	if err != nil {
+		return nil, err
+	}
+	maxFiles, err := parseIntEnv(envProfileDirMaxFiles, defaultProfileDirMaxFiles)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := configutil.ParseDurationEnv(envProfileDirMaxAge, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &profileDir{
+		path:     path,
+		compress: compress,
+		maxFiles: maxFiles,
+		maxAge:   maxAge,
+	}, nil
+}
+
 // updateRemoteConfig updates t and cfg with changes held in "attrs", and reverts to local
 // config for config attributes that have been removed (exist in old but not in attrs).
 //
@@ -379,6 +502,33 @@ func (t *Tracer) updateRemoteConfig(logger WarningLogger, old, attrs map[string]
 					cfg.stackTraceLimit = limit
 				})
 			}
+		case envGoroutineProfileInterval, envMutexProfileInterval, envMutexProfileDuration,
+			envBlockProfileInterval, envBlockProfileDuration:
+			duration, err := configutil.ParseDuration(v)
+			if err == nil && duration < 0 {
+				err = errors.New("duration must not be negative")
+			}
+			if err != nil {
+				errorf("central config failure: failed to parse %s: %s", k, err)
+				delete(attrs, k)
+				continue
+			} else {
+				env := envName(k)
+				updates = append(updates, func(cfg *instrumentationConfig) {
+					*cfg.profiling.durationField(env) = duration
+				})
+			}
+		case envLogLevel:
+			level, err := apmlog.ParseLevel(v)
+			if err != nil {
+				errorf("central config failure: failed to parse %s: %s", k, err)
+				delete(attrs, k)
+				continue
+			} else {
+				updates = append(updates, func(cfg *instrumentationConfig) {
+					cfg.logLevel = level.String()
+				})
+			}
 		case envTransactionSampleRate:
 			sampler, err := parseSampleRate(k, v)
 			if err != nil {
@@ -486,4 +636,41 @@ type instrumentationConfigValues struct {
 	spanFramesMinDuration time.Duration
 	stackTraceLimit       int
 	propagateLegacyHeader bool
+	baggageToAttach       wildcard.Matchers
+	profiling             profilingConfig
+
+	// logLevel holds the log level to set for the tracer's logger,
+	// if it supports changing levels, or is empty if the logger's
+	// level should not be changed.
+	logLevel string
+}
+
+// profilingConfig holds the centrally configurable profiling
+// configuration. This is not used by instrumentation, but is
+// held in instrumentationConfigValues so that it can be reverted
+// to local config, and is propagated to the tracer loop.
+type profilingConfig struct {
+	goroutineProfileInterval time.Duration
+	mutexProfileInterval     time.Duration
+	mutexProfileDuration     time.Duration
+	blockProfileInterval     time.Duration
+	blockProfileDuration     time.Duration
+}
+
+// durationField returns a pointer to the field of c
+// corresponding to the given environment variable.
+func (c *profilingConfig) durationField(envKey string) *time.Duration {
+	switch envKey {
+	case envGoroutineProfileInterval:
+		return &c.goroutineProfileInterval
+	case envMutexProfileInterval:
+		return &c.mutexProfileInterval
+	case envMutexProfileDuration:
+		return &c.mutexProfileDuration
+	case envBlockProfileInterval:
+		return &c.blockProfileInterval
+	case envBlockProfileDuration:
+		return &c.blockProfileDuration
+	}
+	panic("unexpected profiling config " + envKey)
 }
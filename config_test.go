@@ -26,6 +26,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"go.elastic.co/apm"
 	"go.elastic.co/apm/apmconfig"
 	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/internal/apmlog"
 	"go.elastic.co/apm/transport"
 	"go.elastic.co/apm/transport/transporttest"
 )
@@ -260,3 +262,63 @@ func TestTracerConfigWatcherPrecedence(t *testing.T) {
 		}
 	}
 }
+
+func TestTracerSetLogLevel(t *testing.T) {
+	tracer := apmtest.NewDiscardTracer()
+	defer tracer.Close()
+
+	var logger levelLogger
+	logger.SetLevel(apmlog.ErrorLevel)
+	tracer.SetLogger(&logger)
+
+	assert.EqualError(t, tracer.SetLogLevel("panic"), `invalid log level string "panic"`)
+	require.NoError(t, tracer.SetLogLevel("debug"))
+	tracer.Flush(nil)
+	assert.Equal(t, apmlog.DebugLevel, logger.Level())
+}
+
+func TestTracerCentralConfigLogLevel(t *testing.T) {
+	tracer := apmtest.NewDiscardTracer()
+	defer tracer.Close()
+
+	var logger levelLogger
+	logger.SetLevel(apmlog.ErrorLevel)
+	tracer.SetLogger(&logger)
+	require.NoError(t, tracer.SetLogLevel("warn"))
+
+	changes := make(chan apmconfig.Change)
+	watcherFunc := apmtest.WatchConfigFunc(func(ctx context.Context, params apmconfig.WatchParams) <-chan apmconfig.Change {
+		return changes
+	})
+	tracer.SetConfigWatcher(watcherFunc)
+
+	changes <- apmconfig.Change{Attrs: map[string]string{"log_level": "trace"}}
+	tracer.Flush(nil)
+	assert.Equal(t, apmlog.DebugLevel, logger.Level())
+
+	// Central config takes precedence over local config.
+	require.NoError(t, tracer.SetLogLevel("info"))
+	tracer.Flush(nil)
+	assert.Equal(t, apmlog.DebugLevel, logger.Level())
+
+	// Removing central config reverts to local config.
+	changes <- apmconfig.Change{Attrs: map[string]string{}}
+	tracer.Flush(nil)
+	assert.Equal(t, apmlog.InfoLevel, logger.Level())
+}
+
+type levelLogger struct {
+	level uint32
+}
+
+func (l *levelLogger) Debugf(format string, args ...interface{})   {}
+func (l *levelLogger) Errorf(format string, args ...interface{})   {}
+func (l *levelLogger) Warningf(format string, args ...interface{}) {}
+
+func (l *levelLogger) Level() apmlog.Level {
+	return apmlog.Level(atomic.LoadUint32(&l.level))
+}
+
+func (l *levelLogger) SetLevel(level apmlog.Level) {
+	atomic.StoreUint32(&l.level, uint32(level))
+}
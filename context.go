@@ -37,6 +37,9 @@ type Context struct {
 	user             model.User
 	service          model.Service
 	serviceFramework model.Framework
+	message          model.MessageContext
+	messageQueue     model.MessageQueueContext
+	messageAge       model.MessageAgeContext
 	captureHeaders   bool
 	captureBodyMask  CaptureBodyMode
 }
@@ -47,6 +50,7 @@ func (c *Context) build() *model.Context {
 	case c.model.Response != nil:
 	case c.model.User != nil:
 	case c.model.Service != nil:
+	case c.model.Message != nil:
 	case len(c.model.Tags) != 0:
 	case len(c.model.Custom) != 0:
 	default:
@@ -254,3 +258,14 @@ func (c *Context) SetUsername(username string) {
 		c.model.User = &c.user
 	}
 }
+
+// SetMessage sets the context of the message relating to the
+// transaction, e.g. the name of the queue or topic from which
+// the message was received.
+func (c *Context) SetMessage(m MessageContext) {
+	if m.build(&c.message, &c.messageQueue, &c.messageAge) {
+		c.model.Message = &c.message
+	} else {
+		c.model.Message = nil
+	}
+}
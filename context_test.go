@@ -20,6 +20,7 @@ package apm_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -100,6 +101,37 @@ func TestContextFramework(t *testing.T) {
 	})
 }
 
+func TestContextMessage(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		tx := testSendTransaction(t, func(tx *apm.Transaction) {
+			tx.Context.SetMessage(apm.MessageContext{})
+		})
+		assert.Nil(t, tx.Context)
+	})
+	t.Run("queue_and_age", func(t *testing.T) {
+		tx := testSendTransaction(t, func(tx *apm.Transaction) {
+			tx.Context.SetMessage(apm.MessageContext{
+				QueueName: "orders",
+				Age:       1500 * time.Millisecond,
+			})
+		})
+		require.NotNil(t, tx.Context)
+		assert.Equal(t, &model.MessageContext{
+			Queue: &model.MessageQueueContext{Name: "orders"},
+			Age:   &model.MessageAgeContext{Milliseconds: 1500},
+		}, tx.Context.Message)
+	})
+	t.Run("negative_age", func(t *testing.T) {
+		tx := testSendTransaction(t, func(tx *apm.Transaction) {
+			tx.Context.SetMessage(apm.MessageContext{QueueName: "orders", Age: -time.Second})
+		})
+		require.NotNil(t, tx.Context)
+		assert.Equal(t, &model.MessageContext{
+			Queue: &model.MessageQueueContext{Name: "orders"},
+		}, tx.Context.Message)
+	})
+}
+
 func TestContextCustom(t *testing.T) {
 	type arbitraryStruct struct {
 		Field string
@@ -0,0 +1,334 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a custom metric holding a monotonically increasing count.
+//
+// Counters report their total value since creation. Counter methods
+// are safe for concurrent use, and do not allocate.
+type Counter struct {
+	value uint64 // accessed atomically; must be first for alignment
+	customMetric
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add increments the counter by n.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) gather(m *Metrics) {
+	m.Add(c.name, c.labels, float64(c.Value()))
+}
+
+// Gauge is a custom metric holding a value which may go up and down.
+//
+// Gauge methods are safe for concurrent use, and do not allocate.
+type Gauge struct {
+	bits uint64 // float64 bits, accessed atomically; must be first for alignment
+	customMetric
+}
+
+// Set sets the gauge value to v.
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds delta, which may be negative, to the gauge value.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		new := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, new) {
+			return
+		}
+	}
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) gather(m *Metrics) {
+	m.Add(g.name, g.labels, g.Value())
+}
+
+// Histogram is a custom metric which counts observed values in
+// configurable buckets.
+//
+// Histograms report the bucket counts observed since the previous
+// metrics were gathered, and are omitted if no values were observed
+// in the interval. Histogram methods are safe for concurrent use, and do not allocate.
+type Histogram struct {
+	// counts holds a count for each bucket, and one for values
+	// greater than the largest bucket bound. counts is accessed
+	// atomically, and reset when gathered.
+	counts []uint64
+
+	// bounds holds the inclusive upper bounds of the buckets,
+	// sorted in ascending order.
+	bounds []float64
+
+	customMetric
+}
+
+// Observe records the value v in the histogram.
+func (h *Histogram) Observe(v float64) {
+	atomic.AddUint64(&h.counts[sort.SearchFloat64s(h.bounds, v)], 1)
+}
+
+func (h *Histogram) gather(m *Metrics) {
+	var values []float64
+	var counts []uint64
+	var lower float64
+	for i := range h.counts {
+		var value float64
+		if i < len(h.bounds) {
+			// Report the midpoint of the bucket. The first bucket's
+			// value is half its upper bound, as with Prometheus.
+			upper := h.bounds[i]
+			value = lower + (upper-lower)/2
+			if i == 0 {
+				value = upper / 2
+			}
+			lower = upper
+		} else {
+			// Values greater than the largest bucket bound are
+			// reported using the largest bucket bound.
+			value = lower
+		}
+		if count := atomic.SwapUint64(&h.counts[i], 0); count > 0 {
+			values = append(values, value)
+			counts = append(counts, count)
+		}
+	}
+	if len(counts) > 0 {
+		m.AddHistogram(h.name, h.labels, values, counts)
+	}
+}
+
+// customMetric holds the identifying information common to all
+// custom metrics.
+type customMetric struct {
+	name   string
+	labels []MetricLabel
+}
+
+// Counter returns the Counter with the given name and labels, creating
+// and registering it with t if it does not already exist.
+//
+// Custom metrics are reported with t's other metrics, and are subject
+// to the same configuration, e.g. ELASTIC_APM_DISABLE_METRICS. A name
+// and label set identifies a single metric. If the name and label set
+// is already in use by a metric of a different kind, a warning is logged
+// and an unregistered metric is returned, which will not be reported.
+func (t *Tracer) Counter(name string, labels ...MetricLabel) *Counter {
+	m, registered := t.customMetrics.getOrRegister(name, labels, func(m customMetric) customMetricGatherer {
+		return &Counter{customMetric: m}
+	})
+	c, ok := registered.(*Counter)
+	if !ok {
+		t.warnCustomMetricConflict(name, "counter", registered)
+		c = &Counter{customMetric: m}
+	}
+	return c
+}
+
+// Gauge returns the Gauge with the given name and labels, creating
+// and registering it with t if it does not already exist.
+//
+// See Tracer.Counter for details on custom metrics.
+func (t *Tracer) Gauge(name string, labels ...MetricLabel) *Gauge {
+	m, registered := t.customMetrics.getOrRegister(name, labels, func(m customMetric) customMetricGatherer {
+		return &Gauge{customMetric: m}
+	})
+	g, ok := registered.(*Gauge)
+	if !ok {
+		t.warnCustomMetricConflict(name, "gauge", registered)
+		g = &Gauge{customMetric: m}
+	}
+	return g
+}
+
+// Histogram returns the Histogram with the given name and labels, creating
+// and registering it with t if it does not already exist. The histogram's
+// buckets are defined by their inclusive upper bounds, which are sorted in
+// ascending order; NaN and duplicate bounds are ignored, and cause a warning
+// to be logged. If the histogram already exists, buckets is ignored.
+//
+// See Tracer.Counter for details on custom metrics.
+func (t *Tracer) Histogram(name string, buckets []float64, labels ...MetricLabel) *Histogram {
+	// Warnings must be logged outside of getOrRegister,
+	// which holds the registry lock while calling newHistogram.
+	invalidBuckets := false
+	newHistogram := func(m customMetric) customMetricGatherer {
+		bounds, valid := histogramBounds(buckets)
+		invalidBuckets = !valid
+		return &Histogram{
+			customMetric: m,
+			bounds:       bounds,
+			counts:       make([]uint64, len(bounds)+1),
+		}
+	}
+	m, registered := t.customMetrics.getOrRegister(name, labels, newHistogram)
+	h, ok := registered.(*Histogram)
+	if !ok {
+		t.warnCustomMetricConflict(name, "histogram", registered)
+		h = newHistogram(m).(*Histogram)
+	}
+	if invalidBuckets {
+		t.warnf("invalid bucket bounds for histogram %q ignored: %v", name, buckets)
+	}
+	return h
+}
+
+// histogramBounds returns a sorted copy of buckets, with NaN and
+// duplicate values removed, and reports whether buckets was valid,
+// i.e. it did not contain any NaN or duplicate values.
+func histogramBounds(buckets []float64) ([]float64, bool) {
+	bounds := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsNaN(b) {
+			bounds = append(bounds, b)
+		}
+	}
+	sort.Float64s(bounds)
+	n := 0
+	for i, b := range bounds {
+		if i == 0 || b != bounds[n-1] {
+			bounds[n] = b
+			n++
+		}
+	}
+	bounds = bounds[:n]
+	return bounds, len(bounds) == len(buckets)
+}
+
+// warnCustomMetricConflict logs a warning that the custom metric name
+// could not be registered as the given kind, due to existing metric m.
+func (t *Tracer) warnCustomMetricConflict(name, kind string, m customMetricGatherer) {
+	var existing string
+	switch m.(type) {
+	case *Counter:
+		existing = "counter"
+	case *Gauge:
+		existing = "gauge"
+	case *Histogram:
+		existing = "histogram"
+	}
+	t.warnf("custom metric %q is already registered as a %s; %s will not be reported", name, existing, kind)
+}
+
+// warnf logs a warning with the tracer's logger, if any.
+func (t *Tracer) warnf(format string, args ...interface{}) {
+	t.sendConfigCommand(func(cfg *tracerConfig) {
+		if cfg.logger != nil {
+			cfg.logger.Warningf(format, args...)
+		}
+	})
+}
+
+type customMetricGatherer interface {
+	gather(*Metrics)
+}
+
+// customMetrics is a registry of custom metrics, which is
+// registered as a MetricsGatherer with its tracer.
+type customMetrics struct {
+	mu      sync.RWMutex
+	metrics map[string]customMetricGatherer
+}
+
+// getOrRegister returns the metric registered with the given name and
+// labels, calling newMetric to create and register it if there is none.
+// The identifying information for the metric, with labels sorted, is
+// also returned.
+func (r *customMetrics) getOrRegister(
+	name string, labels []MetricLabel,
+	newMetric func(customMetric) customMetricGatherer,
+) (customMetric, customMetricGatherer) {
+	labels = append([]MetricLabel(nil), labels...)
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Name != labels[j].Name {
+			return labels[i].Name < labels[j].Name
+		}
+		return labels[i].Value < labels[j].Value
+	})
+	key := customMetricKey(name, labels)
+	cm := customMetric{name: name, labels: labels}
+
+	r.mu.RLock()
+	m, ok := r.metrics[key]
+	r.mu.RUnlock()
+	if ok {
+		return cm, m
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[key]; ok {
+		return cm, m
+	}
+	if r.metrics == nil {
+		r.metrics = make(map[string]customMetricGatherer)
+	}
+	m = newMetric(cm)
+	r.metrics[key] = m
+	return cm, m
+}
+
+// GatherMetrics gathers the custom metrics into m.
+func (r *customMetrics) GatherMetrics(ctx context.Context, m *Metrics) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, metric := range r.metrics {
+		metric.gather(m)
+	}
+	return nil
+}
+
+func customMetricKey(name string, labels []MetricLabel) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, l := range labels {
+		b.WriteByte(0)
+		b.WriteString(l.Name)
+		b.WriteByte(0)
+		b.WriteString(l.Value)
+	}
+	return b.String()
+}
@@ -0,0 +1,229 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm_test
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/transport/transporttest"
+)
+
+func TestCustomMetrics(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	orders := tracer.Counter("orders", apm.MetricLabel{Name: "region", Value: "eu"})
+	orders.Inc()
+	orders.Add(2)
+	assert.Same(t, orders, tracer.Counter("orders", apm.MetricLabel{Name: "region", Value: "eu"}))
+
+	queued := tracer.Gauge("queue.length", apm.MetricLabel{Name: "region", Value: "eu"})
+	queued.Set(10)
+	queued.Add(-2.5)
+
+	latency := tracer.Histogram("checkout.latency", []float64{1, 2, 4})
+	for _, v := range []float64{0.5, 1.5, 1.5, 3, 10} {
+		latency.Observe(v)
+	}
+
+	tracer.SendMetrics(nil)
+	metrics := transport.Payloads().Metrics
+	require.Len(t, metrics, 2)
+
+	// metrics[0] holds builtin metrics, and metrics without labels.
+	assert.Equal(t, model.Metric{
+		Type:   "histogram",
+		Values: []float64{0.5, 1.5, 3, 4},
+		Counts: []uint64{1, 2, 1, 1},
+	}, metrics[0].Samples["checkout.latency"])
+
+	assert.Equal(t, model.StringMap{{Key: "region", Value: "eu"}}, metrics[1].Labels)
+	assert.Equal(t, map[string]model.Metric{
+		"orders":       {Value: 3},
+		"queue.length": {Value: 7.5},
+	}, metrics[1].Samples)
+}
+
+func TestCustomMetricsLabelsSorted(t *testing.T) {
+	tracer, _ := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	a := tracer.Counter("c", apm.MetricLabel{Name: "a", Value: "1"}, apm.MetricLabel{Name: "b", Value: "2"})
+	b := tracer.Counter("c", apm.MetricLabel{Name: "b", Value: "2"}, apm.MetricLabel{Name: "a", Value: "1"})
+	assert.Same(t, a, b)
+	assert.False(t, a == tracer.Counter("c", apm.MetricLabel{Name: "a", Value: "2"}))
+}
+
+func TestCustomMetricsDisabled(t *testing.T) {
+	os.Setenv("ELASTIC_APM_DISABLE_METRICS", "orders")
+	defer os.Unsetenv("ELASTIC_APM_DISABLE_METRICS")
+
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	tracer.Counter("orders").Inc()
+	tracer.Counter("refunds").Inc()
+	tracer.SendMetrics(nil)
+
+	metrics := transport.Payloads().Metrics
+	require.Len(t, metrics, 1)
+	assert.NotContains(t, metrics[0].Samples, "orders")
+	assert.Contains(t, metrics[0].Samples, "refunds")
+}
+
+func TestCustomMetricsKindConflict(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	var logger apmtest.RecordLogger
+	tracer.SetLogger(&logger)
+
+	counter := tracer.Counter("requests")
+	counter.Inc()
+	gauge := tracer.Gauge("requests")
+	gauge.Set(10)
+	histogram := tracer.Histogram("requests", []float64{1})
+	histogram.Observe(1)
+	assert.Same(t, counter, tracer.Counter("requests"))
+
+	tracer.SendMetrics(nil)
+	metrics := transport.Payloads().Metrics
+	require.Len(t, metrics, 1)
+	assert.Equal(t, model.Metric{Value: 1}, metrics[0].Samples["requests"])
+
+	var warnings []string
+	for _, record := range logger.Records {
+		if record.Level == "warning" {
+			warnings = append(warnings, record.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		`custom metric "requests" is already registered as a counter; gauge will not be reported`,
+		`custom metric "requests" is already registered as a counter; histogram will not be reported`,
+	}, warnings)
+}
+
+func TestCustomMetricsHistogramBuckets(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	var logger apmtest.RecordLogger
+	tracer.SetLogger(&logger)
+
+	latency := tracer.Histogram("latency", []float64{4, 1, math.NaN(), 2, 2})
+	for _, v := range []float64{0.5, 1.5, 3, 10} {
+		latency.Observe(v)
+	}
+
+	tracer.SendMetrics(nil)
+	metrics := transport.Payloads().Metrics
+	require.Len(t, metrics, 1)
+	assert.Equal(t, model.Metric{
+		Type:   "histogram",
+		Values: []float64{0.5, 1.5, 3, 4},
+		Counts: []uint64{1, 1, 1, 1},
+	}, metrics[0].Samples["latency"])
+
+	var warnings []string
+	for _, record := range logger.Records {
+		if record.Level == "warning" {
+			warnings = append(warnings, record.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		`invalid bucket bounds for histogram "latency" ignored: [4 1 NaN 2 2]`,
+	}, warnings)
+}
+
+func TestCustomMetricsHistogramDelta(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	latency := tracer.Histogram("latency", []float64{1, 2, 4})
+	latency.Observe(0.5)
+	latency.Observe(3)
+	tracer.SendMetrics(nil)
+
+	latency.Observe(3)
+	latency.Observe(3)
+	tracer.SendMetrics(nil)
+
+	// No values observed since the metrics were last gathered.
+	tracer.SendMetrics(nil)
+
+	var samples []model.Metric
+	for _, metrics := range transport.Payloads().Metrics {
+		if sample, ok := metrics.Samples["latency"]; ok {
+			samples = append(samples, sample)
+		}
+	}
+	assert.Equal(t, []model.Metric{{
+		Type:   "histogram",
+		Values: []float64{0.5, 3},
+		Counts: []uint64{1, 1},
+	}, {
+		Type:   "histogram",
+		Values: []float64{3},
+		Counts: []uint64{2},
+	}}, samples)
+}
+
+func TestCustomMetricsAllocations(t *testing.T) {
+	tracer, _ := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	counter := tracer.Counter("counter")
+	gauge := tracer.Gauge("gauge")
+	histogram := tracer.Histogram("histogram", []float64{1, 10, 100})
+	allocs := testing.AllocsPerRun(100, func() {
+		counter.Inc()
+		gauge.Add(1)
+		histogram.Observe(50)
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkCounterInc(b *testing.B) {
+	tracer, _ := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+	counter := tracer.Counter("counter")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			counter.Inc()
+		}
+	})
+}
+
+func BenchmarkHistogramObserve(b *testing.B) {
+	tracer, _ := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+	histogram := tracer.Histogram("histogram", []float64{1, 2, 4, 8, 16, 32, 64, 128})
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			histogram.Observe(10)
+		}
+	})
+}
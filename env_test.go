@@ -171,6 +171,39 @@ func testTracerTransactionRateEnv(t *testing.T, envValue string, ratio float64)
 	assert.InDelta(t, N*ratio, sampled, N*0.02) // allow 2% error
 }
 
+func TestTracerPropagatorsEnv(t *testing.T) {
+	os.Setenv("ELASTIC_APM_PROPAGATORS", "tracecontext, b3, b3multi,jaeger,XRay")
+	defer os.Unsetenv("ELASTIC_APM_PROPAGATORS")
+	os.Setenv("ELASTIC_APM_USE_ELASTIC_TRACEPARENT_HEADER", "false")
+	defer os.Unsetenv("ELASTIC_APM_USE_ELASTIC_TRACEPARENT_HEADER")
+
+	tracer, err := apm.NewTracer("", "")
+	require.NoError(t, err)
+	defer tracer.Close()
+	assert.Equal(t, apm.CompositePropagator{
+		apm.W3CTraceContextPropagator{PropagateLegacyHeader: false},
+		apm.B3Propagator{SingleHeader: true},
+		apm.B3Propagator{},
+		apm.JaegerPropagator{},
+		apm.XRayPropagator{},
+	}, tracer.Propagator())
+}
+
+func TestTracerPropagatorsEnvDefault(t *testing.T) {
+	tracer, err := apm.NewTracer("", "")
+	require.NoError(t, err)
+	defer tracer.Close()
+	assert.Equal(t, apm.W3CTraceContextPropagator{PropagateLegacyHeader: true}, tracer.Propagator())
+}
+
+func TestTracerPropagatorsEnvInvalid(t *testing.T) {
+	os.Setenv("ELASTIC_APM_PROPAGATORS", "tracecontext,zipkin")
+	defer os.Unsetenv("ELASTIC_APM_PROPAGATORS")
+
+	_, err := apm.NewTracer("", "")
+	assert.EqualError(t, err, `failed to parse ELASTIC_APM_PROPAGATORS: unknown propagator "zipkin"`)
+}
+
 func TestTracerSanitizeFieldNamesEnv(t *testing.T) {
 	testTracerSanitizeFieldNamesEnv(t, "secRet", "[REDACTED]")
 	testTracerSanitizeFieldNamesEnv(t, "nada", "top")
@@ -31,8 +31,22 @@ func ContextWithSpan(parent context.Context, s *Span) context.Context {
 
 // ContextWithTransaction returns a copy of parent in which the given
 // transaction is stored, associated with the key ContextTransactionKey.
+//
+// If the transaction's tracer is capturing a CPU profile, runtime/pprof
+// labels identifying the transaction (transaction.name, transaction.id,
+// and trace.id) are added to the returned context, enabling CPU profiles
+// to be filtered by transaction. The labels may be set on the calling
+// goroutine with SetProfilerLabels.
+//
+// Members of the baggage in parent matching the tracer's "baggage to attach"
+// configuration are recorded as transaction labels.
 func ContextWithTransaction(parent context.Context, t *Transaction) context.Context {
-	return apmcontext.ContextWithTransaction(parent, t)
+	ctx := apmcontext.ContextWithTransaction(parent, t)
+	if t != nil {
+		t.attachBaggage(BaggageFromContext(parent))
+		ctx = t.withProfilerLabels(ctx, parent)
+	}
+	return ctx
 }
 
 // SpanFromContext returns the current Span in context, if any. The span must
@@ -83,6 +97,9 @@ func StartSpan(ctx context.Context, name, spanType string) (*Span, context.Conte
 // If opts.Parent is non-zero, its value will be used in preference to any parent
 // span in ctx.
 //
+// Members of the baggage in ctx matching the tracer's "baggage to attach"
+// configuration are recorded as span labels.
+//
 // StartSpanOptions always returns a non-nil Span. Its End method must be called
 // when the span completes.
 func StartSpanOptions(ctx context.Context, name, spanType string, opts SpanOptions) (*Span, context.Context) {
@@ -98,6 +115,7 @@ func StartSpanOptions(ctx context.Context, name, spanType string, opts SpanOptio
 		span = tx.StartSpanOptions(name, spanType, opts)
 	}
 	if !span.Dropped() {
+		span.attachBaggage(BaggageFromContext(ctx))
 		ctx = ContextWithSpan(ctx, span)
 	}
 	return span, ctx
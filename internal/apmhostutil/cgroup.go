@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmhostutil
+
+// CgroupMetrics holds resource usage and limits of the cgroup
+// containing the process.
+type CgroupMetrics struct {
+	// Memory holds the cgroup memory metrics, or nil if the
+	// memory controller is unavailable.
+	Memory *CgroupMemoryMetrics
+
+	// CPU holds the cgroup CPU metrics, or nil if the
+	// CPU controller is unavailable.
+	CPU *CgroupCPUMetrics
+}
+
+// CgroupMemoryMetrics holds cgroup memory metrics.
+type CgroupMemoryMetrics struct {
+	// Limit holds the memory limit in bytes, or zero
+	// if there is no limit.
+	Limit uint64
+
+	// Usage holds the memory usage in bytes, excluding
+	// inactive file cache, which may be reclaimed.
+	Usage uint64
+}
+
+// CgroupCPUMetrics holds cgroup CPU bandwidth control metrics.
+type CgroupCPUMetrics struct {
+	// QuotaMicros holds the CPU time in microseconds that the cgroup
+	// may use in each period, or -1 if there is no quota.
+	QuotaMicros int64
+
+	// PeriodMicros holds the length of the CPU bandwidth period
+	// in microseconds.
+	PeriodMicros uint64
+
+	// Periods holds the number of periods that have elapsed.
+	Periods uint64
+
+	// ThrottledPeriods holds the number of periods in which
+	// the cgroup was throttled.
+	ThrottledPeriods uint64
+
+	// ThrottledNanos holds the total time in nanoseconds for
+	// which the cgroup was throttled.
+	ThrottledNanos uint64
+}
+
+// NewCgroupReader returns a CgroupReader which reads metrics for the cgroup
+// containing the process, or an error if the cgroup could not be determined.
+//
+// The cgroup is located using proc/self/cgroup and proc/self/mountinfo,
+// relative to root; root is normally "/", and may be changed for testing.
+func NewCgroupReader(root string) (*CgroupReader, error) {
+	return newCgroupReader(root)
+}
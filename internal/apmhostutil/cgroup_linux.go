@@ -0,0 +1,359 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build linux
+
+package apmhostutil
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cgroupV1UnlimitedMemory is the threshold above which cgroup v1
+	// memory limits are considered unlimited. The kernel reports the
+	// maximum page-aligned int64 value when no limit is set.
+	cgroupV1UnlimitedMemory = 1 << 62
+)
+
+// CgroupReader reads metrics for the cgroup containing the process.
+type CgroupReader struct {
+	// memoryDir and cpuDir hold the directories of the memory and
+	// CPU controllers for the process's cgroup, or are empty if the
+	// controllers could not be located. memoryV2 and cpuV2 record
+	// whether the directories belong to the cgroup v2 hierarchy.
+	memoryDir string
+	memoryV2  bool
+	cpuDir    string
+	cpuV2     bool
+}
+
+func newCgroupReader(root string) (*CgroupReader, error) {
+	f, err := os.Open(filepath.Join(root, "proc", "self", "cgroup"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	paths, unifiedPath, err := readProcCgroup(f)
+	if err != nil {
+		return nil, err
+	}
+
+	mf, err := os.Open(filepath.Join(root, "proc", "self", "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer mf.Close()
+	mounts, unifiedMount, err := readCgroupMountinfo(mf)
+	if err != nil {
+		return nil, err
+	}
+
+	var r CgroupReader
+	resolve := func(controller string) (string, bool) {
+		// Prefer cgroup v1 controllers, which take precedence
+		// over the unified hierarchy in "hybrid" mode.
+		if mount, ok := mounts[controller]; ok {
+			if path, ok := paths[controller]; ok {
+				return mount.resolve(root, path), false
+			}
+		}
+		if unifiedMount != nil && unifiedPath != "" {
+			return unifiedMount.resolve(root, unifiedPath), true
+		}
+		return "", false
+	}
+	r.memoryDir, r.memoryV2 = resolve("memory")
+	r.cpuDir, r.cpuV2 = resolve("cpu")
+	if r.memoryDir == "" && r.cpuDir == "" {
+		return nil, errors.New("could not locate cgroup")
+	}
+	return &r, nil
+}
+
+// Read reads the current cgroup metrics.
+func (r *CgroupReader) Read() (CgroupMetrics, error) {
+	var out CgroupMetrics
+	var firstErr error
+	if r.memoryDir != "" {
+		var memory CgroupMemoryMetrics
+		var err error
+		if r.memoryV2 {
+			err = readCgroupV2Memory(r.memoryDir, &memory)
+		} else {
+			err = readCgroupV1Memory(r.memoryDir, &memory)
+		}
+		if err == nil {
+			out.Memory = &memory
+		} else {
+			firstErr = err
+		}
+	}
+	if r.cpuDir != "" {
+		var cpu CgroupCPUMetrics
+		var err error
+		if r.cpuV2 {
+			err = readCgroupV2CPU(r.cpuDir, &cpu)
+		} else {
+			err = readCgroupV1CPU(r.cpuDir, &cpu)
+		}
+		if err == nil {
+			out.CPU = &cpu
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if out.Memory == nil && out.CPU == nil {
+		return out, firstErr
+	}
+	return out, nil
+}
+
+func readCgroupV1Memory(dir string, out *CgroupMemoryMetrics) error {
+	limit, err := readCgroupUint(filepath.Join(dir, "memory.limit_in_bytes"))
+	if err != nil {
+		return err
+	}
+	usage, err := readCgroupUint(filepath.Join(dir, "memory.usage_in_bytes"))
+	if err != nil {
+		return err
+	}
+	stat, err := readCgroupStat(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return err
+	}
+	if limit < cgroupV1UnlimitedMemory {
+		out.Limit = limit
+	}
+	out.Usage = subtractInactiveFile(usage, stat["total_inactive_file"])
+	return nil
+}
+
+func readCgroupV2Memory(dir string, out *CgroupMemoryMetrics) error {
+	max, err := readCgroupString(filepath.Join(dir, "memory.max"))
+	if err != nil {
+		return err
+	}
+	usage, err := readCgroupUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return err
+	}
+	stat, err := readCgroupStat(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return err
+	}
+	if max != "max" {
+		limit, err := strconv.ParseUint(max, 10, 64)
+		if err != nil {
+			return err
+		}
+		out.Limit = limit
+	}
+	out.Usage = subtractInactiveFile(usage, stat["inactive_file"])
+	return nil
+}
+
+func subtractInactiveFile(usage, inactiveFile uint64) uint64 {
+	if inactiveFile > usage {
+		return 0
+	}
+	return usage - inactiveFile
+}
+
+func readCgroupV1CPU(dir string, out *CgroupCPUMetrics) error {
+	quota, err := readCgroupString(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return err
+	}
+	if out.QuotaMicros, err = strconv.ParseInt(quota, 10, 64); err != nil {
+		return err
+	}
+	if out.PeriodMicros, err = readCgroupUint(filepath.Join(dir, "cpu.cfs_period_us")); err != nil {
+		return err
+	}
+	stat, err := readCgroupStat(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	out.Periods = stat["nr_periods"]
+	out.ThrottledPeriods = stat["nr_throttled"]
+	out.ThrottledNanos = stat["throttled_time"]
+	return nil
+}
+
+func readCgroupV2CPU(dir string, out *CgroupCPUMetrics) error {
+	max, err := readCgroupString(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return err
+	}
+	// cpu.max has the format "$MAX $PERIOD", where $MAX
+	// may be "max" to indicate there is no quota.
+	fields := strings.Fields(max)
+	if len(fields) != 2 {
+		return errors.New("invalid cpu.max format")
+	}
+	out.QuotaMicros = -1
+	if fields[0] != "max" {
+		if out.QuotaMicros, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return err
+		}
+	}
+	if out.PeriodMicros, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return err
+	}
+	stat, err := readCgroupStat(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return err
+	}
+	out.Periods = stat["nr_periods"]
+	out.ThrottledPeriods = stat["nr_throttled"]
+	out.ThrottledNanos = stat["throttled_usec"] * 1000
+	return nil
+}
+
+func readCgroupString(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readCgroupUint(path string) (uint64, error) {
+	s, err := readCgroupString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// readCgroupStat reads a flat-keyed cgroup stat file, such as memory.stat
+// or cpu.stat, ignoring lines with unexpected formats.
+func readCgroupStat(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat := make(map[string]uint64)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stat[fields[0]] = v
+		}
+	}
+	return stat, s.Err()
+}
+
+// readProcCgroup reads /proc/<pid>/cgroup, returning the cgroup v1 paths
+// keyed by controller, and the cgroup v2 (unified hierarchy) path.
+func readProcCgroup(r io.Reader) (paths map[string]string, unifiedPath string, err error) {
+	paths = make(map[string]string)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			unifiedPath = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, "", err
+	}
+	return paths, unifiedPath, nil
+}
+
+// cgroupMount describes a cgroup filesystem mount.
+type cgroupMount struct {
+	// root is the path of the cgroup, within the hierarchy,
+	// which is mounted at mountPoint.
+	root       string
+	mountPoint string
+}
+
+// resolve returns the directory, relative to fsRoot, for the
+// cgroup with the given path within the mount's hierarchy.
+func (m *cgroupMount) resolve(fsRoot, cgroupPath string) string {
+	rel := cgroupPath
+	if m.root != "/" {
+		// The cgroup namespace root is mounted, e.g. in a
+		// container without cgroup namespaces; the process's
+		// cgroup path is relative to the hierarchy root.
+		rel = strings.TrimPrefix(cgroupPath, m.root)
+	}
+	return filepath.Join(fsRoot, m.mountPoint, rel)
+}
+
+// readCgroupMountinfo reads /proc/<pid>/mountinfo, returning the cgroup v1
+// mounts keyed by controller, and the cgroup v2 mount if any.
+func readCgroupMountinfo(r io.Reader) (map[string]*cgroupMount, *cgroupMount, error) {
+	mounts := make(map[string]*cgroupMount)
+	var unified *cgroupMount
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// See proc(5) for the mountinfo format:
+		//
+		//   36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		//
+		// Fields 4 and 5 are the mount root and mount point. Following
+		// the optional fields and separator are the filesystem type,
+		// mount source, and super options.
+		line := s.Text()
+		sep := strings.Index(line, " - ")
+		if sep == -1 {
+			continue
+		}
+		fields := strings.Fields(line[:sep])
+		post := strings.Fields(line[sep+3:])
+		if len(fields) < 5 || len(post) < 3 {
+			continue
+		}
+		mount := &cgroupMount{root: fields[3], mountPoint: fields[4]}
+		switch post[0] {
+		case "cgroup2":
+			unified = mount
+		case "cgroup":
+			for _, opt := range strings.Split(post[2], ",") {
+				switch opt {
+				case "memory", "cpu":
+					mounts[opt] = mount
+				}
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	return mounts, unified, nil
+}
@@ -0,0 +1,81 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmhostutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCgroupReaderV1(t *testing.T) {
+	metrics := readCgroupFixture(t, "cgroupv1")
+	assert.Equal(t, &CgroupMemoryMetrics{
+		Limit: 536870912,
+		Usage: 268435456 - 33554432, // usage_in_bytes - total_inactive_file
+	}, metrics.Memory)
+	assert.Equal(t, &CgroupCPUMetrics{
+		QuotaMicros:      150000,
+		PeriodMicros:     100000,
+		Periods:          1000,
+		ThrottledPeriods: 25,
+		ThrottledNanos:   123456789,
+	}, metrics.CPU)
+}
+
+func TestCgroupReaderV2(t *testing.T) {
+	metrics := readCgroupFixture(t, "cgroupv2")
+	assert.Equal(t, &CgroupMemoryMetrics{
+		Limit: 1073741824,
+		Usage: 402653184 - 16777216, // memory.current - inactive_file
+	}, metrics.Memory)
+	assert.Equal(t, &CgroupCPUMetrics{
+		QuotaMicros:      50000,
+		PeriodMicros:     100000,
+		Periods:          500,
+		ThrottledPeriods: 10,
+		ThrottledNanos:   250000000,
+	}, metrics.CPU)
+}
+
+func TestCgroupReaderV2Unlimited(t *testing.T) {
+	metrics := readCgroupFixture(t, "cgroupv2-unlimited")
+	assert.Equal(t, &CgroupMemoryMetrics{
+		Limit: 0,
+		Usage: 104857600 - 10485760,
+	}, metrics.Memory)
+	assert.Equal(t, &CgroupCPUMetrics{
+		QuotaMicros:  -1,
+		PeriodMicros: 100000,
+	}, metrics.CPU)
+}
+
+func TestCgroupReaderNotFound(t *testing.T) {
+	_, err := NewCgroupReader(filepath.Join("testdata", "nonexistent"))
+	assert.Error(t, err)
+}
+
+func readCgroupFixture(t *testing.T, name string) CgroupMetrics {
+	r, err := NewCgroupReader(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	metrics, err := r.Read()
+	require.NoError(t, err)
+	return metrics
+}
@@ -0,0 +1,38 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !linux
+
+package apmhostutil
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// CgroupReader reads metrics for the cgroup containing the process.
+type CgroupReader struct{}
+
+func newCgroupReader(root string) (*CgroupReader, error) {
+	return nil, errors.Errorf("cgroup metrics not implemented for %s", runtime.GOOS)
+}
+
+// Read reads the current cgroup metrics.
+func (r *CgroupReader) Read() (CgroupMetrics, error) {
+	return CgroupMetrics{}, errors.Errorf("cgroup metrics not implemented for %s", runtime.GOOS)
+}
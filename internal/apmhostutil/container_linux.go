@@ -24,7 +24,6 @@ import (
 	"errors"
 	"io"
 	"os"
-	"path"
 	"regexp"
 	"strings"
 	"sync"
@@ -42,11 +41,15 @@ var (
 	kubernetes               *model.Kubernetes
 	container                *model.Container
 
-	kubepodsRegexp = regexp.MustCompile(
-		"" +
-			`(?:^/kubepods/[^/]+/pod([^/]+)/$)|` +
-			`(?:^/kubepods\.slice/kubepods-[^/]+\.slice/kubepods-[^/]+-pod([^/]+)\.slice/$)`,
-	)
+	// kubepodsCgroupfsRegexp matches the pod cgroup path segment
+	// when the cgroupfs driver is used, e.g. "pod<pod-UID>". The
+	// QoS class segment is absent for the Guaranteed QoS class.
+	kubepodsCgroupfsRegexp = regexp.MustCompile(`^pod([[:xdigit:]]{8}[_-][[:xdigit:]_-]+)$`)
+
+	// kubepodsSystemdRegexp matches the pod cgroup path segment
+	// when the systemd driver is used, e.g. "kubepods-<QoS-class>-pod<pod-UID>.slice".
+	// The QoS class is absent for the Guaranteed QoS class.
+	kubepodsSystemdRegexp = regexp.MustCompile(`^(?:[^/]*-)?kubepods(?:-[^/]+)?-pod([[:xdigit:]_]+)\.slice$`)
 
 	containerIDRegexp = regexp.MustCompile(
 		"^" +
@@ -54,10 +57,27 @@ var (
 			"[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4,}" +
 			"$",
 	)
+
+	// mountinfoContainerIDRegexp matches container IDs in the source
+	// paths of the files bind-mounted into containers by Docker and
+	// Podman, such as /etc/hostname.
+	mountinfoContainerIDRegexp = regexp.MustCompile(
+		`/(?:containers|overlay-containers)/([[:xdigit:]]{64})/`,
+	)
+
+	// mountinfoPodUIDRegexp matches Kubernetes pod UIDs in the source
+	// paths of the files bind-mounted into pod containers by the kubelet,
+	// such as /etc/hosts.
+	mountinfoPodUIDRegexp = regexp.MustCompile(
+		`/kubelet/pods/([[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12})/`,
+	)
 )
 
 func containerInfo() (*model.Container, error) {
 	container, _, err := cgroupContainerInfo()
+	if err == nil && container == nil {
+		return nil, errors.New("could not determine container info")
+	}
 	return container, err
 }
 
@@ -78,11 +98,21 @@ func cgroupContainerInfo() (*model.Container, *model.Kubernetes, error) {
 			}
 			defer f.Close()
 
-			c, k, err := readCgroupContainerInfo(f)
+			// With cgroup v2 and cgroup namespaces, /proc/self/cgroup
+			// typically contains only "0::/", so we fall back to
+			// /proc/self/mountinfo. Errors opening mountinfo are
+			// ignored, and the cgroup information used alone.
+			var mountinfo io.Reader
+			if mf, err := os.Open("/proc/self/mountinfo"); err == nil {
+				defer mf.Close()
+				mountinfo = mf
+			}
+
+			c, k, err := readContainerInfo(f, mountinfo)
 			if err != nil {
 				return err
 			}
-			if c == nil {
+			if c == nil && k == nil {
 				return errors.New("could not determine container info")
 			}
 			container = c
@@ -93,6 +123,29 @@ func cgroupContainerInfo() (*model.Container, *model.Kubernetes, error) {
 	return container, kubernetes, cgroupContainerInfoError
 }
 
+// readContainerInfo reads container and Kubernetes information from
+// the contents of /proc/<pid>/cgroup, and /proc/<pid>/mountinfo if
+// the cgroup is not conclusive. mountinfo may be nil.
+func readContainerInfo(cgroup, mountinfo io.Reader) (*model.Container, *model.Kubernetes, error) {
+	container, kubernetes, err := readCgroupContainerInfo(cgroup)
+	if err != nil {
+		return nil, nil, err
+	}
+	if (container == nil || kubernetes == nil) && mountinfo != nil {
+		mountContainer, mountKubernetes, err := readMountinfoContainerInfo(mountinfo)
+		if err != nil {
+			return nil, nil, err
+		}
+		if container == nil {
+			container = mountContainer
+		}
+		if kubernetes == nil {
+			kubernetes = mountKubernetes
+		}
+	}
+	return container, kubernetes, nil
+}
+
 func readCgroupContainerInfo(r io.Reader) (*model.Container, *model.Kubernetes, error) {
 	var container *model.Container
 	var kubernetes *model.Kubernetes
@@ -102,55 +155,167 @@ func readCgroupContainerInfo(r io.Reader) (*model.Container, *model.Kubernetes,
 		if len(fields) != 3 {
 			continue
 		}
-		cgroupPath := fields[2]
-
-		// Depending on the filesystem driver used for cgroup
-		// management, the paths in /proc/pid/cgroup will have
-		// one of the following formats in a Docker container:
-		//
-		//   systemd: /system.slice/docker-<container-ID>.scope
-		//   cgroupfs: /docker/<container-ID>
-		//
-		// In a Kubernetes pod, the cgroup path will look like:
-		//
-		//   systemd: /kubepods.slice/kubepods-<QoS-class>.slice/kubepods-<QoS-class>-pod<pod-UID>.slice/<container-iD>.scope
-		//   cgroupfs: /kubepods/<QoS-class>/pod<pod-UID>/<container-iD>
-		//
-		dir, id := path.Split(cgroupPath)
-		if strings.HasSuffix(id, systemdScopeSuffix) {
-			id = id[:len(id)-len(systemdScopeSuffix)]
-			if dash := strings.IndexRune(id, '-'); dash != -1 {
-				id = id[dash+1:]
-			}
+		if c, k := parseCgroupPath(fields[2]); c != nil {
+			container, kubernetes = c, k
 		}
-		if match := kubepodsRegexp.FindStringSubmatch(dir); match != nil {
-			// By default, Kubernetes will set the hostname of
-			// the pod containers to the pod name. Users that
-			// override the name should use the Downard API to
-			// override the pod name.
-			hostname, _ := os.Hostname()
-			uid := match[1]
-			if uid == "" {
-				// Systemd cgroup driver is being used,
-				// so we need to unescape '_' back to '-'.
-				uid = strings.Replace(match[2], "_", "-", -1)
-			}
-			kubernetes = &model.Kubernetes{
-				Pod: &model.KubernetesPod{
-					Name: hostname,
-					UID:  uid,
-				},
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+	return container, kubernetes, nil
+}
+
+// readMountinfoContainerInfo reads container and Kubernetes information
+// from the contents of /proc/<pid>/mountinfo. The container ID is taken
+// from the path of a cgroup mounted at the root of a container's cgroup
+// hierarchy, or from the source path of a file bind-mounted into the
+// container by the container runtime.
+func readMountinfoContainerInfo(r io.Reader) (*model.Container, *model.Kubernetes, error) {
+	var container *model.Container
+	var kubernetes *model.Kubernetes
+	var podUID string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// See proc(5) for the mountinfo format. Fields 4 and 5
+		// are the mount root and mount point, and the filesystem
+		// type follows the " - " separator.
+		line := s.Text()
+		sep := strings.Index(line, " - ")
+		if sep == -1 {
+			continue
+		}
+		fields := strings.Fields(line[:sep])
+		post := strings.Fields(line[sep+3:])
+		if len(fields) < 5 || len(post) < 1 {
+			continue
+		}
+		root, mountPoint := fields[3], fields[4]
+		switch post[0] {
+		case "cgroup", "cgroup2":
+			if c, k := parseCgroupPath(root); c != nil {
+				if container == nil || k != nil {
+					container, kubernetes = c, k
+				}
 			}
-			// We don't check the contents of the last path segment
-			// when we've matched "^/kubepods"; we assume that it is
-			// a valid container ID.
-			container = &model.Container{ID: id}
-		} else if containerIDRegexp.MatchString(id) {
-			container = &model.Container{ID: id}
+			continue
+		}
+		switch mountPoint {
+		case "/etc/hostname", "/etc/hosts", "/etc/resolv.conf":
+		default:
+			continue
+		}
+		if match := mountinfoContainerIDRegexp.FindStringSubmatch(root); match != nil && container == nil {
+			container = &model.Container{ID: match[1]}
+		}
+		if match := mountinfoPodUIDRegexp.FindStringSubmatch(root); match != nil {
+			podUID = match[1]
 		}
 	}
 	if err := s.Err(); err != nil {
 		return nil, nil, err
 	}
+	if kubernetes == nil && podUID != "" {
+		kubernetes = newKubernetesPod(podUID)
+	}
 	return container, kubernetes, nil
 }
+
+// parseCgroupPath parses a cgroup path, returning the container and
+// Kubernetes information encoded in it, if any.
+func parseCgroupPath(cgroupPath string) (*model.Container, *model.Kubernetes) {
+	// Depending on the filesystem driver used for cgroup
+	// management, the paths in /proc/pid/cgroup will have
+	// one of the following formats in a Docker container:
+	//
+	//   systemd: /system.slice/docker-<container-ID>.scope
+	//   cgroupfs: /docker/<container-ID>
+	//
+	// Other container runtimes use similar formats, e.g.
+	//
+	//   containerd: /system.slice/containerd.service/<pod-slice>:cri-containerd:<container-ID>
+	//   podman: /machine.slice/libpod-<container-ID>.scope[/container]
+	//   podman (cgroupfs): /libpod_parent/libpod-<container-ID>
+	//
+	// In a Kubernetes pod, the cgroup path will look like:
+	//
+	//   systemd: /kubepods.slice/kubepods-<QoS-class>.slice/kubepods-<QoS-class>-pod<pod-UID>.slice/<container-iD>.scope
+	//   cgroupfs: /kubepods/<QoS-class>/pod<pod-UID>/<container-iD>
+	//
+	// The QoS class segments are omitted for pods with the Guaranteed
+	// QoS class, and the paths may be nested, e.g. under kubelet.slice.
+	cgroupPath = strings.TrimSuffix(cgroupPath, "/")
+	segments := strings.Split(cgroupPath, "/")
+	last := len(segments) - 1
+	if last > 0 && segments[last] == "container" && strings.HasSuffix(segments[last-1], systemdScopeSuffix) {
+		// Podman with cgroup v2 places the container
+		// process in a "container" sub-cgroup.
+		last--
+	}
+	id := segments[last]
+
+	var podUID string
+	for _, segment := range segments[:last] {
+		if match := kubepodsSystemdRegexp.FindStringSubmatch(segment); match != nil {
+			// Systemd cgroup driver is being used,
+			// so we need to unescape '_' back to '-'.
+			podUID = strings.Replace(match[1], "_", "-", -1)
+		} else if match := kubepodsCgroupfsRegexp.FindStringSubmatch(segment); match != nil {
+			podUID = match[1]
+		}
+	}
+	if i := strings.LastIndex(id, ":"); i != -1 {
+		// e.g. "kubepods-besteffort-pod<pod-UID>.slice:cri-containerd:<container-ID>"
+		if match := kubepodsSystemdRegexp.FindStringSubmatch(id[:strings.IndexRune(id, ':')]); match != nil {
+			podUID = strings.Replace(match[1], "_", "-", -1)
+		}
+		id = id[i+1:]
+	}
+	id = parseContainerID(id)
+
+	if podUID != "" && strings.Contains(cgroupPath, "kubepods") {
+		if id == "" {
+			return nil, nil
+		}
+		// We don't check the contents of the last path segment
+		// when we've matched a pod cgroup; we assume that it is
+		// a valid container ID.
+		return &model.Container{ID: id}, newKubernetesPod(podUID)
+	}
+	if containerIDRegexp.MatchString(id) {
+		return &model.Container{ID: id}, nil
+	}
+	return nil, nil
+}
+
+// parseContainerID returns the container ID from the final segment of
+// a cgroup path, removing any systemd scope suffix and runtime prefix,
+// e.g. "docker-<container-ID>.scope" or "cri-containerd-<container-ID>.scope".
+func parseContainerID(id string) string {
+	if !strings.HasSuffix(id, systemdScopeSuffix) && !strings.HasPrefix(id, "libpod-") {
+		return id
+	}
+	id = strings.TrimSuffix(id, systemdScopeSuffix)
+	if dash := strings.LastIndex(id, "-"); dash != -1 {
+		if suffix := id[dash+1:]; containerIDRegexp.MatchString(suffix) {
+			return suffix
+		}
+	}
+	if dash := strings.IndexRune(id, '-'); dash != -1 {
+		id = id[dash+1:]
+	}
+	return id
+}
+
+func newKubernetesPod(uid string) *model.Kubernetes {
+	// By default, Kubernetes will set the hostname of
+	// the pod containers to the pod name. Users that
+	// override the name should use the Downard API to
+	// override the pod name.
+	hostname, _ := os.Hostname()
+	return &model.Kubernetes{
+		Pod: &model.KubernetesPod{
+			Name: hostname,
+			UID:  uid,
+		},
+	}
+}
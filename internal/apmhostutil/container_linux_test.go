@@ -144,3 +144,125 @@ func TestCgroupContainerInfoKubernetesSystemd(t *testing.T) {
 		},
 	}, kubernetes)
 }
+
+func TestCgroupContainerInfoKubernetesQoSClasses(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	const (
+		podUID      = "e9b90526-f47d-11e8-b2a5-080027b9f4fb"
+		containerID = "2227daf62df6694645fee5df53c1f91271546a9560e8600a525690ae252b7f63"
+	)
+	for name, cgroup := range map[string]string{
+		"cgroupfs_guaranteed":           "/kubepods/pod" + podUID + "/" + containerID,
+		"cgroupfs_burstable":            "/kubepods/burstable/pod" + podUID + "/" + containerID,
+		"cgroupfs_besteffort":           "/kubepods/besteffort/pod" + podUID + "/" + containerID,
+		"systemd_guaranteed":            "/kubepods.slice/kubepods-pod" + systemdPodUID(podUID) + ".slice/docker-" + containerID + ".scope",
+		"systemd_burstable":             "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + systemdPodUID(podUID) + ".slice/cri-containerd-" + containerID + ".scope",
+		"systemd_besteffort":            "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod" + systemdPodUID(podUID) + ".slice/crio-" + containerID + ".scope",
+		"systemd_kubelet_slice":         "/kubelet.slice/kubelet-kubepods.slice/kubelet-kubepods-besteffort.slice/kubelet-kubepods-besteffort-pod" + systemdPodUID(podUID) + ".slice/cri-containerd-" + containerID + ".scope",
+		"systemd_containerd_service":    "/system.slice/containerd.service/kubepods-burstable-pod" + systemdPodUID(podUID) + ".slice:cri-containerd:" + containerID,
+		"systemd_containerd_guaranteed": "/system.slice/containerd.service/kubepods-pod" + systemdPodUID(podUID) + ".slice:cri-containerd:" + containerID,
+	} {
+		t.Run(name, func(t *testing.T) {
+			container, kubernetes, err := readCgroupContainerInfo(strings.NewReader("0::" + cgroup))
+			assert.NoError(t, err)
+			assert.Equal(t, &model.Container{ID: containerID}, container)
+			assert.Equal(t, &model.Kubernetes{
+				Pod: &model.KubernetesPod{
+					UID:  podUID,
+					Name: hostname,
+				},
+			}, kubernetes)
+		})
+	}
+}
+
+func TestCgroupContainerInfoRuntimes(t *testing.T) {
+	const containerID = "cde7c2bab394630a42d73dc610b9c57415dced996106665d427f6d0566594411"
+	for name, cgroup := range map[string]string{
+		"docker_cgroupv2":       "0::/system.slice/docker-" + containerID + ".scope",
+		"containerd_namespace":  "0::/default/" + containerID,
+		"podman_systemd":        "0::/machine.slice/libpod-" + containerID + ".scope",
+		"podman_systemd_nested": "0::/machine.slice/libpod-" + containerID + ".scope/container",
+		"podman_rootless":       "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + containerID + ".scope",
+		"podman_cgroupfs":       "1:name=systemd:/libpod_parent/libpod-" + containerID,
+	} {
+		t.Run(name, func(t *testing.T) {
+			container, kubernetes, err := readCgroupContainerInfo(strings.NewReader(cgroup))
+			assert.NoError(t, err)
+			assert.Nil(t, kubernetes)
+			assert.Equal(t, &model.Container{ID: containerID}, container)
+		})
+	}
+}
+
+func TestMountinfoContainerInfoDocker(t *testing.T) {
+	// cgroup v2 with a cgroup namespace: /proc/self/cgroup is not
+	// informative, so the container ID comes from the bind mounts.
+	container, kubernetes, err := readContainerInfo(strings.NewReader("0::/\n"), strings.NewReader(`
+1193 1171 0:27 / /sys/fs/cgroup ro,nosuid,nodev,noexec,relatime - cgroup2 cgroup rw,nsdelegate,memory_recursiveprot
+1194 1168 259:2 /var/lib/docker/containers/051e2ee0bce99116029a13df4a9e943137f19f957f38ac02d6bad96f9b700f76/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/nvme0n1p2 rw
+1195 1168 259:2 /var/lib/docker/containers/051e2ee0bce99116029a13df4a9e943137f19f957f38ac02d6bad96f9b700f76/hostname /etc/hostname rw,relatime - ext4 /dev/nvme0n1p2 rw
+1196 1168 259:2 /var/lib/docker/containers/051e2ee0bce99116029a13df4a9e943137f19f957f38ac02d6bad96f9b700f76/hosts /etc/hosts rw,relatime - ext4 /dev/nvme0n1p2 rw
+`[1:]))
+
+	assert.NoError(t, err)
+	assert.Nil(t, kubernetes)
+	assert.Equal(t, &model.Container{ID: "051e2ee0bce99116029a13df4a9e943137f19f957f38ac02d6bad96f9b700f76"}, container)
+}
+
+func TestMountinfoContainerInfoPodman(t *testing.T) {
+	container, kubernetes, err := readContainerInfo(strings.NewReader("0::/\n"), strings.NewReader(`
+1022 1001 0:26 / /sys/fs/cgroup ro,nosuid,nodev,noexec,relatime - cgroup2 cgroup2 rw,seclabel
+1023 1001 0:110 /containers/storage/overlay-containers/7e9139716d9e5d762d22f9f877b87d1be8b1449ac912c025a984750c5dbff157/userdata/hostname /etc/hostname rw,nosuid,nodev,relatime - tmpfs tmpfs rw,seclabel
+`[1:]))
+
+	assert.NoError(t, err)
+	assert.Nil(t, kubernetes)
+	assert.Equal(t, &model.Container{ID: "7e9139716d9e5d762d22f9f877b87d1be8b1449ac912c025a984750c5dbff157"}, container)
+}
+
+func TestMountinfoContainerInfoKubernetesCgroupMount(t *testing.T) {
+	// With cgroup namespaces but without a private cgroup mount,
+	// the cgroup mount root holds the container's cgroup path.
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	container, kubernetes, err := readContainerInfo(strings.NewReader("0::/\n"), strings.NewReader(`
+1037 1036 0:29 /kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod90d81341_92de_11e7_8cf2_507b9d4141fa.slice/cri-containerd-2227daf62df6694645fee5df53c1f91271546a9560e8600a525690ae252b7f63.scope /sys/fs/cgroup ro,nosuid,nodev,noexec,relatime - cgroup2 cgroup rw
+`[1:]))
+
+	assert.NoError(t, err)
+	assert.Equal(t, &model.Container{ID: "2227daf62df6694645fee5df53c1f91271546a9560e8600a525690ae252b7f63"}, container)
+	assert.Equal(t, &model.Kubernetes{
+		Pod: &model.KubernetesPod{
+			UID:  "90d81341-92de-11e7-8cf2-507b9d4141fa",
+			Name: hostname,
+		},
+	}, kubernetes)
+}
+
+func TestMountinfoContainerInfoKubernetesPodUID(t *testing.T) {
+	// containerd mounts /etc/hostname from the pod sandbox, so only
+	// the pod UID can be determined, from the kubelet's /etc/hosts.
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	container, kubernetes, err := readContainerInfo(strings.NewReader("0::/\n"), strings.NewReader(`
+2311 2290 0:31 / /sys/fs/cgroup ro,nosuid,nodev,noexec,relatime - cgroup2 cgroup rw
+2312 2290 259:1 /var/lib/kubelet/pods/e9b90526-f47d-11e8-b2a5-080027b9f4fb/etc-hosts /etc/hosts rw,relatime - ext4 /dev/root rw
+2313 2290 259:1 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/6e2ad3df4b4b5b0f5cd4a3bbf6ec0ee8f0c9f2b6c0ef5e1d4f8f2e5d7c8b9a0f/hostname /etc/hostname rw,relatime - ext4 /dev/root rw
+`[1:]))
+
+	assert.NoError(t, err)
+	assert.Nil(t, container)
+	assert.Equal(t, &model.Kubernetes{
+		Pod: &model.KubernetesPod{
+			UID:  "e9b90526-f47d-11e8-b2a5-080027b9f4fb",
+			Name: hostname,
+		},
+	}, kubernetes)
+}
+
+func systemdPodUID(uid string) string {
+	return strings.Replace(uid, "-", "_", -1)
+}
@@ -24,11 +24,24 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.elastic.co/apm/internal/configutil"
 	"go.elastic.co/fastjson"
 )
 
+const (
+	envLogFile     = "ELASTIC_APM_LOG_FILE"
+	envLogFileSize = "ELASTIC_APM_LOG_FILE_SIZE"
+	envLogFormat   = "ELASTIC_APM_LOG_FORMAT"
+	envLogLevel    = "ELASTIC_APM_LOG_LEVEL"
+
+	// ecsVersion is the version of ECS (Elastic Common Schema)
+	// to which the "json" log format conforms.
+	ecsVersion = "1.6.0"
+)
+
 var (
 	// DefaultLogger is the default Logger to use, if ELASTIC_APM_LOG_* are specified.
 	DefaultLogger Logger
@@ -45,7 +58,7 @@ func init() {
 }
 
 func initDefaultLogger() {
-	fileStr := strings.TrimSpace(os.Getenv("ELASTIC_APM_LOG_FILE"))
+	fileStr := strings.TrimSpace(os.Getenv(envLogFile))
 	if fileStr == "" {
 		return
 	}
@@ -57,62 +70,100 @@ func initDefaultLogger() {
 	case "stderr":
 		logWriter = os.Stderr
 	default:
+		maxSize, err := configutil.ParseSizeEnv(envLogFileSize, 0)
+		if err != nil {
+			log.Printf("%s (disabling log rotation)", err)
+			maxSize = 0
+		}
 		f, err := os.Create(fileStr)
 		if err != nil {
 			log.Printf("failed to create %q: %s (disabling logging)", fileStr, err)
 			return
 		}
-		logWriter = &syncFile{File: f}
+		if maxSize > 0 {
+			logWriter = &rotatingFile{File: f, path: fileStr, maxSize: maxSize.Bytes()}
+		} else {
+			logWriter = &syncFile{File: f}
+		}
 	}
 
-	logLevel := errorLevel
-	if levelStr := strings.TrimSpace(os.Getenv("ELASTIC_APM_LOG_LEVEL")); levelStr != "" {
-		level, err := parseLogLevel(levelStr)
+	logLevel := ErrorLevel
+	if levelStr := strings.TrimSpace(os.Getenv(envLogLevel)); levelStr != "" {
+		level, err := ParseLevel(levelStr)
 		if err != nil {
-			log.Printf("invalid ELASTIC_APM_LOG_LEVEL %q, falling back to %q", levelStr, logLevel)
+			log.Printf("invalid %s %q, falling back to %q", envLogLevel, levelStr, logLevel)
 		} else {
 			logLevel = level
 		}
 	}
-	DefaultLogger = levelLogger{w: logWriter, level: logLevel}
+
+	logFormat := defaultFormat
+	if formatStr := strings.TrimSpace(os.Getenv(envLogFormat)); formatStr != "" {
+		switch strings.ToLower(formatStr) {
+		case "json":
+			logFormat = jsonFormat
+		default:
+			log.Printf("invalid %s %q, falling back to the default format", envLogFormat, formatStr)
+		}
+	}
+	DefaultLogger = newLevelLogger(logWriter, logLevel, logFormat)
 }
 
+// Level is a log level.
+type Level uint32
+
 const (
-	debugLevel logLevel = iota
-	infoLevel
-	warnLevel
-	errorLevel
-	noLevel
-)
+	// DebugLevel is the level for debug messages.
+	DebugLevel Level = iota
+
+	// InfoLevel is the level for informational messages.
+	InfoLevel
+
+	// WarnLevel is the level for warning messages.
+	WarnLevel
 
-type logLevel uint8
+	// ErrorLevel is the level for error messages.
+	ErrorLevel
 
-func (l logLevel) String() string {
+	// OffLevel disables logging.
+	OffLevel
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
 	switch l {
-	case debugLevel:
+	case DebugLevel:
 		return "debug"
-	case infoLevel:
+	case InfoLevel:
 		return "info"
-	case warnLevel:
+	case WarnLevel:
 		return "warn"
-	case errorLevel:
+	case ErrorLevel:
 		return "error"
+	case OffLevel:
+		return "off"
 	}
 	return ""
 }
 
-func parseLogLevel(s string) (logLevel, error) {
+// ParseLevel parses s as a log level. In addition to the names
+// returned by Level.String, the levels used by central config are
+// accepted: "trace" is equivalent to "debug", "warning" to "warn",
+// and "critical" to "error".
+func ParseLevel(s string) (Level, error) {
 	switch strings.ToLower(s) {
-	case "debug":
-		return debugLevel, nil
+	case "trace", "debug":
+		return DebugLevel, nil
 	case "info":
-		return infoLevel, nil
-	case "warn":
-		return warnLevel, nil
-	case "error":
-		return errorLevel, nil
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error", "critical":
+		return ErrorLevel, nil
+	case "off":
+		return OffLevel, nil
 	}
-	return noLevel, fmt.Errorf("invalid log level string %q", s)
+	return OffLevel, fmt.Errorf("invalid log level string %q", s)
 }
 
 // Logger provides methods for logging.
@@ -122,38 +173,86 @@ type Logger interface {
 	Warningf(format string, args ...interface{})
 }
 
+// LevelLogger is a Logger whose level may be changed at runtime.
+type LevelLogger interface {
+	Logger
+
+	// Level returns the minimum level of messages to log.
+	Level() Level
+
+	// SetLevel sets the minimum level of messages to log.
+	SetLevel(Level)
+}
+
+type logFormat int
+
+const (
+	defaultFormat logFormat = iota
+	jsonFormat
+)
+
 type levelLogger struct {
-	w     io.Writer
-	level logLevel
+	w      io.Writer
+	level  uint32 // atomic Level
+	format logFormat
+}
+
+func newLevelLogger(w io.Writer, level Level, format logFormat) *levelLogger {
+	return &levelLogger{w: w, level: uint32(level), format: format}
+}
+
+// Level returns the minimum level of messages to log.
+func (l *levelLogger) Level() Level {
+	return Level(atomic.LoadUint32(&l.level))
+}
+
+// SetLevel sets the minimum level of messages to log.
+func (l *levelLogger) SetLevel(level Level) {
+	atomic.StoreUint32(&l.level, uint32(level))
 }
 
 // Debugf logs a message with log.Printf, with a DEBUG prefix.
-func (l levelLogger) Debugf(format string, args ...interface{}) {
-	l.logf(debugLevel, format, args...)
+func (l *levelLogger) Debugf(format string, args ...interface{}) {
+	l.logf(DebugLevel, format, args...)
 }
 
 // Errorf logs a message with log.Printf, with an ERROR prefix.
-func (l levelLogger) Errorf(format string, args ...interface{}) {
-	l.logf(errorLevel, format, args...)
+func (l *levelLogger) Errorf(format string, args ...interface{}) {
+	l.logf(ErrorLevel, format, args...)
 }
 
 // Warningf logs a message with log.Printf, with a WARNING prefix.
-func (l levelLogger) Warningf(format string, args ...interface{}) {
-	l.logf(warnLevel, format, args...)
+func (l *levelLogger) Warningf(format string, args ...interface{}) {
+	l.logf(WarnLevel, format, args...)
 }
 
-func (l levelLogger) logf(level logLevel, format string, args ...interface{}) {
-	if level < l.level {
+func (l *levelLogger) logf(level Level, format string, args ...interface{}) {
+	if level < l.Level() {
 		return
 	}
 	jw := fastjsonPool.Get().(*fastjson.Writer)
-	jw.RawString(`{"level":"`)
-	jw.RawString(level.String())
-	jw.RawString(`","time":"`)
-	jw.Time(time.Now(), time.RFC3339)
-	jw.RawString(`","message":`)
-	jw.String(fmt.Sprintf(format, args...))
-	jw.RawString("}\n")
+	switch l.format {
+	case jsonFormat:
+		// Log records conform to ECS logging; see
+		// https://github.com/elastic/ecs-logging.
+		jw.RawString(`{"@timestamp":"`)
+		jw.Time(time.Now().UTC(), "2006-01-02T15:04:05.000Z07:00")
+		jw.RawString(`","log.level":"`)
+		jw.RawString(level.String())
+		jw.RawString(`","message":`)
+		jw.String(fmt.Sprintf(format, args...))
+		jw.RawString(`,"ecs.version":"`)
+		jw.RawString(ecsVersion)
+		jw.RawString("\"}\n")
+	default:
+		jw.RawString(`{"level":"`)
+		jw.RawString(level.String())
+		jw.RawString(`","time":"`)
+		jw.Time(time.Now(), time.RFC3339)
+		jw.RawString(`","message":`)
+		jw.String(fmt.Sprintf(format, args...))
+		jw.RawString("}\n")
+	}
 	l.w.Write(jw.Bytes())
 	jw.Reset()
 	fastjsonPool.Put(jw)
@@ -171,3 +270,50 @@ func (f *syncFile) Write(data []byte) (int, error) {
 	defer f.mu.Unlock()
 	return f.File.Write(data)
 }
+
+// rotatingFile is an io.Writer which writes to a file, rotating it when
+// a write would cause it to exceed maxSize bytes. When the file is rotated,
+// it is renamed with the suffix ".1", replacing any existing backup file,
+// and a new file is created.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	size    int64
+	*os.File
+}
+
+// Write writes data to the file with f.mu held, rotating the file first
+// if necessary.
+func (f *rotatingFile) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.File.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		// Continue writing to the existing file.
+		if file, openErr := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0); openErr == nil {
+			f.File = file
+		}
+		return err
+	}
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	f.File = file
+	f.size = 0
+	return nil
+}
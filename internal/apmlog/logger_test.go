@@ -32,6 +32,8 @@ import (
 func init() {
 	os.Unsetenv("ELASTIC_APM_LOG_FILE")
 	os.Unsetenv("ELASTIC_APM_LOG_LEVEL")
+	os.Unsetenv("ELASTIC_APM_LOG_FORMAT")
+	os.Unsetenv("ELASTIC_APM_LOG_FILE_SIZE")
 	DefaultLogger = nil
 }
 
@@ -161,6 +163,106 @@ func TestInitDefaultLoggerLevel(t *testing.T) {
 		string(data))
 }
 
+func TestInitDefaultLoggerJSONFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	DefaultLogger = nil
+	os.Setenv("ELASTIC_APM_LOG_FILE", filepath.Join(dir, "log.json"))
+	os.Setenv("ELASTIC_APM_LOG_FORMAT", "json")
+	defer os.Unsetenv("ELASTIC_APM_LOG_FILE")
+	defer os.Unsetenv("ELASTIC_APM_LOG_FORMAT")
+	initDefaultLogger()
+
+	require.NotNil(t, DefaultLogger)
+	DefaultLogger.Errorf("error %q", "message")
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "log.json"))
+	require.NoError(t, err)
+	assert.Regexp(t,
+		`^{"@timestamp":"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z","log.level":"error","message":"error \\"message\\"","ecs.version":"1.6.0"}\n$`,
+		string(data),
+	)
+}
+
+func TestInitDefaultLoggerInvalidFormat(t *testing.T) {
+	var logbuf bytes.Buffer
+	log.SetOutput(&logbuf)
+
+	DefaultLogger = nil
+	os.Setenv("ELASTIC_APM_LOG_FILE", "stderr")
+	os.Setenv("ELASTIC_APM_LOG_FORMAT", "xml")
+	defer os.Unsetenv("ELASTIC_APM_LOG_FILE")
+	defer os.Unsetenv("ELASTIC_APM_LOG_FORMAT")
+	initDefaultLogger()
+
+	require.NotNil(t, DefaultLogger)
+	assert.Regexp(t, `invalid ELASTIC_APM_LOG_FORMAT "xml", falling back to the default format`, logbuf.String())
+}
+
+func TestInitDefaultLoggerRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	DefaultLogger = nil
+	os.Setenv("ELASTIC_APM_LOG_FILE", filepath.Join(dir, "log.json"))
+	os.Setenv("ELASTIC_APM_LOG_FILE_SIZE", "100b")
+	defer os.Unsetenv("ELASTIC_APM_LOG_FILE")
+	defer os.Unsetenv("ELASTIC_APM_LOG_FILE_SIZE")
+	initDefaultLogger()
+
+	require.NotNil(t, DefaultLogger)
+	DefaultLogger.Errorf("first")
+	DefaultLogger.Errorf("second")
+	DefaultLogger.Errorf("third")
+
+	backup, err := ioutil.ReadFile(filepath.Join(dir, "log.json.1"))
+	require.NoError(t, err)
+	assert.Regexp(t, `^{"level":"error","time":".*","message":"second"}\n$`, string(backup))
+
+	current, err := ioutil.ReadFile(filepath.Join(dir, "log.json"))
+	require.NoError(t, err)
+	assert.Regexp(t, `^{"level":"error","time":".*","message":"third"}\n$`, string(current))
+}
+
+func TestLevelLoggerSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLevelLogger(&buf, ErrorLevel, defaultFormat)
+	logger.Debugf("before")
+	assert.Equal(t, ErrorLevel, logger.Level())
+
+	logger.SetLevel(DebugLevel)
+	assert.Equal(t, DebugLevel, logger.Level())
+	logger.Debugf("after")
+	assert.Regexp(t, `^{"level":"debug","time":".*","message":"after"}\n$`, buf.String())
+
+	buf.Reset()
+	logger.SetLevel(OffLevel)
+	logger.Errorf("off")
+	assert.Empty(t, buf.String())
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, expect := range map[string]Level{
+		"trace":    DebugLevel,
+		"DEBUG":    DebugLevel,
+		"info":     InfoLevel,
+		"warn":     WarnLevel,
+		"warning":  WarnLevel,
+		"error":    ErrorLevel,
+		"critical": ErrorLevel,
+		"off":      OffLevel,
+	} {
+		level, err := ParseLevel(in)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, level, in)
+	}
+	_, err := ParseLevel("panic")
+	assert.EqualError(t, err, `invalid log level string "panic"`)
+}
+
 func BenchmarkDefaultLogger(b *testing.B) {
 	dir, err := ioutil.TempDir("", "")
 	require.NoError(b, err)
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmmessaging
+
+import "strings"
+
+// Headers provides access to message headers, or attributes,
+// whose keys are case-sensitive.
+type Headers interface {
+	// Keys calls f with the key of each header.
+	Keys(f func(key string))
+
+	// Values returns the values of the header with the given key.
+	Values(key string) []string
+
+	// Set sets the value of the header with the given key.
+	Set(key, value string)
+
+	// Del deletes the header with the given key.
+	Del(key string)
+}
+
+// Carrier is an apm.PropagationCarrier backed by message headers.
+//
+// Message header keys are case-sensitive, so keys are matched
+// case-insensitively, and set in lower case.
+type Carrier struct {
+	Headers Headers
+}
+
+// Get returns the values of the header with the given key.
+func (c Carrier) Get(key string) []string {
+	if values := c.Headers.Values(key); len(values) > 0 {
+		return values
+	}
+	var values []string
+	c.Headers.Keys(func(k string) {
+		if values == nil && strings.EqualFold(k, key) {
+			values = c.Headers.Values(k)
+		}
+	})
+	return values
+}
+
+// Set sets the value of the header with the given key,
+// removing any existing headers with the same key.
+func (c Carrier) Set(key, value string) {
+	var keys []string
+	c.Headers.Keys(func(k string) {
+		if strings.EqualFold(k, key) {
+			keys = append(keys, k)
+		}
+	})
+	for _, k := range keys {
+		c.Headers.Del(k)
+	}
+	c.Headers.Set(strings.ToLower(key), value)
+}
+
+// StringMap is Headers backed by a map of strings.
+type StringMap map[string]string
+
+// Keys calls f with each key in m.
+func (m StringMap) Keys(f func(key string)) {
+	for k := range m {
+		f(k)
+	}
+}
+
+// Values returns the value for key in m, if any.
+func (m StringMap) Values(key string) []string {
+	if v, ok := m[key]; ok {
+		return []string{v}
+	}
+	return nil
+}
+
+// Set sets the value for key in m.
+func (m StringMap) Set(key, value string) {
+	m[key] = value
+}
+
+// Del deletes key from m.
+func (m StringMap) Del(key string) {
+	delete(m, key)
+}
@@ -0,0 +1,46 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmmessaging_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+func TestCarrierGet(t *testing.T) {
+	carrier := apmmessaging.Carrier{Headers: apmmessaging.StringMap{
+		"traceparent": "exact",
+		"Tracestate":  "folded",
+	}}
+	assert.Equal(t, []string{"exact"}, carrier.Get("traceparent"))
+	assert.Equal(t, []string{"folded"}, carrier.Get("tracestate"))
+	assert.Nil(t, carrier.Get("baggage"))
+}
+
+func TestCarrierSet(t *testing.T) {
+	m := apmmessaging.StringMap{
+		"Traceparent": "a",
+		"TRACEPARENT": "b",
+		"other":       "c",
+	}
+	apmmessaging.Carrier{Headers: m}.Set("TraceParent", "d")
+	assert.Equal(t, apmmessaging.StringMap{"traceparent": "d", "other": "c"}, m)
+}
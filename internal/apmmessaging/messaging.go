@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package apmmessaging provides functionality shared by the
+// messaging instrumentation modules.
+package apmmessaging
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.elastic.co/apm"
+)
+
+// StartSpan starts a span within tx for sending a message to dest, and
+// injects the resulting trace context and any baggage in ctx into carrier,
+// if non-nil. The span is named "<system> <ACTION> to <dest>", and subtype
+// is used for the span subtype and destination service name.
+//
+// If the transaction is unsampled, StartSpan returns nil and the
+// transaction's trace context is injected. Likewise if the span is
+// dropped, though the dropped span is returned.
+func StartSpan(
+	ctx context.Context,
+	tx *apm.Transaction,
+	system, subtype, action, dest string,
+	carrier apm.PropagationCarrier,
+) *apm.Span {
+	traceContext := tx.TraceContext()
+	var span *apm.Span
+	if traceContext.Options.Recorded() {
+		span = tx.StartSpan(
+			system+" "+action+" to "+dest,
+			"messaging."+subtype+"."+strings.ToLower(action),
+			apm.SpanFromContext(ctx),
+		)
+		if !span.Dropped() {
+			traceContext = span.TraceContext()
+			span.Context.SetDestinationService(apm.DestinationServiceSpanContext{
+				Name:     subtype,
+				Resource: subtype + "/" + dest,
+			})
+			span.Context.SetMessage(apm.MessageContext{QueueName: dest})
+		}
+	}
+	if carrier != nil {
+		tx.Propagator().Inject(traceContext, carrier)
+		apm.InjectBaggage(apm.BaggageFromContext(ctx), carrier)
+	}
+	return span
+}
+
+// EndSpan sets the outcome of span according to err, and ends it.
+// If span is nil, EndSpan does nothing.
+func EndSpan(span *apm.Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.Outcome = "failure"
+	} else {
+		span.Outcome = "success"
+	}
+	span.End()
+}
+
+// StartTransaction starts a "messaging" transaction named
+// "<system> RECEIVE from <name>" at the given start time, continuing
+// the trace propagated through carrier, if any. The transaction and
+// any propagated baggage are added to the returned context.
+func StartTransaction(
+	ctx context.Context,
+	tracer *apm.Tracer,
+	system, name string,
+	start time.Time,
+	carrier apm.PropagationCarrier,
+) (*apm.Transaction, context.Context) {
+	txOpts := apm.TransactionOptions{Start: start}
+	if traceContext, err := tracer.Propagator().Extract(carrier); err == nil {
+		txOpts.TraceContext = traceContext
+	}
+	tx := tracer.StartTransactionOptions(system+" RECEIVE from "+name, "messaging", txOpts)
+	if baggage := apm.ExtractBaggage(carrier); baggage.Len() > 0 {
+		ctx = apm.ContextWithBaggage(ctx, baggage)
+	}
+	return tx, apm.ContextWithTransaction(ctx, tx)
+}
+
+// Process calls f, setting the outcome of tx according to whether
+// or not f panics. If f panics, the panic is reported as an error
+// using tracer, and the panic continues.
+func Process(tracer *apm.Tracer, tx *apm.Transaction, f func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			e := tracer.Recovered(v)
+			e.SetTransaction(tx)
+			e.Send()
+			tx.Outcome = "failure"
+			panic(v)
+		}
+	}()
+	f()
+	tx.Outcome = "success"
+}
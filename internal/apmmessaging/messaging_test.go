@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmmessaging_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/internal/apmmessaging"
+	"go.elastic.co/apm/model"
+)
+
+func TestStartSpanStartTransaction(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	headers := make(apmmessaging.StringMap)
+	carrier := apmmessaging.Carrier{Headers: headers}
+	tx, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		ctx = apm.ContextWithBaggage(ctx, apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme"}))
+		span := apmmessaging.StartSpan(ctx, apm.TransactionFromContext(ctx), "Queue", "queue", "SEND", "orders", carrier)
+		apmmessaging.EndSpan(span, errors.New("boom"))
+	})
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "Queue SEND to orders", span.Name)
+	assert.Equal(t, "messaging", span.Type)
+	assert.Equal(t, "queue", span.Subtype)
+	assert.Equal(t, "send", span.Action)
+	assert.Equal(t, "failure", span.Outcome)
+	assert.Equal(t, &model.DestinationServiceSpanContext{
+		Type:     "messaging",
+		Name:     "queue",
+		Resource: "queue/orders",
+	}, span.Context.Destination.Service)
+	assert.Equal(t, "tenant=acme", headers["baggage"])
+
+	consumerTx, ctx := apmmessaging.StartTransaction(context.Background(), tracer.Tracer, "Queue", "orders", time.Now(), carrier)
+	assert.Equal(t, consumerTx, apm.TransactionFromContext(ctx))
+	value, _ := apm.BaggageFromContext(ctx).Value("tenant")
+	assert.Equal(t, "acme", value)
+	consumerTx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, "Queue RECEIVE from orders", payloads.Transactions[0].Name)
+	assert.Equal(t, "messaging", payloads.Transactions[0].Type)
+	assert.Equal(t, tx.TraceID, payloads.Transactions[0].TraceID)
+	assert.Equal(t, span.ID, payloads.Transactions[0].ParentID)
+}
+
+func TestProcess(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	tx := tracer.StartTransaction("name", "messaging")
+	apmmessaging.Process(tracer.Tracer, tx, func() {})
+	assert.Equal(t, "success", tx.Outcome)
+	tx.End()
+
+	tx = tracer.StartTransaction("name", "messaging")
+	assert.PanicsWithValue(t, "boom", func() {
+		apmmessaging.Process(tracer.Tracer, tx, func() { panic("boom") })
+	})
+	assert.Equal(t, "failure", tx.Outcome)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+	assert.Equal(t, payloads.Transactions[1].ID, payloads.Errors[0].TransactionID)
+}
@@ -35,3 +35,9 @@ for i in "${FILES[@]}"; do
   o=jsonschema/$i
   curl -sf https://raw.githubusercontent.com/elastic/apm-server/${BRANCH}/docs/spec/${i} --compressed -o $o
 done
+
+# Local patches for schema changes not yet available upstream.
+# Remove a patch once the upstream schema includes the change.
+for p in patches/*.patch; do
+  patch -p1 < $p
+done
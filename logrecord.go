@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"sort"
+	"time"
+
+	"go.elastic.co/apm/model"
+)
+
+// LogRecord holds a log record to send to the Elastic APM server
+// with Tracer.SendLog.
+type LogRecord struct {
+	// Timestamp holds the time at which the log record was written.
+	//
+	// If this is zero, the current time will be used.
+	Timestamp time.Time
+
+	// Message holds the message for the log record.
+	Message string
+
+	// Level holds the severity level of the log record.
+	//
+	// This is optional.
+	Level string
+
+	// LoggerName holds the name of the logger used.
+	//
+	// This is optional.
+	LoggerName string
+
+	// TraceID, TransactionID and SpanID hold the IDs of the trace,
+	// transaction and span within which the log record was written.
+	//
+	// These are optional.
+	TraceID       TraceID
+	TransactionID SpanID
+	SpanID        SpanID
+
+	// Fields holds structured fields of the log record, which will
+	// be recorded as labels. Keys are cleaned and values converted
+	// in the same way as for Context.SetLabel.
+	//
+	// This is optional.
+	Fields map[string]interface{}
+}
+
+// SendLog enqueues r for sending to the Elastic APM server.
+//
+// SendLog does not block; if the tracer's event queue is full, the
+// log record will be dropped and counted in TracerStats.LogsDropped.
+// If the tracer is not recording, SendLog does nothing.
+func (t *Tracer) SendLog(r LogRecord) {
+	if !t.Recording() {
+		return
+	}
+	timestamp := r.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	log := &model.LogEvent{
+		Timestamp:     model.Time(timestamp.UTC()),
+		Message:       truncateLongString(r.Message),
+		Level:         truncateString(r.Level),
+		LoggerName:    truncateString(r.LoggerName),
+		TraceID:       model.TraceID(r.TraceID),
+		TransactionID: model.SpanID(r.TransactionID),
+		SpanID:        model.SpanID(r.SpanID),
+	}
+	if len(r.Fields) > 0 {
+		log.Labels = make(model.IfaceMap, 0, len(r.Fields))
+		for k, v := range r.Fields {
+			log.Labels = append(log.Labels, model.IfaceMapItem{
+				Key:   cleanLabelKey(k),
+				Value: makeLabelValue(v),
+			})
+		}
+		sort.Slice(log.Labels, func(i, j int) bool {
+			return log.Labels[i].Key < log.Labels[j].Key
+		})
+	}
+	select {
+	case t.events <- tracerEvent{eventType: logEvent, log: log}:
+	default:
+		// Enqueuing a log record should never block.
+		t.statsMu.Lock()
+		t.stats.LogsDropped++
+		t.statsMu.Unlock()
+	}
+}
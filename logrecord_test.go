@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+)
+
+func TestTracerSendLog(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	tx := tracer.StartTransaction("name", "type")
+	span := tx.StartSpan("name", "type", nil)
+	timestamp := time.Unix(123, 0).UTC()
+	tracer.SendLog(apm.LogRecord{
+		Timestamp:     timestamp,
+		Message:       "hello, world",
+		Level:         "info",
+		LoggerName:    "logger",
+		TraceID:       tx.TraceContext().Trace,
+		TransactionID: tx.TraceContext().Span,
+		SpanID:        span.TraceContext().Span,
+		Fields: map[string]interface{}{
+			"user.name": "jane",
+			"attempt":   2,
+		},
+	})
+	span.End()
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Logs, 1)
+	assert.Equal(t, model.LogEvent{
+		Timestamp:     model.Time(timestamp),
+		Message:       "hello, world",
+		Level:         "info",
+		LoggerName:    "logger",
+		TraceID:       model.TraceID(tx.TraceContext().Trace),
+		TransactionID: model.SpanID(tx.TraceContext().Span),
+		SpanID:        model.SpanID(span.TraceContext().Span),
+		Labels: model.IfaceMap{
+			{Key: "attempt", Value: 2.0},
+			{Key: "user_name", Value: "jane"},
+		},
+	}, payloads.Logs[0])
+	assert.Equal(t, uint64(1), tracer.Stats().LogsSent)
+}
+
+func TestTracerSendLogDefaultTimestamp(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	before := time.Now()
+	tracer.SendLog(apm.LogRecord{Message: "hello"})
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Logs, 1)
+	assert.WithinDuration(t, before, time.Time(payloads.Logs[0].Timestamp), time.Second)
+}
+
+func TestTracerSendLogNotRecording(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	tracer.SetRecording(false)
+	tracer.SendLog(apm.LogRecord{Message: "hello"})
+	tracer.SetRecording(true)
+	tracer.Flush(nil)
+	assert.Empty(t, tracer.Payloads().Logs)
+}
@@ -19,6 +19,7 @@ package apm
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -31,17 +32,49 @@ import (
 type Metrics struct {
 	disabled wildcard.Matchers
 
+	// labelSetsLimit and metricLabelSetsLimit hold the maximum number
+	// of distinct label sets in total, and per metric name. Samples
+	// which would exceed either limit are folded into an overflow
+	// series. A limit of zero or less means there is no limit.
+	labelSetsLimit       int
+	metricLabelSetsLimit int
+
 	mu      sync.Mutex
 	metrics []*model.Metrics
 
+	// labelSets holds the number of distinct non-empty label sets,
+	// excluding the overflow series, and metricLabelSets holds the
+	// same per metric name.
+	labelSets       int
+	metricLabelSets map[string]int
+
+	// overflowed holds the number of samples folded into the
+	// overflow series.
+	overflowed uint64
+
 	// transactionGroupMetrics holds metrics which are scoped to transaction
 	// groups, and are not sorted according to their labels.
 	transactionGroupMetrics []*model.Metrics
 }
 
+var metricsLabelSetsLimitWarning = fmt.Sprintf(`
+The limit on distinct metric label sets has been reached (%s, %s), new label sets will be folded into an overflow series.
+Try to avoid high-cardinality metric labels, such as user IDs.`[1:],
+	envMetricsLabelSetsLimit, envMetricsLabelSetsPerMetricLimit,
+)
+
+// overflowLabels holds the labels for the series into which samples
+// exceeding the label set limits are folded.
+var overflowLabels = []MetricLabel{{Name: "_overflow", Value: "true"}}
+
 func (m *Metrics) reset() {
 	m.metrics = m.metrics[:0]
 	m.transactionGroupMetrics = m.transactionGroupMetrics[:0]
+	m.labelSets = 0
+	m.overflowed = 0
+	for name := range m.metricLabelSets {
+		delete(m.metricLabelSets, name)
+	}
 }
 
 // MetricLabel is a name/value pair for labeling metrics.
@@ -79,6 +112,24 @@ func (m *Metrics) Add(name string, labels []MetricLabel, value float64) {
 	m.addMetric(name, labels, model.Metric{Value: value})
 }
 
+// AddHistogram adds a histogram metric with the given name, labels,
+// bucket values, and bucket counts. The labels are expected to be sorted
+// lexicographically, and values are expected to be sorted in ascending
+// order. Each bucket value must have a corresponding count.
+//
+// Buckets with a zero count may be omitted. AddHistogram does not retain
+// values or counts.
+func (m *Metrics) AddHistogram(name string, labels []MetricLabel, values []float64, counts []uint64) {
+	if len(values) != len(counts) {
+		return
+	}
+	m.addMetric(name, labels, model.Metric{
+		Type:   "histogram",
+		Values: append([]float64(nil), values...),
+		Counts: append([]uint64(nil), counts...),
+	})
+}
+
 func (m *Metrics) addMetric(name string, labels []MetricLabel, metric model.Metric) {
 	if m.disabled.MatchAny(name) {
 		return
@@ -86,38 +137,134 @@ func (m *Metrics) addMetric(name string, labels []MetricLabel, metric model.Metr
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	var metrics *model.Metrics
+	i, found := m.searchLabels(labels)
+	if len(labels) > 0 {
+		var newSeries bool
+		if found {
+			_, ok := m.metrics[i].Samples[name]
+			newSeries = !ok
+		} else {
+			newSeries = true
+		}
+		if newSeries {
+			if m.labelSetsLimitReached(name, !found) {
+				m.addOverflowSample(name, metric)
+				return
+			}
+			if !found {
+				m.labelSets++
+			}
+			if m.metricLabelSets == nil {
+				m.metricLabelSets = make(map[string]int)
+			}
+			m.metricLabelSets[name]++
+		}
+	}
+	m.insertMetrics(i, found, labels).Samples[name] = metric
+}
+
+// searchLabels returns the index of the metrics with the given labels,
+// or the index at which they should be inserted, and whether or not
+// they were found.
+//
+// This must be called with m.mu held.
+func (m *Metrics) searchLabels(labels []MetricLabel) (int, bool) {
 	results := make([]int, len(m.metrics))
 	i := sort.Search(len(m.metrics), func(j int) bool {
 		results[j] = compareLabels(m.metrics[j].Labels, labels)
 		return results[j] >= 0
 	})
-	if i < len(results) && results[i] == 0 {
-		// labels are equal
-		metrics = m.metrics[i]
-	} else {
-		var modelLabels model.StringMap
-		if len(labels) > 0 {
-			modelLabels = make(model.StringMap, len(labels))
-			for i, l := range labels {
-				modelLabels[i] = model.StringMapItem{
-					Key: l.Name, Value: l.Value,
-				}
+	return i, i < len(results) && results[i] == 0
+}
+
+// insertMetrics returns the metrics at index i if found is true, and
+// otherwise inserts and returns new metrics with the given labels.
+//
+// This must be called with m.mu held.
+func (m *Metrics) insertMetrics(i int, found bool, labels []MetricLabel) *model.Metrics {
+	if found {
+		return m.metrics[i]
+	}
+	var modelLabels model.StringMap
+	if len(labels) > 0 {
+		modelLabels = make(model.StringMap, len(labels))
+		for i, l := range labels {
+			modelLabels[i] = model.StringMapItem{
+				Key: l.Name, Value: l.Value,
 			}
 		}
-		metrics = &model.Metrics{
-			Labels:  modelLabels,
-			Samples: make(map[string]model.Metric),
-		}
-		if i == len(results) {
-			m.metrics = append(m.metrics, metrics)
-		} else {
-			m.metrics = append(m.metrics, nil)
-			copy(m.metrics[i+1:], m.metrics[i:])
-			m.metrics[i] = metrics
+	}
+	metrics := &model.Metrics{
+		Labels:  modelLabels,
+		Samples: make(map[string]model.Metric),
+	}
+	if i == len(m.metrics) {
+		m.metrics = append(m.metrics, metrics)
+	} else {
+		m.metrics = append(m.metrics, nil)
+		copy(m.metrics[i+1:], m.metrics[i:])
+		m.metrics[i] = metrics
+	}
+	return metrics
+}
+
+// labelSetsLimitReached reports whether adding a new series for the named
+// metric would exceed the label set limits. If newLabelSet is true, then
+// the series would also introduce a new label set.
+//
+// This must be called with m.mu held.
+func (m *Metrics) labelSetsLimitReached(name string, newLabelSet bool) bool {
+	if newLabelSet && m.labelSetsLimit > 0 && m.labelSets >= m.labelSetsLimit {
+		return true
+	}
+	return m.metricLabelSetsLimit > 0 && m.metricLabelSets[name] >= m.metricLabelSetsLimit
+}
+
+// addOverflowSample folds metric into the overflow series. Single-value
+// samples are summed, and histogram samples have their buckets merged.
+//
+// This must be called with m.mu held.
+func (m *Metrics) addOverflowSample(name string, metric model.Metric) {
+	m.overflowed++
+	i, found := m.searchLabels(overflowLabels)
+	overflow := m.insertMetrics(i, found, overflowLabels).Samples
+	existing, ok := overflow[name]
+	switch {
+	case !ok:
+	case metric.Type == "histogram" && existing.Type == "histogram":
+		metric.Values, metric.Counts = mergeHistograms(
+			existing.Values, existing.Counts,
+			metric.Values, metric.Counts,
+		)
+	default:
+		metric.Value += existing.Value
+	}
+	overflow[name] = metric
+}
+
+// mergeHistograms merges two histograms with sorted bucket values,
+// summing the counts of buckets with equal values.
+func mergeHistograms(values1 []float64, counts1 []uint64, values2 []float64, counts2 []uint64) ([]float64, []uint64) {
+	values := make([]float64, 0, len(values1)+len(values2))
+	counts := make([]uint64, 0, len(counts1)+len(counts2))
+	for len(values1) > 0 || len(values2) > 0 {
+		switch {
+		case len(values2) == 0 || (len(values1) > 0 && values1[0] < values2[0]):
+			values = append(values, values1[0])
+			counts = append(counts, counts1[0])
+			values1, counts1 = values1[1:], counts1[1:]
+		case len(values1) == 0 || values2[0] < values1[0]:
+			values = append(values, values2[0])
+			counts = append(counts, counts2[0])
+			values2, counts2 = values2[1:], counts2[1:]
+		default:
+			values = append(values, values1[0])
+			counts = append(counts, counts1[0]+counts2[0])
+			values1, counts1 = values1[1:], counts1[1:]
+			values2, counts2 = values2[1:], counts2[1:]
 		}
 	}
-	metrics.Samples[name] = metric
+	return values, counts
 }
 
 func compareLabels(a model.StringMap, b []MetricLabel) int {
@@ -98,8 +98,29 @@ func TestTracerMetricsBuiltin(t *testing.T) {
 		"system.process.memory.rss.bytes",
 	}
 	sort.Strings(expected)
+
+	// runtime/metrics based metrics depend on the Go version.
+	optional := []string{
+		"golang.heap.gc.pauses.seconds",
+		"golang.sched.latencies.seconds",
+		"golang.sched.goroutines.created",
+		"golang.sched.goroutines.runnable",
+		"golang.sched.goroutines.running",
+		"golang.sched.goroutines.waiting",
+		"golang.sched.goroutines.not_in_go",
+		"golang.sync.mutex.wait.total.seconds",
+
+		// cgroup metrics depend on the environment.
+		"system.process.cgroup.memory.mem.limit.bytes",
+		"system.process.cgroup.memory.mem.usage.bytes",
+		"system.process.cgroup.cpu.cfs.quota.us",
+		"system.process.cgroup.cpu.cfs.period.us",
+		"system.process.cgroup.cpu.stats.periods",
+		"system.process.cgroup.cpu.stats.throttled.periods",
+		"system.process.cgroup.cpu.stats.throttled.ns",
+	}
 	for name := range builtinMetrics.Samples {
-		assert.Contains(t, expected, name)
+		assert.Contains(t, append(expected, optional...), name)
 	}
 
 	var buf bytes.Buffer
@@ -164,6 +185,98 @@ func TestTracerMetricsGatherer(t *testing.T) {
 	assert.Equal(t, map[string]model.Metric{"http.request": {Value: 3}}, metrics2.Samples)
 }
 
+func TestTracerMetricsGathererHistogram(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	values := []float64{0.5, 1.5, 2.5}
+	counts := []uint64{1, 2, 3}
+	tracer.RegisterMetricsGatherer(apm.GatherMetricsFunc(
+		func(ctx context.Context, m *apm.Metrics) error {
+			m.AddHistogram("latency", []apm.MetricLabel{{Name: "path", Value: "/"}}, values, counts)
+			m.AddHistogram("invalid", nil, values, counts[:1])
+			return nil
+		},
+	))
+	tracer.SendMetrics(nil)
+
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Metrics, 2)
+	assert.NotContains(t, payloads.Metrics[0].Samples, "invalid")
+	assert.Equal(t, model.StringMap{{Key: "path", Value: "/"}}, payloads.Metrics[1].Labels)
+	assert.Equal(t, map[string]model.Metric{
+		"latency": {
+			Type:   "histogram",
+			Values: values,
+			Counts: counts,
+		},
+	}, payloads.Metrics[1].Samples)
+}
+
+func TestTracerMetricsLabelSetsLimit(t *testing.T) {
+	os.Setenv("ELASTIC_APM_METRICS_LABEL_SETS_LIMIT", "3")
+	os.Setenv("ELASTIC_APM_METRICS_LABEL_SETS_PER_METRIC_LIMIT", "2")
+	defer os.Unsetenv("ELASTIC_APM_METRICS_LABEL_SETS_LIMIT")
+	defer os.Unsetenv("ELASTIC_APM_METRICS_LABEL_SETS_PER_METRIC_LIMIT")
+
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	var logger apmtest.RecordLogger
+	tracer.SetLogger(&logger)
+
+	label := func(value string) []apm.MetricLabel {
+		return []apm.MetricLabel{{Name: "user", Value: value}}
+	}
+	tracer.RegisterMetricsGatherer(apm.GatherMetricsFunc(
+		func(ctx context.Context, m *apm.Metrics) error {
+			// "requests" is limited to 2 label sets by the per-metric limit.
+			m.Add("requests", label("a"), 1)
+			m.Add("requests", label("b"), 2)
+			m.Add("requests", label("c"), 3)
+			m.Add("requests", label("d"), 4)
+			m.AddHistogram("latency", label("a"), []float64{1, 2}, []uint64{1, 1})
+
+			// The total limit of 3 label sets is reached with "e".
+			m.Add("errors", label("e"), 5)
+			m.Add("errors", label("f"), 6)
+			m.AddHistogram("latency", label("g"), []float64{2, 3}, []uint64{1, 1})
+			m.AddHistogram("latency", label("h"), []float64{3}, []uint64{1})
+			return nil
+		},
+	))
+	for i := 0; i < 2; i++ {
+		tracer.SendMetrics(nil)
+	}
+
+	metrics := transport.Payloads().Metrics
+	require.Len(t, metrics, 10) // builtin, overflow, a, b, e; twice
+	overflow := metrics[1]
+	assert.Equal(t, model.StringMap{{Key: "_overflow", Value: "true"}}, overflow.Labels)
+	assert.Equal(t, map[string]model.Metric{
+		"requests": {Value: 7},
+		"errors":   {Value: 6},
+		"latency": {
+			Type:   "histogram",
+			Values: []float64{2, 3},
+			Counts: []uint64{1, 2},
+		},
+	}, overflow.Samples)
+	for i, value := range []string{"a", "b", "e"} {
+		assert.Equal(t, model.StringMap{{Key: "user", Value: value}}, metrics[i+2].Labels)
+	}
+
+	assert.Equal(t, uint64(10), tracer.Stats().MetricsOverflowed)
+	var warnings []apmtest.LogRecord
+	for _, record := range logger.Records {
+		if record.Level == "warning" {
+			warnings = append(warnings, record)
+		}
+	}
+	require.Len(t, warnings, 1)
+	assert.Regexp(t, "The limit on distinct metric label sets has been reached (.|\n)*", warnings[0].Message)
+}
+
 func TestTracerMetricsDeregister(t *testing.T) {
 	tracer, transport := transporttest.NewRecorderTracer()
 	defer tracer.Close()
@@ -283,7 +396,7 @@ func TestTracerMetricsBuffered(t *testing.T) {
 }
 
 func TestTracerDisableMetrics(t *testing.T) {
-	os.Setenv("ELASTIC_APM_DISABLE_METRICS", "golang.heap.*, system.memory.*, system.process.*")
+	os.Setenv("ELASTIC_APM_DISABLE_METRICS", "golang.heap.*, golang.sched.*, golang.sync.*, system.memory.*, system.process.*")
 	defer os.Unsetenv("ELASTIC_APM_DISABLE_METRICS")
 
 	tracer, transport := transporttest.NewRecorderTracer()
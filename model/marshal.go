@@ -663,6 +663,66 @@ func (s *MetricsSpan) isZero() bool {
 	return *s == MetricsSpan{}
 }
 
+// MarshalFastJSON writes the JSON representation of v to w.
+//
+// Value is omitted for histogram metrics, which are described
+// by Values and Counts.
+func (v *Metric) MarshalFastJSON(w *fastjson.Writer) error {
+	w.RawByte('{')
+	first := true
+	if v.Type != "histogram" {
+		first = false
+		w.RawString("\"value\":")
+		w.Float64(v.Value)
+	}
+	if v.Counts != nil {
+		const prefix = ",\"counts\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		w.RawByte('[')
+		for i, v := range v.Counts {
+			if i != 0 {
+				w.RawByte(',')
+			}
+			w.Uint64(v)
+		}
+		w.RawByte(']')
+	}
+	if v.Type != "" {
+		const prefix = ",\"type\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		w.String(v.Type)
+	}
+	if v.Values != nil {
+		const prefix = ",\"values\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		w.RawByte('[')
+		for i, v := range v.Values {
+			if i != 0 {
+				w.RawByte(',')
+			}
+			w.Float64(v)
+		}
+		w.RawByte(']')
+	}
+	w.RawByte('}')
+	return nil
+}
+
 func writeHex(w *fastjson.Writer, v []byte) {
 	const hextable = "0123456789abcdef"
 	for _, v := range v {
@@ -380,6 +380,29 @@ func (v *Transaction) MarshalFastJSON(w *fastjson.Writer) error {
 			firstErr = err
 		}
 	}
+	if v.FaaS != nil {
+		w.RawString(",\"faas\":")
+		if err := v.FaaS.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if v.Links != nil {
+		w.RawString(",\"links\":")
+		w.RawByte('[')
+		for i, v := range v.Links {
+			if i != 0 {
+				w.RawByte(',')
+			}
+			if err := v.MarshalFastJSON(w); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		w.RawByte(']')
+	}
+	if v.Outcome != "" {
+		w.RawString(",\"outcome\":")
+		w.String(v.Outcome)
+	}
 	if !v.ParentID.isZero() {
 		w.RawString(",\"parent_id\":")
 		if err := v.ParentID.MarshalFastJSON(w); err != nil && firstErr == nil {
@@ -402,6 +425,50 @@ func (v *Transaction) MarshalFastJSON(w *fastjson.Writer) error {
 	return firstErr
 }
 
+func (v *SpanLink) MarshalFastJSON(w *fastjson.Writer) error {
+	var firstErr error
+	w.RawByte('{')
+	w.RawString("\"span_id\":")
+	if err := v.SpanID.MarshalFastJSON(w); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	w.RawString(",\"trace_id\":")
+	if err := v.TraceID.MarshalFastJSON(w); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	w.RawByte('}')
+	return firstErr
+}
+
+func (v *FaaS) MarshalFastJSON(w *fastjson.Writer) error {
+	var firstErr error
+	w.RawByte('{')
+	w.RawString("\"coldstart\":")
+	w.Bool(v.Coldstart)
+	w.RawString(",\"trigger\":")
+	if err := v.Trigger.MarshalFastJSON(w); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if v.Execution != "" {
+		w.RawString(",\"execution\":")
+		w.String(v.Execution)
+	}
+	w.RawByte('}')
+	return firstErr
+}
+
+func (v *FaaSTrigger) MarshalFastJSON(w *fastjson.Writer) error {
+	w.RawByte('{')
+	w.RawString("\"type\":")
+	w.String(v.Type)
+	if v.RequestID != "" {
+		w.RawString(",\"request_id\":")
+		w.String(v.RequestID)
+	}
+	w.RawByte('}')
+	return nil
+}
+
 func (v *SpanCount) MarshalFastJSON(w *fastjson.Writer) error {
 	w.RawByte('{')
 	w.RawString("\"dropped\":")
@@ -443,6 +510,10 @@ func (v *Span) MarshalFastJSON(w *fastjson.Writer) error {
 			firstErr = err
 		}
 	}
+	if v.Outcome != "" {
+		w.RawString(",\"outcome\":")
+		w.String(v.Outcome)
+	}
 	if !v.ParentID.isZero() {
 		w.RawString(",\"parent_id\":")
 		if err := v.ParentID.MarshalFastJSON(w); err != nil && firstErr == nil {
@@ -520,6 +591,18 @@ func (v *SpanContext) MarshalFastJSON(w *fastjson.Writer) error {
 			firstErr = err
 		}
 	}
+	if v.Message != nil {
+		const prefix = ",\"message\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		if err := v.Message.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 	if !v.Tags.isZero() {
 		const prefix = ",\"tags\":"
 		if first {
@@ -670,6 +753,54 @@ func (v *DatabaseSpanContext) MarshalFastJSON(w *fastjson.Writer) error {
 	return nil
 }
 
+func (v *MessageContext) MarshalFastJSON(w *fastjson.Writer) error {
+	var firstErr error
+	w.RawByte('{')
+	first := true
+	if v.Age != nil {
+		const prefix = ",\"age\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		if err := v.Age.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if v.Queue != nil {
+		const prefix = ",\"queue\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		if err := v.Queue.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.RawByte('}')
+	return firstErr
+}
+
+func (v *MessageQueueContext) MarshalFastJSON(w *fastjson.Writer) error {
+	w.RawByte('{')
+	w.RawString("\"name\":")
+	w.String(v.Name)
+	w.RawByte('}')
+	return nil
+}
+
+func (v *MessageAgeContext) MarshalFastJSON(w *fastjson.Writer) error {
+	w.RawByte('{')
+	w.RawString("\"ms\":")
+	w.Int64(v.Milliseconds)
+	w.RawByte('}')
+	return nil
+}
+
 func (v *Context) MarshalFastJSON(w *fastjson.Writer) error {
 	var firstErr error
 	w.RawByte('{')
@@ -686,6 +817,18 @@ func (v *Context) MarshalFastJSON(w *fastjson.Writer) error {
 			firstErr = err
 		}
 	}
+	if v.Message != nil {
+		const prefix = ",\"message\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		if err := v.Message.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 	if v.Request != nil {
 		const prefix = ",\"request\":"
 		if first {
@@ -1057,6 +1200,51 @@ func (v *Log) MarshalFastJSON(w *fastjson.Writer) error {
 	return firstErr
 }
 
+func (v *LogEvent) MarshalFastJSON(w *fastjson.Writer) error {
+	var firstErr error
+	w.RawByte('{')
+	w.RawString("\"@timestamp\":")
+	if err := v.Timestamp.MarshalFastJSON(w); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	w.RawString(",\"message\":")
+	w.String(v.Message)
+	if !v.Labels.isZero() {
+		w.RawString(",\"labels\":")
+		if err := v.Labels.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if v.Level != "" {
+		w.RawString(",\"log.level\":")
+		w.String(v.Level)
+	}
+	if v.LoggerName != "" {
+		w.RawString(",\"log.logger\":")
+		w.String(v.LoggerName)
+	}
+	if !v.SpanID.isZero() {
+		w.RawString(",\"span.id\":")
+		if err := v.SpanID.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if !v.TraceID.isZero() {
+		w.RawString(",\"trace.id\":")
+		if err := v.TraceID.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if !v.TransactionID.isZero() {
+		w.RawString(",\"transaction.id\":")
+		if err := v.TransactionID.MarshalFastJSON(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.RawByte('}')
+	return firstErr
+}
+
 func (v *Request) MarshalFastJSON(w *fastjson.Writer) error {
 	var firstErr error
 	w.RawByte('{')
@@ -1257,6 +1445,26 @@ func (v *MetricsTransaction) MarshalFastJSON(w *fastjson.Writer) error {
 		}
 		w.String(v.Name)
 	}
+	if v.Outcome != "" {
+		const prefix = ",\"outcome\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		w.String(v.Outcome)
+	}
+	if v.Result != "" {
+		const prefix = ",\"result\":"
+		if first {
+			first = false
+			w.RawString(prefix[1:])
+		} else {
+			w.RawString(prefix)
+		}
+		w.String(v.Result)
+	}
 	if v.Type != "" {
 		const prefix = ",\"type\":"
 		if first {
@@ -1297,11 +1505,3 @@ func (v *MetricsSpan) MarshalFastJSON(w *fastjson.Writer) error {
 	w.RawByte('}')
 	return nil
 }
-
-func (v *Metric) MarshalFastJSON(w *fastjson.Writer) error {
-	w.RawByte('{')
-	w.RawString("\"value\":")
-	w.Float64(v.Value)
-	w.RawByte('}')
-	return nil
-}
@@ -108,6 +108,61 @@ func TestMarshalTransaction(t *testing.T) {
 	assert.Equal(t, expect, decoded)
 }
 
+func TestMarshalTransactionFaaS(t *testing.T) {
+	tx := fakeTransaction()
+	tx.Context = nil
+	tx.FaaS = &model.FaaS{
+		Coldstart: false,
+		Execution: "af9aa4-a6b5-4e31-b2cc-8b3d1cd2e6c0",
+		Trigger: model.FaaSTrigger{
+			Type:      "http",
+			RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+		},
+	}
+
+	var w fastjson.Writer
+	tx.MarshalFastJSON(&w)
+
+	decoded := mustUnmarshalJSON(w)
+	assert.Equal(t, map[string]interface{}{
+		"coldstart": false,
+		"execution": "af9aa4-a6b5-4e31-b2cc-8b3d1cd2e6c0",
+		"trigger": map[string]interface{}{
+			"type":       "http",
+			"request_id": "c6af9ac6-7b61-11e6-9a41-93e8deadbeef",
+		},
+	}, decoded.(map[string]interface{})["faas"])
+}
+
+func TestMarshalTransactionLinksMessage(t *testing.T) {
+	tx := fakeTransaction()
+	tx.Context = &model.Context{
+		Message: &model.MessageContext{
+			Queue: &model.MessageQueueContext{Name: "orders"},
+			Age:   &model.MessageAgeContext{Milliseconds: 123},
+		},
+	}
+	tx.Links = []model.SpanLink{{
+		TraceID: model.TraceID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		SpanID:  model.SpanID{0, 1, 2, 3, 4, 5, 6, 7},
+	}}
+
+	var w fastjson.Writer
+	tx.MarshalFastJSON(&w)
+
+	decoded := mustUnmarshalJSON(w).(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"message": map[string]interface{}{
+			"queue": map[string]interface{}{"name": "orders"},
+			"age":   map[string]interface{}{"ms": float64(123)},
+		},
+	}, decoded["context"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"trace_id": "000102030405060708090a0b0c0d0e0f",
+		"span_id":  "0001020304050607",
+	}}, decoded["links"])
+}
+
 func TestMarshalSpan(t *testing.T) {
 	var w fastjson.Writer
 	span := fakeSpan()
@@ -209,6 +264,47 @@ func TestMarshalMetrics(t *testing.T) {
 	assert.Equal(t, expect, decoded)
 }
 
+func TestMarshalMetricsHistogram(t *testing.T) {
+	metrics := model.Metrics{
+		Timestamp: model.Time(time.Unix(123, 0).UTC()),
+		Transaction: model.MetricsTransaction{
+			Type:    "request",
+			Name:    "GET /",
+			Result:  "HTTP 2xx",
+			Outcome: "success",
+		},
+		Samples: map[string]model.Metric{
+			"histogram": {
+				Type:   "histogram",
+				Values: []float64{0.5, 1.5},
+				Counts: []uint64{3, 7},
+			},
+		},
+	}
+
+	var w fastjson.Writer
+	metrics.MarshalFastJSON(&w)
+
+	decoded := mustUnmarshalJSON(w)
+	expect := map[string]interface{}{
+		"timestamp": float64(123000000),
+		"transaction": map[string]interface{}{
+			"type":    "request",
+			"name":    "GET /",
+			"result":  "HTTP 2xx",
+			"outcome": "success",
+		},
+		"samples": map[string]interface{}{
+			"histogram": map[string]interface{}{
+				"type":   "histogram",
+				"values": []interface{}{0.5, 1.5},
+				"counts": []interface{}{float64(3), float64(7)},
+			},
+		},
+	}
+	assert.Equal(t, expect, decoded)
+}
+
 func TestMarshalError(t *testing.T) {
 	var e model.Error
 	time, err := time.Parse("2006-01-02T15:04:05.999Z", "1970-01-01T00:02:03Z")
@@ -302,6 +398,30 @@ func TestMarshalLog(t *testing.T) {
 	assert.Equal(t, `{"message":"foo","logger_name":"bar"}`, string(w.Bytes()))
 }
 
+func TestMarshalLogEvent(t *testing.T) {
+	log := model.LogEvent{
+		Timestamp:     model.Time(time.Unix(123, 0).UTC()),
+		Message:       "foo",
+		Level:         "info",
+		LoggerName:    "bar",
+		TraceID:       model.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		TransactionID: model.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		SpanID:        model.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		Labels:        model.IfaceMap{{Key: "a", Value: "b"}, {Key: "n", Value: 1}},
+	}
+	var w fastjson.Writer
+	log.MarshalFastJSON(&w)
+	assert.Equal(t, `{"@timestamp":123000000,"message":"foo","labels":{"a":"b","n":1},"log.level":"info","log.logger":"bar",`+
+		`"span.id":"0807060504030201","trace.id":"0102030405060708090a0b0c0d0e0f10","transaction.id":"0102030405060708"}`,
+		string(w.Bytes()),
+	)
+
+	log = model.LogEvent{Timestamp: model.Time(time.Unix(123, 0).UTC()), Message: "foo"}
+	w.Reset()
+	log.MarshalFastJSON(&w)
+	assert.Equal(t, `{"@timestamp":123000000,"message":"foo"}`, string(w.Bytes()))
+}
+
 func TestMarshalException(t *testing.T) {
 	x := model.Exception{
 		Message: "foo",
@@ -194,6 +194,10 @@ type Transaction struct {
 	// for HTTP requests.
 	Result string `json:"result,omitempty"`
 
+	// Outcome holds the outcome of the transaction: "success",
+	// "failure", or "unknown".
+	Outcome string `json:"outcome,omitempty"`
+
 	// Context holds contextual information relating to the transaction.
 	Context *Context `json:"context,omitempty"`
 
@@ -211,6 +215,46 @@ type Transaction struct {
 
 	// SpanCount holds statistics on spans within a transaction.
 	SpanCount SpanCount `json:"span_count"`
+
+	// FaaS holds Function-as-a-Service details of the transaction,
+	// if the transaction represents a function invocation.
+	FaaS *FaaS `json:"faas,omitempty"`
+
+	// Links holds links to spans or transactions that are causally
+	// related to the transaction, but are not its parent.
+	Links []SpanLink `json:"links,omitempty"`
+}
+
+// SpanLink holds a link to a span or transaction.
+type SpanLink struct {
+	// TraceID holds the ID of the trace containing the linked span.
+	TraceID TraceID `json:"trace_id"`
+
+	// SpanID holds the ID of the linked span or transaction.
+	SpanID SpanID `json:"span_id"`
+}
+
+// FaaS holds information about a Function-as-a-Service invocation.
+type FaaS struct {
+	// Coldstart indicates whether the invocation was the first one
+	// handled by the function instance.
+	Coldstart bool `json:"coldstart"`
+
+	// Execution holds the request ID of the function invocation.
+	Execution string `json:"execution,omitempty"`
+
+	// Trigger holds information about what triggered the invocation.
+	Trigger FaaSTrigger `json:"trigger"`
+}
+
+// FaaSTrigger holds information about a Function-as-a-Service trigger.
+type FaaSTrigger struct {
+	// Type holds the trigger type, e.g. "http", "pubsub" or "datasource".
+	Type string `json:"type"`
+
+	// RequestID holds the ID of the request or event that triggered
+	// the invocation, if any.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // SpanCount holds statistics on spans within a transaction.
@@ -246,6 +290,10 @@ type Span struct {
 	// Action identifies the action that is being undertaken, e.g. "query".
 	Action string `json:"action,omitempty"`
 
+	// Outcome holds the outcome of the span: "success",
+	// "failure", or "unknown".
+	Outcome string `json:"outcome,omitempty"`
+
 	// ID holds the ID of the span.
 	ID SpanID `json:"id"`
 
@@ -281,6 +329,9 @@ type SpanContext struct {
 	// HTTP holds contextual information for HTTP client request spans.
 	HTTP *HTTPSpanContext `json:"http,omitempty"`
 
+	// Message holds contextual information for messaging spans.
+	Message *MessageContext `json:"message,omitempty"`
+
 	// Tags holds user-defined key/value pairs.
 	Tags IfaceMap `json:"tags,omitempty"`
 }
@@ -344,6 +395,29 @@ type HTTPSpanContext struct {
 	StatusCode int `json:"status_code,omitempty"`
 }
 
+// MessageContext holds contextual information about a message
+// sent or received through a messaging system.
+type MessageContext struct {
+	// Queue holds information about the message queue or topic.
+	Queue *MessageQueueContext `json:"queue,omitempty"`
+
+	// Age holds information about the age of a received message.
+	Age *MessageAgeContext `json:"age,omitempty"`
+}
+
+// MessageQueueContext holds information about a message queue or topic.
+type MessageQueueContext struct {
+	// Name holds the name of the message queue or topic.
+	Name string `json:"name"`
+}
+
+// MessageAgeContext holds the age of a received message.
+type MessageAgeContext struct {
+	// Milliseconds holds the time elapsed between the message
+	// being produced and received, in milliseconds.
+	Milliseconds int64 `json:"ms"`
+}
+
 // Context holds contextual information relating to a transaction or error.
 type Context struct {
 	// Custom holds custom context relating to the transaction or error.
@@ -366,6 +440,10 @@ type Context struct {
 
 	// Service holds values to overrides service-level metadata.
 	Service *Service `json:"service,omitempty"`
+
+	// Message holds details of the message relating to the
+	// transaction, if relevant.
+	Message *MessageContext `json:"message,omitempty"`
 }
 
 // User holds information about an authenticated user.
@@ -521,6 +599,37 @@ type Log struct {
 	Stacktrace []StacktraceFrame `json:"stacktrace,omitempty"`
 }
 
+// LogEvent represents a log record sent to the server independently
+// of any error, using ECS (Elastic Common Schema) field names.
+type LogEvent struct {
+	// Timestamp holds the time at which the log record was written.
+	Timestamp Time `json:"@timestamp"`
+
+	// Message holds the log message.
+	Message string `json:"message"`
+
+	// Level holds the severity of the log record.
+	Level string `json:"log.level,omitempty"`
+
+	// LoggerName holds the name of the logger used.
+	LoggerName string `json:"log.logger,omitempty"`
+
+	// TraceID holds the ID of the trace within which the log record
+	// was written.
+	TraceID TraceID `json:"trace.id,omitempty"`
+
+	// TransactionID holds the ID of the transaction within which the
+	// log record was written.
+	TransactionID SpanID `json:"transaction.id,omitempty"`
+
+	// SpanID holds the ID of the span within which the log record
+	// was written.
+	SpanID SpanID `json:"span.id,omitempty"`
+
+	// Labels holds the structured fields of the log record.
+	Labels IfaceMap `json:"labels,omitempty"`
+}
+
 // Request represents an HTTP request.
 type Request struct {
 	// URL is the request URL.
@@ -662,8 +771,10 @@ type Metrics struct {
 
 // MetricsTransaction holds transaction identifiers for metrics.
 type MetricsTransaction struct {
-	Type string `json:"type,omitempty"`
-	Name string `json:"name,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Result  string `json:"result,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
 }
 
 // MetricsSpan holds span identifiers for metrics.
@@ -674,6 +785,20 @@ type MetricsSpan struct {
 
 // Metric holds metric values.
 type Metric struct {
-	// Value holds the metric value.
+	// Type holds an optional metric type, e.g. "histogram".
+	Type string `json:"type,omitempty"`
+
+	// Value holds the metric value for single-value metrics.
+	//
+	// Value is not encoded for histogram metrics.
 	Value float64 `json:"value"`
+
+	// Values holds the bucket values for histogram metrics.
+	//
+	// Values must be sorted in ascending order, and must have
+	// the same length as Counts.
+	Values []float64 `json:"values,omitempty"`
+
+	// Counts holds the bucket counts for histogram metrics.
+	Counts []uint64 `json:"counts,omitempty"`
 }
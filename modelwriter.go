@@ -29,6 +29,7 @@ const (
 	spanBlockTag
 	errorBlockTag
 	metricsBlockTag
+	logBlockTag
 )
 
 // notSampled is used as the pointee for the model.Transaction.Sampled field
@@ -42,6 +43,8 @@ type modelWriter struct {
 	stats           *TracerStats
 	json            fastjson.Writer
 	modelStacktrace []model.StacktraceFrame
+	modelFaaS       model.FaaS
+	modelLinks      []model.SpanLink
 }
 
 // writeTransaction encodes tx as JSON to the buffer, and then resets tx.
@@ -80,6 +83,15 @@ func (w *modelWriter) writeError(e *ErrorData) {
 	e.reset()
 }
 
+// writeLog encodes log as JSON to the buffer.
+func (w *modelWriter) writeLog(log *model.LogEvent) {
+	w.json.RawString(`{"log":`)
+	log.MarshalFastJSON(&w.json)
+	w.json.RawByte('}')
+	w.buffer.WriteBlock(w.json.Bytes(), logBlockTag)
+	w.json.Reset()
+}
+
 // writeMetrics encodes m as JSON to the w.metricsBuffer, and then resets m.
 //
 // Note that we do not write metrics to the main ring buffer (w.buffer), as
@@ -117,13 +129,35 @@ func (w *modelWriter) buildModelTransaction(out *model.Transaction, tx *Transact
 	out.Name = truncateString(td.Name)
 	out.Type = truncateString(td.Type)
 	out.Result = truncateString(td.Result)
+	out.Outcome = truncateString(td.Outcome)
 	out.Timestamp = model.Time(td.timestamp.UTC())
 	out.Duration = td.Duration.Seconds() * 1000
 	out.SpanCount.Started = td.spansCreated
 	out.SpanCount.Dropped = td.spansDropped
+	if len(td.links) != 0 {
+		w.modelLinks = w.modelLinks[:0]
+		for _, link := range td.links {
+			w.modelLinks = append(w.modelLinks, model.SpanLink{
+				TraceID: model.TraceID(link.Trace),
+				SpanID:  model.SpanID(link.Span),
+			})
+		}
+		out.Links = w.modelLinks
+	}
 	if sampled {
 		out.Context = td.Context.build()
 	}
+	if td.FaaS != (FaaS{}) {
+		w.modelFaaS = model.FaaS{
+			Coldstart: td.FaaS.Coldstart,
+			Execution: truncateString(td.FaaS.Execution),
+			Trigger: model.FaaSTrigger{
+				Type:      truncateString(td.FaaS.TriggerType),
+				RequestID: truncateString(td.FaaS.TriggerRequestID),
+			},
+		}
+		out.FaaS = &w.modelFaaS
+	}
 
 	if len(w.cfg.sanitizedFieldNames) != 0 && out.Context != nil {
 		if out.Context.Request != nil {
@@ -149,6 +183,7 @@ func (w *modelWriter) buildModelSpan(out *model.Span, span *Span, sd *SpanData)
 	out.Type = truncateString(sd.Type)
 	out.Subtype = truncateString(sd.Subtype)
 	out.Action = truncateString(sd.Action)
+	out.Outcome = truncateString(sd.Outcome)
 	out.Timestamp = model.Time(sd.timestamp.UTC())
 	out.Duration = sd.Duration.Seconds() * 1000
 	out.Context = sd.Context.build()
@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmamqp
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/streadway/amqp"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// defaultExchange is the name used in place of the
+// default exchange, whose name is the empty string.
+const defaultExchange = "<default>"
+
+var consumerSeq uint64
+
+var _ Channel = (*amqp.Channel)(nil)
+
+// Channel is the subset of the *amqp.Channel methods which are traced.
+type Channel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+}
+
+// WrapChannel wraps ch such that messages published with a context
+// containing a transaction are traced, and trace context is propagated
+// to consumers through message headers.
+func WrapChannel(ch Channel, o ...Option) *WrappedChannel {
+	return &WrappedChannel{
+		Channel: ch,
+		opts:    newOptions(o...),
+		queues:  make(map[string]string),
+	}
+}
+
+// WrappedChannel wraps a Channel, reporting a span for each message
+// published within a transaction, and providing StartTransaction for
+// tracing the processing of deliveries.
+type WrappedChannel struct {
+	Channel
+	opts options
+
+	mu     sync.RWMutex
+	queues map[string]string // consumer tag -> queue
+}
+
+// Publish publishes msg to exchange with the routing key, reporting a span
+// within the transaction in ctx, if any, and injecting trace context into
+// msg's headers. If ctx does not contain a transaction, msg is published
+// without modification.
+func (c *WrappedChannel) Publish(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	tx := apm.TransactionFromContext(ctx)
+	if tx == nil {
+		return c.Channel.Publish(exchange, key, mandatory, immediate, msg)
+	}
+	exchangeName := exchange
+	if exchangeName == "" {
+		exchangeName = defaultExchange
+	}
+
+	// Copy the headers so the caller's table is not modified.
+	headers := make(amqp.Table, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	carrier := apmmessaging.Carrier{Headers: tableHeaders(headers)}
+	span := apmmessaging.StartSpan(ctx, tx, "RabbitMQ", "rabbitmq", "SEND", exchangeName, carrier)
+	if span != nil && !span.Dropped() {
+		span.Context.SetLabel("amqp.routing_key", key)
+	}
+	msg.Headers = headers
+
+	err := c.Channel.Publish(exchange, key, mandatory, immediate, msg)
+	apmmessaging.EndSpan(span, err)
+	return err
+}
+
+// Consume starts consuming deliveries from queue, as with amqp.Channel.Consume.
+// The queue is recorded so that transactions started by StartTransaction for
+// the deliveries are named after it. If consumer is empty, a unique consumer
+// tag is generated.
+func (c *WrappedChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	if consumer == "" {
+		consumer = "ctag-apmamqp-" + strconv.FormatUint(atomic.AddUint64(&consumerSeq, 1), 10)
+	}
+	deliveries, err := c.Channel.Consume(queue, consumer, autoAck, exclusive, noLocal, noWait, args)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.queues[consumer] = queue
+	c.mu.Unlock()
+	return deliveries, nil
+}
+
+// Cancel stops deliveries to consumer, as with amqp.Channel.Cancel,
+// and forgets the queue recorded for it by Consume.
+func (c *WrappedChannel) Cancel(consumer string, noWait bool) error {
+	if err := c.Channel.Cancel(consumer, noWait); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.queues, consumer)
+	c.mu.Unlock()
+	return nil
+}
+
+// StartTransaction starts a transaction for processing d, continuing the
+// trace propagated through d's headers, if any. The transaction and any
+// propagated baggage are added to the returned context.
+//
+// If d was consumed with c.Consume, the transaction is named after the
+// queue; otherwise it is named after the exchange to which d was published.
+// It is the caller's responsibility to end the transaction once d has been
+// processed.
+func (c *WrappedChannel) StartTransaction(ctx context.Context, d amqp.Delivery) (*apm.Transaction, context.Context) {
+	c.mu.RLock()
+	queue := c.queues[d.ConsumerTag]
+	c.mu.RUnlock()
+	return startTransaction(ctx, c.opts.tracer, queue, d)
+}
+
+// StartTransaction starts a transaction for processing d, which was
+// consumed from queue, continuing the trace propagated through d's headers,
+// if any. The transaction and any propagated baggage are added to the
+// returned context.
+//
+// StartTransaction may be used for deliveries consumed from a channel not
+// wrapped with WrapChannel. It is the caller's responsibility to end the
+// transaction once d has been processed.
+func StartTransaction(ctx context.Context, queue string, d amqp.Delivery, o ...Option) (*apm.Transaction, context.Context) {
+	return startTransaction(ctx, newOptions(o...).tracer, queue, d)
+}
+
+func startTransaction(ctx context.Context, tracer *apm.Tracer, queue string, d amqp.Delivery) (*apm.Transaction, context.Context) {
+	name := queue
+	if name == "" {
+		name = d.Exchange
+		if name == "" {
+			name = defaultExchange
+		}
+	}
+	start := time.Now()
+	carrier := apmmessaging.Carrier{Headers: tableHeaders(d.Headers)}
+	tx, ctx := apmmessaging.StartTransaction(ctx, tracer, "RabbitMQ", name, start, carrier)
+	if tx.Sampled() {
+		var age time.Duration
+		if !d.Timestamp.IsZero() {
+			age = start.Sub(d.Timestamp)
+		}
+		tx.Context.SetMessage(apm.MessageContext{QueueName: name, Age: age})
+		tx.Context.SetLabel("amqp.routing_key", d.RoutingKey)
+	}
+	return tx, ctx
+}
+
+// Option sets options for tracing AMQP consumers.
+type Option func(*options)
+
+type options struct {
+	tracer *apm.Tracer
+}
+
+func newOptions(o ...Option) options {
+	opts := options{tracer: apm.DefaultTracer}
+	for _, o := range o {
+		o(&opts)
+	}
+	return opts
+}
+
+// WithTracer returns an Option which sets t as the tracer
+// to use for tracing deliveries.
+func WithTracer(t *apm.Tracer) Option {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(o *options) {
+		o.tracer = t
+	}
+}
@@ -0,0 +1,210 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmamqp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmamqp"
+)
+
+func TestPublishConsume(t *testing.T) {
+	consumerTracer := apmtest.NewRecordingTracer()
+	defer consumerTracer.Close()
+
+	fake := newFakeChannel()
+	ch := apmamqp.WrapChannel(fake, apmamqp.WithTracer(consumerTracer.Tracer))
+	deliveries, err := ch.Consume("orders-queue", "", false, false, false, false, nil)
+	require.NoError(t, err)
+
+	userHeaders := amqp.Table{"x-custom": "value"}
+	tx, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		ctx = apm.ContextWithBaggage(ctx, apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme"}))
+		err := ch.Publish(ctx, "orders", "orders.new", false, false, amqp.Publishing{
+			Headers:   userHeaders,
+			Timestamp: time.Now().Add(-time.Minute),
+			Body:      []byte("hello"),
+		})
+		require.NoError(t, err)
+	})
+	assert.Equal(t, amqp.Table{"x-custom": "value"}, userHeaders)
+
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "RabbitMQ SEND to orders", span.Name)
+	assert.Equal(t, "messaging", span.Type)
+	assert.Equal(t, "rabbitmq", span.Subtype)
+	assert.Equal(t, "send", span.Action)
+	assert.Equal(t, "success", span.Outcome)
+	assert.Equal(t, &model.DestinationServiceSpanContext{
+		Type:     "messaging",
+		Name:     "rabbitmq",
+		Resource: "rabbitmq/orders",
+	}, span.Context.Destination.Service)
+	assert.Equal(t, model.IfaceMap{{Key: "amqp_routing_key", Value: "orders.new"}}, span.Context.Tags)
+
+	d := <-deliveries
+	assert.Equal(t, "value", d.Headers["x-custom"])
+	consumerTx, ctx := ch.StartTransaction(context.Background(), d)
+	assert.Equal(t, consumerTx, apm.TransactionFromContext(ctx))
+	value, _ := apm.BaggageFromContext(ctx).Value("tenant")
+	assert.Equal(t, "acme", value)
+	consumerTx.End()
+	consumerTracer.Flush(nil)
+
+	payloads := consumerTracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	transaction := payloads.Transactions[0]
+	assert.Equal(t, "RabbitMQ RECEIVE from orders-queue", transaction.Name)
+	assert.Equal(t, "messaging", transaction.Type)
+	assert.Equal(t, tx.TraceID, transaction.TraceID)
+	assert.Equal(t, span.ID, transaction.ParentID)
+	require.NotNil(t, transaction.Context.Message)
+	assert.Equal(t, &model.MessageQueueContext{Name: "orders-queue"}, transaction.Context.Message.Queue)
+	require.NotNil(t, transaction.Context.Message.Age)
+	assert.InDelta(t, 60000, transaction.Context.Message.Age.Milliseconds, 10000)
+}
+
+func TestPublishDefaultExchange(t *testing.T) {
+	fake := newFakeChannel()
+	ch := apmamqp.WrapChannel(fake)
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		require.NoError(t, ch.Publish(ctx, "", "orders-queue", false, false, amqp.Publishing{}))
+	})
+	require.Len(t, spans, 1)
+	assert.Equal(t, "RabbitMQ SEND to <default>", spans[0].Name)
+	assert.Equal(t, "rabbitmq/<default>", spans[0].Context.Destination.Service.Resource)
+}
+
+func TestPublishError(t *testing.T) {
+	fake := newFakeChannel()
+	fake.publishErr = errors.New("channel closed")
+	ch := apmamqp.WrapChannel(fake)
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		err := ch.Publish(ctx, "orders", "", false, false, amqp.Publishing{})
+		assert.EqualError(t, err, "channel closed")
+	})
+	require.Len(t, spans, 1)
+	assert.Equal(t, "failure", spans[0].Outcome)
+}
+
+func TestPublishNoTransaction(t *testing.T) {
+	fake := newFakeChannel()
+	ch := apmamqp.WrapChannel(fake)
+	require.NoError(t, ch.Publish(context.Background(), "orders", "", false, false, amqp.Publishing{}))
+	require.Len(t, fake.published, 1)
+	assert.Nil(t, fake.published[0].Headers)
+}
+
+func TestStartTransactionUnknownQueue(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	tx, _ := apmamqp.StartTransaction(context.Background(), "", amqp.Delivery{Exchange: "orders"}, apmamqp.WithTracer(tracer.Tracer))
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, "RabbitMQ RECEIVE from orders", payloads.Transactions[0].Name)
+	assert.Zero(t, payloads.Transactions[0].ParentID)
+	assert.Nil(t, payloads.Transactions[0].Context.Message.Age)
+}
+
+func TestCancelForgetsQueue(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	fake := newFakeChannel()
+	ch := apmamqp.WrapChannel(fake, apmamqp.WithTracer(tracer.Tracer))
+	_, err := ch.Consume("orders-queue", "orders-consumer", false, false, false, false, nil)
+	require.NoError(t, err)
+	require.NoError(t, ch.Cancel("orders-consumer", false))
+	assert.Empty(t, fake.consumers)
+
+	d := amqp.Delivery{ConsumerTag: "orders-consumer", Exchange: "orders"}
+	tx, _ := ch.StartTransaction(context.Background(), d)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, "RabbitMQ RECEIVE from orders", payloads.Transactions[0].Name)
+}
+
+// fakeChannel is an apmamqp.Channel which delivers each published
+// message to every consumer, in place of a broker.
+type fakeChannel struct {
+	publishErr error
+	published  []amqp.Publishing
+	consumers  []fakeConsumer
+}
+
+type fakeConsumer struct {
+	tag        string
+	deliveries chan amqp.Delivery
+}
+
+func newFakeChannel() *fakeChannel {
+	return &fakeChannel{}
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if c.publishErr != nil {
+		return c.publishErr
+	}
+	c.published = append(c.published, msg)
+	for _, consumer := range c.consumers {
+		consumer.deliveries <- amqp.Delivery{
+			Headers:     msg.Headers,
+			Timestamp:   msg.Timestamp,
+			ConsumerTag: consumer.tag,
+			Exchange:    exchange,
+			RoutingKey:  key,
+			Body:        msg.Body,
+		}
+	}
+	return nil
+}
+
+func (c *fakeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	deliveries := make(chan amqp.Delivery, 10)
+	c.consumers = append(c.consumers, fakeConsumer{tag: consumer, deliveries: deliveries})
+	return deliveries, nil
+}
+
+func (c *fakeChannel) Cancel(consumer string, noWait bool) error {
+	for i, fc := range c.consumers {
+		if fc.tag == consumer {
+			close(fc.deliveries)
+			c.consumers = append(c.consumers[:i], c.consumers[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
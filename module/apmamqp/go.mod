@@ -0,0 +1,11 @@
+module go.elastic.co/apm/module/apmamqp
+
+require (
+	github.com/streadway/amqp v1.0.0
+	github.com/stretchr/testify v1.4.0
+	go.elastic.co/apm v1.8.0
+)
+
+replace go.elastic.co/apm => ../..
+
+go 1.13
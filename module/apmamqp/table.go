@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmamqp
+
+import "github.com/streadway/amqp"
+
+// tableHeaders is apmmessaging.Headers backed by amqp.Table.
+// Only string values are considered.
+type tableHeaders amqp.Table
+
+// Keys calls f with each key in h.
+func (h tableHeaders) Keys(f func(key string)) {
+	for k := range h {
+		f(k)
+	}
+}
+
+// Values returns the string value for key in h, if any.
+func (h tableHeaders) Values(key string) []string {
+	switch v := h[key].(type) {
+	case string:
+		return []string{v}
+	case []byte:
+		return []string{string(v)}
+	}
+	return nil
+}
+
+// Set sets the value for key in h.
+func (h tableHeaders) Set(key, value string) {
+	h[key] = value
+}
+
+// Del deletes key from h.
+func (h tableHeaders) Del(key string) {
+	delete(h, key)
+}
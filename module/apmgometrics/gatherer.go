@@ -19,6 +19,8 @@ package apmgometrics
 
 import (
 	"context"
+	"math"
+	"sort"
 
 	metrics "github.com/rcrowley/go-metrics"
 
@@ -46,17 +48,90 @@ func (g gatherer) GatherMetrics(ctx context.Context, m *apm.Metrics) error {
 		case metrics.GaugeFloat64:
 			m.Add(name, nil, v.Value())
 		case metrics.Histogram:
-			m.Add(name+".count", nil, float64(v.Count()))
-			m.Add(name+".total", nil, float64(v.Sum()))
-			m.Add(name+".min", nil, float64(v.Min()))
-			m.Add(name+".max", nil, float64(v.Max()))
-			m.Add(name+".stddev", nil, v.StdDev())
-			m.Add(name+".percentile.50", nil, v.Percentile(0.5))
-			m.Add(name+".percentile.95", nil, v.Percentile(0.95))
-			m.Add(name+".percentile.99", nil, v.Percentile(0.99))
+			addHistogramStats(m, name, v)
+			values, counts := sampleHistogram(v.Sample().Values())
+			m.AddHistogram(name+".histogram", nil, values, counts)
+		case metrics.Timer:
+			addHistogramStats(m, name, v)
+			values, counts := percentileHistogram(v)
+			m.AddHistogram(name+".histogram", nil, values, counts)
 		default:
-			// TODO(axw) Meter, Timer, EWMA
+			// TODO(axw) Meter, EWMA
 		}
 	})
 	return nil
 }
+
+// histogramStats is the subset of methods common to
+// metrics.Histogram and metrics.Timer.
+type histogramStats interface {
+	Count() int64
+	Sum() int64
+	Min() int64
+	Max() int64
+	StdDev() float64
+	Percentile(float64) float64
+	Percentiles([]float64) []float64
+}
+
+func addHistogramStats(m *apm.Metrics, name string, v histogramStats) {
+	m.Add(name+".count", nil, float64(v.Count()))
+	m.Add(name+".total", nil, float64(v.Sum()))
+	m.Add(name+".min", nil, float64(v.Min()))
+	m.Add(name+".max", nil, float64(v.Max()))
+	m.Add(name+".stddev", nil, v.StdDev())
+	m.Add(name+".percentile.50", nil, v.Percentile(0.5))
+	m.Add(name+".percentile.95", nil, v.Percentile(0.95))
+	m.Add(name+".percentile.99", nil, v.Percentile(0.99))
+}
+
+// sampleHistogram returns a histogram of the sampled values,
+// with one bucket for each distinct value.
+func sampleHistogram(sample []int64) ([]float64, []uint64) {
+	sort.Slice(sample, func(i, j int) bool { return sample[i] < sample[j] })
+	var values []float64
+	var counts []uint64
+	for i, v := range sample {
+		if i > 0 && v == sample[i-1] {
+			counts[len(counts)-1]++
+			continue
+		}
+		values = append(values, float64(v))
+		counts = append(counts, 1)
+	}
+	return values, counts
+}
+
+// timerHistogramQuantiles holds the quantiles used for approximating
+// the distribution of timer values, which are not otherwise exposed.
+var timerHistogramQuantiles = []float64{
+	0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 0.95, 0.99, 0.999, 1,
+}
+
+// percentileHistogram returns a histogram approximating the distribution
+// of v's values, with a bucket for each of timerHistogramQuantiles.
+func percentileHistogram(v histogramStats) ([]float64, []uint64) {
+	total := v.Count()
+	if total == 0 {
+		return nil, nil
+	}
+	percentiles := v.Percentiles(timerHistogramQuantiles)
+	var values []float64
+	var counts []uint64
+	var prevCount int64
+	for i, q := range timerHistogramQuantiles {
+		cumulativeCount := int64(math.Round(q * float64(total)))
+		count := cumulativeCount - prevCount
+		if count <= 0 {
+			continue
+		}
+		prevCount = cumulativeCount
+		if n := len(values); n > 0 && values[n-1] == percentiles[i] {
+			counts[n-1] += uint64(count)
+			continue
+		}
+		values = append(values, percentiles[i])
+		counts = append(counts, uint64(count))
+	}
+	return values, counts
+}
@@ -20,6 +20,7 @@ package apmgometrics_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	metrics "github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/assert"
@@ -84,9 +85,38 @@ func TestHistogram(t *testing.T) {
 		"histogram.percentile.50": {Value: 100},
 		"histogram.percentile.95": {Value: 150},
 		"histogram.percentile.99": {Value: 150},
+		"histogram.histogram": {
+			Type:   "histogram",
+			Values: []float64{50, 100, 150},
+			Counts: []uint64{1, 1, 1},
+		},
 	}, metrics[0].Samples)
 }
 
+func TestTimer(t *testing.T) {
+	r := metrics.NewRegistry()
+	timer := metrics.GetOrRegisterTimer("timer", r)
+	for i := 0; i < 10; i++ {
+		timer.Update(time.Millisecond)
+	}
+
+	g := apmgometrics.Wrap(r)
+	metrics := gatherMetrics(g)
+	for name := range metrics[0].Samples {
+		if !strings.HasPrefix(name, "timer.") {
+			delete(metrics[0].Samples, name)
+		}
+	}
+
+	assert.Equal(t, model.Metric{Value: 10}, metrics[0].Samples["timer.count"])
+	assert.Equal(t, model.Metric{Value: float64(10 * time.Millisecond)}, metrics[0].Samples["timer.total"])
+	assert.Equal(t, model.Metric{
+		Type:   "histogram",
+		Values: []float64{float64(time.Millisecond)},
+		Counts: []uint64{10},
+	}, metrics[0].Samples["timer.histogram"])
+}
+
 func gatherMetrics(g apm.MetricsGatherer) []model.Metrics {
 	tracer := apmtest.NewRecordingTracer()
 	defer tracer.Close()
@@ -20,12 +20,15 @@
 package apmgrpc
 
 import (
+	"net"
+	"strconv"
+	"strings"
+
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
 	"go.elastic.co/apm"
-	"go.elastic.co/apm/module/apmhttp"
 )
 
 // NewUnaryClientInterceptor returns a grpc.UnaryClientInterceptor that
@@ -33,7 +36,8 @@ import (
 //
 // The interceptor will trace spans with the "grpc" type for each request
 // made, for any client method presented with a context containing a sampled
-// apm.Transaction.
+// apm.Transaction. Baggage in the context, added with apm.ContextWithBaggage,
+// is propagated in the "baggage" metadata.
 func NewUnaryClientInterceptor(o ...ClientOption) grpc.UnaryClientInterceptor {
 	opts := clientOptions{}
 	for _, o := range o {
@@ -47,52 +51,153 @@ func NewUnaryClientInterceptor(o ...ClientOption) grpc.UnaryClientInterceptor {
 		invoker grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
-		span, ctx := startSpan(ctx, method)
-		if span != nil {
-			defer span.End()
+		span, ctx := startSpan(ctx, method, cc.Target())
+		if span == nil {
+			return invoker(ctx, method, req, resp, cc, opts...)
 		}
-		return invoker(ctx, method, req, resp, cc, opts...)
+		defer span.End()
+		ctx = contextWithInterceptorSpan(ctx, span)
+		err := invoker(ctx, method, req, resp, cc, opts...)
+		span.Outcome = clientOutcome(statusCode(err))
+		return err
 	}
 }
 
-func startSpan(ctx context.Context, name string) (*apm.Span, context.Context) {
+// NewStreamClientInterceptor returns a grpc.StreamClientInterceptor that
+// traces gRPC streams with the given options.
+//
+// The interceptor will trace spans with the "grpc" type for each stream
+// created, for any client method presented with a context containing a
+// sampled apm.Transaction. The span covers the lifetime of the stream: it
+// is ended when the stream completes, fails, or its context is done. The
+// number of messages sent and received on the stream, and the final gRPC
+// status code, are recorded as the span labels "grpc.messages_sent",
+// "grpc.messages_received", and "grpc.status_code".
+func NewStreamClientInterceptor(o ...ClientOption) grpc.StreamClientInterceptor {
+	opts := clientOptions{}
+	for _, o := range o {
+		o(&opts)
+	}
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		span, ctx := startSpan(ctx, method, cc.Target())
+		if span == nil {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx = contextWithInterceptorSpan(ctx, span)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endClientStreamSpan(span, 0, 0, err)
+			return nil, err
+		}
+		return newClientStream(ctx, stream, desc, span), nil
+	}
+}
+
+// startSpan starts a span for a client request, if ctx contains a
+// sampled transaction, and returns the context with the trace context
+// and baggage added to its outgoing metadata. If target is non-empty,
+// it is used to set the span's destination.
+func startSpan(ctx context.Context, name, target string) (*apm.Span, context.Context) {
 	tx := apm.TransactionFromContext(ctx)
 	if tx == nil {
+		if baggage := apm.BaggageFromContext(ctx); baggage.Len() > 0 {
+			md := outgoingMetadata(ctx)
+			apm.InjectBaggage(baggage, metadataCarrier(md))
+			ctx = metadata.NewOutgoingContext(ctx, md)
+		}
 		return nil, ctx
 	}
 	traceContext := tx.TraceContext()
-	propagateLegacyHeader := tx.ShouldPropagateLegacyHeader()
+	propagator := tx.Propagator()
 	if !traceContext.Options.Recorded() {
-		return nil, outgoingContextWithTraceContext(ctx, traceContext, propagateLegacyHeader)
+		return nil, outgoingContextWithTraceContext(ctx, traceContext, propagator)
 	}
 	span := tx.StartSpan(name, "external.grpc", apm.SpanFromContext(ctx))
 	if !span.Dropped() {
 		traceContext = span.TraceContext()
 		ctx = apm.ContextWithSpan(ctx, span)
+		if target != "" {
+			setSpanDestination(&span.Context, target)
+		}
 	}
-	return span, outgoingContextWithTraceContext(ctx, traceContext, propagateLegacyHeader)
+	return span, outgoingContextWithTraceContext(ctx, traceContext, propagator)
+}
+
+// setSpanDestination sets the span's destination address and service
+// using the ClientConn target, which is of the form "host:port", or
+// "scheme://authority/host:port" when a resolver scheme is specified.
+//
+// Unix domain socket targets, "unix:path" or "unix://absolute_path",
+// have no host or port, so only the destination service is set, with
+// the target as its resource.
+func setSpanDestination(c *apm.SpanContext, target string) {
+	if strings.HasPrefix(target, "unix:") || strings.HasPrefix(target, "unix-abstract:") {
+		c.SetDestinationService(apm.DestinationServiceSpanContext{
+			Name:     "grpc",
+			Resource: target,
+		})
+		return
+	}
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+len("://"):]
+		if i := strings.IndexByte(target, '/'); i >= 0 {
+			target = target[i+1:]
+		}
+	}
+	if target == "" {
+		return
+	}
+	host, portString, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+	port, _ := strconv.Atoi(portString)
+	c.SetDestinationAddress(host, port)
+	c.SetDestinationService(apm.DestinationServiceSpanContext{
+		Name:     "grpc",
+		Resource: target,
+	})
 }
 
 func outgoingContextWithTraceContext(
 	ctx context.Context,
 	traceContext apm.TraceContext,
-	propagateLegacyHeader bool,
+	propagator apm.Propagator,
 ) context.Context {
-	traceparentValue := apmhttp.FormatTraceparentHeader(traceContext)
+	md := outgoingMetadata(ctx)
+	propagator.Inject(traceContext, metadataCarrier(md))
+	apm.InjectBaggage(apm.BaggageFromContext(ctx), metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// outgoingMetadata returns a copy of the outgoing metadata in ctx,
+// or empty metadata if there is none.
+func outgoingMetadata(ctx context.Context) metadata.MD {
 	md, ok := metadata.FromOutgoingContext(ctx)
 	if !ok {
-		md = metadata.Pairs(w3cTraceparentHeader, traceparentValue)
-	} else {
-		md = md.Copy()
-		md.Set(w3cTraceparentHeader, traceparentValue)
-	}
-	if propagateLegacyHeader {
-		md.Set(elasticTraceparentHeader, traceparentValue)
-	}
-	if tracestate := traceContext.State.String(); tracestate != "" {
-		md.Set(tracestateHeader, tracestate)
+		return metadata.MD{}
 	}
-	return metadata.NewOutgoingContext(ctx, md)
+	return md.Copy()
+}
+
+// metadataCarrier is an apm.PropagationCarrier backed by gRPC metadata.
+type metadataCarrier metadata.MD
+
+// Get returns the metadata values for key.
+func (c metadataCarrier) Get(key string) []string {
+	return metadata.MD(c).Get(key)
+}
+
+// Set sets the metadata value for key.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
 }
 
 type clientOptions struct {
@@ -20,17 +20,26 @@
 package apmgrpc_test
 
 import (
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
 
 	"go.elastic.co/apm"
 	"go.elastic.co/apm/apmtest"
 	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmgrpc"
 	"go.elastic.co/apm/module/apmhttp"
 	"go.elastic.co/apm/transport/transporttest"
 )
@@ -82,6 +91,16 @@ func testClientSpan(t *testing.T, traceparentHeaders ...string) {
 	assert.Equal(t, "/helloworld.Greeter/SayHello", clientSpans[0].Name)
 	assert.Equal(t, "external", clientSpans[0].Type)
 	assert.Equal(t, "grpc", clientSpans[0].Subtype)
+	assert.Equal(t, "success", clientSpans[0].Outcome)
+	assert.Equal(t, &model.DestinationSpanContext{
+		Address: "127.0.0.1",
+		Port:    addr.(*net.TCPAddr).Port,
+		Service: &model.DestinationServiceSpanContext{
+			Type:     "external",
+			Name:     "grpc",
+			Resource: addr.String(),
+		},
+	}, clientSpans[0].Context.Destination)
 
 	serverTracer.Flush(nil)
 	serverTransactions := serverTransport.Payloads().Transactions
@@ -115,6 +134,85 @@ func testClientSpan(t *testing.T, traceparentHeaders ...string) {
 	assert.Equal(t, expectedCustom, serverTransactions[1].Context.Custom)
 }
 
+func TestClientSpanDestination(t *testing.T) {
+	s, _, addr := newServer(t, apmtest.DiscardTracer)
+	defer s.GracefulStop()
+
+	dir, err := ioutil.TempDir("", "apmgrpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "grpc.sock")
+	lis, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	go s.Serve(lis)
+
+	port := addr.(*net.TCPAddr).Port
+	for _, test := range []struct {
+		target string
+		expect *model.DestinationSpanContext
+	}{{
+		target: addr.String(),
+		expect: &model.DestinationSpanContext{
+			Address: "127.0.0.1",
+			Port:    port,
+			Service: &model.DestinationServiceSpanContext{Type: "external", Name: "grpc", Resource: addr.String()},
+		},
+	}, {
+		target: "passthrough:///" + addr.String(),
+		expect: &model.DestinationSpanContext{
+			Address: "127.0.0.1",
+			Port:    port,
+			Service: &model.DestinationServiceSpanContext{Type: "external", Name: "grpc", Resource: addr.String()},
+		},
+	}, {
+		target: "unix://" + socketPath,
+		expect: &model.DestinationSpanContext{
+			Service: &model.DestinationServiceSpanContext{Type: "external", Name: "grpc", Resource: "unix://" + socketPath},
+		},
+	}} {
+		t.Run(test.target, func(t *testing.T) {
+			conn, err := grpc.Dial(
+				test.target, grpc.WithInsecure(),
+				grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+					if strings.HasPrefix(addr, "unix://") {
+						return net.DialTimeout("unix", addr[len("unix://"):], timeout)
+					}
+					return net.DialTimeout("tcp", addr, timeout)
+				}),
+				grpc.WithUnaryInterceptor(apmgrpc.NewUnaryClientInterceptor()),
+			)
+			require.NoError(t, err)
+			defer conn.Close()
+			client := pb.NewGreeterClient(conn)
+
+			_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+				_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+				require.NoError(t, err)
+			})
+			require.Len(t, spans, 1)
+			assert.Equal(t, test.expect, spans[0].Context.Destination)
+		})
+	}
+}
+
+func TestClientSpanStatusError(t *testing.T) {
+	serverTracer := apmtest.NewRecordingTracer()
+	defer serverTracer.Close()
+	s, server, addr := newServer(t, serverTracer.Tracer)
+	defer s.GracefulStop()
+	server.err = status.Errorf(codes.NotFound, "boom")
+
+	conn, client := newClient(t, addr)
+	defer conn.Close()
+
+	_, clientSpans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+		assert.EqualError(t, err, "rpc error: code = NotFound desc = boom")
+	})
+	require.Len(t, clientSpans, 1)
+	assert.Equal(t, "failure", clientSpans[0].Outcome)
+}
+
 func TestClientSpanDropped(t *testing.T) {
 	serverTracer := apmtest.NewRecordingTracer()
 	defer serverTracer.Close()
@@ -1,11 +1,13 @@
 module go.elastic.co/apm/module/apmgrpc
 
 require (
+	github.com/golang/protobuf v1.2.0
 	github.com/grpc-ecosystem/go-grpc-middleware v1.0.0
 	github.com/stretchr/testify v1.4.0
 	go.elastic.co/apm v1.8.0
 	go.elastic.co/apm/module/apmhttp v1.8.0
 	golang.org/x/net v0.0.0-20190724013045-ca1201d0de80
+	google.golang.org/genproto v0.0.0-20180817151627-c66870c02cf8
 	google.golang.org/grpc v1.17.0
 )
 
@@ -20,8 +20,6 @@
 package apmgrpc
 
 import (
-	"strings"
-
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -29,13 +27,6 @@ import (
 	"google.golang.org/grpc/status"
 
 	"go.elastic.co/apm"
-	"go.elastic.co/apm/module/apmhttp"
-)
-
-var (
-	elasticTraceparentHeader = strings.ToLower(apmhttp.ElasticTraceparentHeader)
-	w3cTraceparentHeader     = strings.ToLower(apmhttp.W3CTraceparentHeader)
-	tracestateHeader         = strings.ToLower(apmhttp.TracestateHeader)
 )
 
 // NewUnaryServerInterceptor returns a grpc.UnaryServerInterceptor that
@@ -43,7 +34,9 @@ var (
 //
 // The interceptor will trace transactions with the "grpc" type for each
 // incoming request. The transaction will be added to the context, so
-// server methods can use apm.StartSpan with the provided context.
+// server methods can use apm.StartSpan with the provided context. Any
+// baggage in the incoming "baggage" metadata is also added to the context,
+// and may be obtained with apm.BaggageFromContext.
 //
 // By default, the interceptor will trace with apm.DefaultTracer,
 // and will not recover any panics. Use WithTracer to specify an
@@ -66,25 +59,17 @@ func NewUnaryServerInterceptor(o ...ServerOption) grpc.UnaryServerInterceptor {
 		if !opts.tracer.Recording() || opts.requestIgnorer(info) {
 			return handler(ctx, req)
 		}
-		tx, ctx := startTransaction(ctx, opts.tracer, info.FullMethod)
-		defer tx.End()
+		tx, ctx, started := startServerTransaction(ctx, opts.tracer, info.FullMethod)
+		if started {
+			defer tx.End()
+		}
 
 		// TODO(axw) define context schema for RPC,
 		// including at least the peer address.
 
 		defer func() {
-			r := recover()
-			if r != nil {
-				e := opts.tracer.Recovered(r)
-				e.SetTransaction(tx)
-				e.Context.SetFramework("grpc", grpc.Version)
-				e.Handled = opts.recover
-				e.Send()
-				if opts.recover {
-					err = status.Errorf(codes.Internal, "%s", r)
-				} else {
-					panic(r)
-				}
+			if r := recover(); r != nil {
+				err = recoverPanic(r, tx, opts)
 			}
 		}()
 
@@ -94,41 +79,107 @@ func NewUnaryServerInterceptor(o ...ServerOption) grpc.UnaryServerInterceptor {
 	}
 }
 
+// NewStreamServerInterceptor returns a grpc.StreamServerInterceptor that
+// traces gRPC streams with the given options.
+//
+// The interceptor will trace transactions with the "grpc" type for each
+// incoming stream, spanning the lifetime of the stream. The transaction
+// will be added to the stream's context, so server methods can use
+// apm.StartSpan with the context returned by the stream's Context method.
+// The number of messages sent and received on the stream are recorded
+// as the transaction labels "grpc.messages_sent" and "grpc.messages_received".
+//
+// The interceptor accepts the same options as NewUnaryServerInterceptor.
+// Request ignorers are passed a grpc.UnaryServerInfo with the stream's
+// server and full method name.
+func NewStreamServerInterceptor(o ...ServerOption) grpc.StreamServerInterceptor {
+	opts := serverOptions{
+		tracer:         apm.DefaultTracer,
+		recover:        false,
+		requestIgnorer: DefaultServerRequestIgnorer(),
+	}
+	for _, o := range o {
+		o(&opts)
+	}
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		if !opts.tracer.Recording() || opts.requestIgnorer(&grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: info.FullMethod,
+		}) {
+			return handler(srv, stream)
+		}
+		tx, ctx, started := startServerTransaction(stream.Context(), opts.tracer, info.FullMethod)
+		if started {
+			defer tx.End()
+		}
+
+		wrapped := &serverStream{ServerStream: stream, ctx: ctx}
+		defer func() {
+			wrapped.setLabels(&tx.Context)
+			if r := recover(); r != nil {
+				err = recoverPanic(r, tx, opts)
+			}
+		}()
+
+		err = handler(srv, wrapped)
+		setTransactionResult(tx, err)
+		return err
+	}
+}
+
+// recoverPanic reports the recovered panic value r as an error
+// associated with tx. If panic recovery is enabled, recoverPanic
+// returns a gRPC error with the code codes.Internal; otherwise it
+// panics again with r.
+func recoverPanic(r interface{}, tx *apm.Transaction, opts serverOptions) error {
+	e := opts.tracer.Recovered(r)
+	e.SetTransaction(tx)
+	e.Context.SetFramework("grpc", grpc.Version)
+	e.Handled = opts.recover
+	e.Send()
+	err := status.Errorf(codes.Internal, "%s", r)
+	setTransactionResult(tx, err)
+	if !opts.recover {
+		panic(r)
+	}
+	return err
+}
+
+// startServerTransaction returns the transaction started for the request
+// by a server stats handler, if any. Otherwise, startServerTransaction starts
+// a new transaction, and returns true to indicate that the caller must end it.
+func startServerTransaction(ctx context.Context, tracer *apm.Tracer, name string) (*apm.Transaction, context.Context, bool) {
+	if rs := rpcStatsFromContext(ctx); rs != nil && rs.tx != nil {
+		return rs.tx, ctx, false
+	}
+	tx, ctx := startTransaction(ctx, tracer, name)
+	return tx, ctx, true
+}
+
 func startTransaction(ctx context.Context, tracer *apm.Tracer, name string) (*apm.Transaction, context.Context) {
 	var opts apm.TransactionOptions
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		traceContext, ok := getIncomingMetadataTraceContext(md, elasticTraceparentHeader)
-		if !ok {
-			traceContext, _ = getIncomingMetadataTraceContext(md, w3cTraceparentHeader)
+		opts.TraceContext, _ = tracer.Propagator().Extract(metadataCarrier(md))
+		if baggage := apm.ExtractBaggage(metadataCarrier(md)); baggage.Len() > 0 {
+			ctx = apm.ContextWithBaggage(ctx, baggage)
 		}
-		opts.TraceContext = traceContext
 	}
 	tx := tracer.StartTransactionOptions(name, "request", opts)
 	tx.Context.SetFramework("grpc", grpc.Version)
 	return tx, apm.ContextWithTransaction(ctx, tx)
 }
 
-func getIncomingMetadataTraceContext(md metadata.MD, header string) (apm.TraceContext, bool) {
-	if values := md.Get(header); len(values) == 1 {
-		traceContext, err := apmhttp.ParseTraceparentHeader(values[0])
-		if err == nil {
-			traceContext.State, _ = apmhttp.ParseTracestateHeader(md.Get(tracestateHeader)...)
-			return traceContext, true
-		}
-	}
-	return apm.TraceContext{}, false
-}
-
+// setTransactionResult sets tx.Result to the name of the gRPC status
+// code for err, and tx.Outcome according to the code.
 func setTransactionResult(tx *apm.Transaction, err error) {
-	if err == nil {
-		tx.Result = codes.OK.String()
-	} else {
-		statusCode := codes.Unknown
-		if s, ok := status.FromError(err); ok {
-			statusCode = s.Code()
-		}
-		tx.Result = statusCode.String()
-	}
+	code := statusCode(err)
+	tx.Result = code.String()
+	tx.Outcome = serverOutcome(code)
 }
 
 type serverOptions struct {
@@ -166,6 +217,9 @@ func WithRecovery() ServerOption {
 
 // RequestIgnorerFunc is the type of a function for use in
 // WithServerRequestIgnorer.
+//
+// For streaming requests, the function is passed a grpc.UnaryServerInfo
+// holding the stream's server and full method name.
 type RequestIgnorerFunc func(*grpc.UnaryServerInfo) bool
 
 // WithServerRequestIgnorer returns a ServerOption which sets r as the
@@ -77,6 +77,7 @@ func TestServerTransaction(t *testing.T) {
 	t.Run("happy", adaptTest(testServerTransactionHappy))
 	t.Run("unknown_error", adaptTest(testServerTransactionUnknownError))
 	t.Run("status_error", adaptTest(testServerTransactionStatusError))
+	t.Run("client_status_error", adaptTest(testServerTransactionClientStatusError))
 	t.Run("panic", adaptTest(testServerTransactionPanic))
 }
 
@@ -109,6 +110,7 @@ func testServerTransactionHappy(t *testing.T, p testParams) {
 		assert.Equal(t, "/helloworld.Greeter/SayHello", tx.Name)
 		assert.Equal(t, "request", tx.Type)
 		assert.Equal(t, "OK", tx.Result)
+		assert.Equal(t, "success", tx.Outcome)
 		assert.Equal(t, model.TraceID(traceID), tx.TraceID)
 		assert.Equal(t, model.SpanID(clientSpanID), tx.ParentID)
 		assert.Equal(t, &model.Context{
@@ -137,6 +139,7 @@ func testServerTransactionUnknownError(t *testing.T, p testParams) {
 	assert.Equal(t, "/helloworld.Greeter/SayHello", tx.Name)
 	assert.Equal(t, "request", tx.Type)
 	assert.Equal(t, "Unknown", tx.Result)
+	assert.Equal(t, "failure", tx.Outcome)
 }
 
 func testServerTransactionStatusError(t *testing.T, p testParams) {
@@ -150,6 +153,20 @@ func testServerTransactionStatusError(t *testing.T, p testParams) {
 	assert.Equal(t, "/helloworld.Greeter/SayHello", tx.Name)
 	assert.Equal(t, "request", tx.Type)
 	assert.Equal(t, "DataLoss", tx.Result)
+	assert.Equal(t, "failure", tx.Outcome)
+}
+
+func testServerTransactionClientStatusError(t *testing.T, p testParams) {
+	p.server.err = status.Errorf(codes.InvalidArgument, "boom")
+	_, err := p.client.SayHello(context.Background(), &pb.HelloRequest{Name: "birita"})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = boom")
+
+	// Client errors are not considered server failures.
+	p.tracer.Flush(nil)
+	payloads := p.transport.Payloads()
+	tx := payloads.Transactions[0]
+	assert.Equal(t, "InvalidArgument", tx.Result)
+	assert.Equal(t, "success", tx.Outcome)
 }
 
 func testServerTransactionPanic(t *testing.T, p testParams) {
@@ -165,6 +182,85 @@ func testServerTransactionPanic(t *testing.T, p testParams) {
 	assert.Equal(t, false, e.Exception.Handled)
 	assert.Equal(t, "(*helloworldServer).SayHello", e.Culprit)
 	assert.Equal(t, "boom", e.Exception.Message)
+
+	tx := payloads.Transactions[0]
+	assert.Equal(t, "Internal", tx.Result)
+	assert.Equal(t, "failure", tx.Outcome)
+}
+
+func TestServerClientPropagator(t *testing.T) {
+	var recorder transporttest.RecorderTransport
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{
+		Transport:  &recorder,
+		Propagator: apm.B3Propagator{SingleHeader: true},
+	})
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	s, _, addr := newServer(t, tracer)
+	defer s.GracefulStop()
+
+	conn, client := newClient(t, addr)
+	defer conn.Close()
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	_, err = client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+	require.NoError(t, err)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := recorder.Payloads()
+	require.Len(t, payloads.Transactions, 2)
+	clientSpan := payloads.Spans[len(payloads.Spans)-1]
+	assert.Equal(t, "/helloworld.Greeter/SayHello", clientSpan.Name)
+
+	serverTx := payloads.Transactions[0]
+	assert.Equal(t, "/helloworld.Greeter/SayHello", serverTx.Name)
+	assert.Equal(t, clientSpan.TraceID, serverTx.TraceID)
+	assert.Equal(t, clientSpan.ID, serverTx.ParentID)
+	assert.Nil(t, serverTx.Context.Custom) // no traceparent/tracestate
+}
+
+func TestServerClientBaggage(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+	tracer.SetBaggageToAttach("tenant")
+
+	s, _, addr := newServer(t, tracer)
+	defer s.GracefulStop()
+
+	conn, client := newClient(t, addr)
+	defer conn.Close()
+
+	// Baggage is propagated with or without a client transaction.
+	ctx := apm.ContextWithBaggage(context.Background(), apm.NewBaggage(
+		apm.BaggageMember{Key: "tenant", Value: "acme corp"},
+		apm.BaggageMember{Key: "bucket", Value: "b"},
+	))
+	_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+	require.NoError(t, err)
+
+	tx := tracer.StartTransaction("name", "type")
+	_, err = client.SayHello(apm.ContextWithTransaction(ctx, tx), &pb.HelloRequest{Name: "birita"})
+	require.NoError(t, err)
+	tx.End()
+	tracer.Flush(nil)
+
+	expected := model.IfaceMap{{Key: "baggage_tenant", Value: "acme corp"}}
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Transactions, 3)
+	for _, tx := range payloads.Transactions {
+		assert.Equal(t, expected, tx.Context.Tags)
+	}
+	var serverSpans int
+	for _, span := range payloads.Spans {
+		if span.Type == "type" { // server_span
+			assert.Equal(t, expected, span.Context.Tags)
+			serverSpans++
+		}
+	}
+	assert.Equal(t, 2, serverSpans)
 }
 
 func TestServerRecovery(t *testing.T) {
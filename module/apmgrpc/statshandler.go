@@ -0,0 +1,327 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.9
+
+package apmgrpc
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+
+	"go.elastic.co/apm"
+)
+
+const (
+	sentBytesLabel         = "grpc.sent_bytes"
+	sentWireBytesLabel     = "grpc.sent_wire_bytes"
+	receivedBytesLabel     = "grpc.received_bytes"
+	receivedWireBytesLabel = "grpc.received_wire_bytes"
+	timeToFirstByteLabel   = "grpc.time_to_first_byte_ms"
+)
+
+// NewServerStatsHandler returns a stats.Handler that traces gRPC requests
+// with the given options, for use with grpc.StatsHandler.
+//
+// The stats handler will trace transactions with the "grpc" type for each
+// incoming request, in the same way as NewUnaryServerInterceptor and
+// NewStreamServerInterceptor. In addition, the stats handler records the
+// inbound and outbound payload sizes, uncompressed and on the wire, and the
+// time to the first byte of the response, as transaction labels. The size
+// of received payloads on the wire is recorded only if reported by gRPC. If header
+// capture is enabled, the request metadata is recorded as request headers,
+// subject to the tracer's field name sanitization.
+//
+// The stats handler may be used instead of, or together with, the server
+// interceptors. When used together, the interceptors will use the
+// transaction started by the stats handler rather than starting their own.
+//
+// WithRecovery has no effect on the stats handler; panics can only be
+// recovered by an interceptor.
+func NewServerStatsHandler(o ...ServerOption) stats.Handler {
+	opts := serverOptions{
+		tracer:         apm.DefaultTracer,
+		requestIgnorer: DefaultServerRequestIgnorer(),
+	}
+	for _, o := range o {
+		o(&opts)
+	}
+	return &serverStatsHandler{opts: opts}
+}
+
+// NewClientStatsHandler returns a stats.Handler that traces gRPC requests
+// with the given options, for use with grpc.WithStatsHandler.
+//
+// The stats handler will trace spans with the "grpc" type for each request
+// made with a context containing a sampled apm.Transaction, in the same way
+// as NewUnaryClientInterceptor and NewStreamClientInterceptor. In addition,
+// the stats handler records the outbound and inbound payload sizes,
+// uncompressed and on the wire, and the time to the first byte of the
+// response, as span labels.
+//
+// The stats handler may be used instead of, or together with, the client
+// interceptors. When used together, the stats handler will record its labels
+// on the span started by the interceptor rather than starting its own.
+func NewClientStatsHandler(o ...ClientOption) stats.Handler {
+	opts := clientOptions{}
+	for _, o := range o {
+		o(&opts)
+	}
+	return &clientStatsHandler{opts: opts}
+}
+
+type serverStatsHandler struct {
+	opts serverOptions
+}
+
+// TagRPC starts a transaction for the RPC, unless it is ignored.
+func (h *serverStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if !h.opts.tracer.Recording() || h.opts.requestIgnorer(&grpc.UnaryServerInfo{FullMethod: info.FullMethodName}) {
+		return ctx
+	}
+	tx, ctx := startTransaction(ctx, h.opts.tracer, info.FullMethodName)
+	return context.WithValue(ctx, rpcStatsKey{}, &rpcStats{tx: tx})
+}
+
+// HandleRPC records s for the RPC's transaction, ending
+// the transaction when the RPC ends.
+func (h *serverStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	rs := rpcStatsFromContext(ctx)
+	if rs == nil || rs.tx == nil {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InHeader:
+		// The request headers are received before the
+		// server handler is invoked, so it is safe to
+		// update the transaction context here.
+		md, _ := metadata.FromIncomingContext(ctx)
+		rs.tx.Context.SetHTTPRequest(newServerRequest(s, md))
+	case *stats.End:
+		rs.setLabels(&rs.tx.Context)
+		setTransactionResult(rs.tx, s.Error)
+		rs.tx.End()
+	default:
+		rs.handle(s)
+	}
+}
+
+// TagConn returns ctx.
+func (*serverStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn does nothing.
+func (*serverStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+type clientStatsHandler struct {
+	opts clientOptions
+}
+
+// TagRPC starts a span for the RPC if ctx contains a sampled
+// transaction, unless a client interceptor has already done so.
+func (h *clientStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if rs := rpcStatsFromContext(ctx); rs != nil && rs.span != nil && rs.claim() {
+		return ctx
+	}
+	span, ctx := startSpan(ctx, info.FullMethodName, "")
+	if span == nil {
+		return ctx
+	}
+	if span.Dropped() {
+		span.End()
+		return ctx
+	}
+	rs := &rpcStats{span: span, spanOwner: true, claimed: 1}
+	return context.WithValue(ctx, rpcStatsKey{}, rs)
+}
+
+// HandleRPC records s for the RPC's span. If the span was started
+// by the stats handler, it is ended when the RPC ends.
+func (h *clientStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	rs := rpcStatsFromContext(ctx)
+	if rs == nil || rs.span == nil {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.OutHeader:
+		if rs.spanOwner && s.RemoteAddr != nil {
+			setSpanDestination(&rs.span.Context, s.RemoteAddr.String())
+		}
+		rs.handle(s)
+	case *stats.End:
+		rs.setLabels(&rs.span.Context)
+		if rs.spanOwner {
+			rs.span.Outcome = clientOutcome(statusCode(s.Error))
+			rs.span.End()
+		}
+	default:
+		rs.handle(s)
+	}
+}
+
+// TagConn returns ctx.
+func (*clientStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn does nothing.
+func (*clientStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+type rpcStatsKey struct{}
+
+// labelSetter is implemented by apm.Context and apm.SpanContext.
+type labelSetter interface {
+	SetLabel(key string, value interface{})
+}
+
+// rpcStats holds the transaction or span for an RPC, and the
+// stats recorded for it by a stats handler.
+type rpcStats struct {
+	tx   *apm.Transaction
+	span *apm.Span
+
+	// spanOwner records whether span was started by the client
+	// stats handler, and must be ended by it. claimed is set once
+	// a client stats handler has tagged the RPC, so that nested
+	// RPCs do not record stats on an enclosing RPC's span.
+	spanOwner bool
+	claimed   int32
+
+	mu                sync.Mutex
+	begin             time.Time
+	firstByte         time.Time
+	sentBytes         int64
+	sentWireBytes     int64
+	receivedBytes     int64
+	receivedWireBytes int64
+}
+
+func rpcStatsFromContext(ctx context.Context) *rpcStats {
+	rs, _ := ctx.Value(rpcStatsKey{}).(*rpcStats)
+	return rs
+}
+
+// contextWithInterceptorSpan returns a copy of ctx recording that span
+// was started by a client interceptor, so that a client stats handler
+// will record its stats on span rather than starting a new one.
+func contextWithInterceptorSpan(ctx context.Context, span *apm.Span) context.Context {
+	if span.Dropped() {
+		return ctx
+	}
+	return context.WithValue(ctx, rpcStatsKey{}, &rpcStats{span: span})
+}
+
+// claim reports whether rs was claimed by this call.
+func (rs *rpcStats) claim() bool {
+	return atomic.CompareAndSwapInt32(&rs.claimed, 0, 1)
+}
+
+// handle records the payload sizes and timings in s.
+//
+// Payloads may be sent and received concurrently,
+// so rs.mu is held while updating the stats.
+func (rs *rpcStats) handle(s stats.RPCStats) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	switch s := s.(type) {
+	case *stats.Begin:
+		rs.begin = s.BeginTime
+	case *stats.InHeader:
+		if s.Client {
+			rs.setFirstByte(time.Now())
+		}
+	case *stats.OutHeader:
+		if !s.Client {
+			rs.setFirstByte(time.Now())
+		}
+	case *stats.InPayload:
+		rs.receivedBytes += int64(s.Length)
+		rs.receivedWireBytes += int64(s.WireLength)
+		if s.Client {
+			rs.setFirstByte(s.RecvTime)
+		}
+	case *stats.OutPayload:
+		rs.sentBytes += int64(s.Length)
+		rs.sentWireBytes += int64(s.WireLength)
+		if !s.Client {
+			rs.setFirstByte(s.SentTime)
+		}
+	}
+}
+
+// setFirstByte records t as the time of the first byte of the
+// response, if it has not already been recorded.
+func (rs *rpcStats) setFirstByte(t time.Time) {
+	if rs.firstByte.IsZero() {
+		rs.firstByte = t
+	}
+}
+
+// setLabels records the payload sizes and time to first byte as labels.
+func (rs *rpcStats) setLabels(c labelSetter) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	c.SetLabel(sentBytesLabel, rs.sentBytes)
+	c.SetLabel(sentWireBytesLabel, rs.sentWireBytes)
+	c.SetLabel(receivedBytesLabel, rs.receivedBytes)
+	if rs.receivedWireBytes > 0 {
+		// Older versions of gRPC do not report the wire
+		// length of received payloads.
+		c.SetLabel(receivedWireBytesLabel, rs.receivedWireBytes)
+	}
+	if !rs.begin.IsZero() && !rs.firstByte.IsZero() {
+		ms := float64(rs.firstByte.Sub(rs.begin)) / float64(time.Millisecond)
+		c.SetLabel(timeToFirstByteLabel, ms)
+	}
+}
+
+// newServerRequest returns an HTTP/2 request describing the
+// gRPC request, with the request metadata as its headers.
+// Binary ("-bin") metadata is omitted.
+func newServerRequest(s *stats.InHeader, md metadata.MD) *http.Request {
+	header := make(http.Header, len(md))
+	for k, values := range md {
+		if strings.HasPrefix(k, ":") || strings.HasSuffix(k, "-bin") {
+			continue
+		}
+		header[http.CanonicalHeaderKey(k)] = values
+	}
+	req := &http.Request{
+		Method:     http.MethodPost,
+		URL:        &url.URL{Path: s.FullMethod},
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+	}
+	if authority := md.Get(":authority"); len(authority) > 0 {
+		req.Host = authority[0]
+	}
+	if s.RemoteAddr != nil {
+		req.RemoteAddr = s.RemoteAddr.String()
+	}
+	return req
+}
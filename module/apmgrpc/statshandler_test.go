@@ -0,0 +1,226 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.9
+
+package apmgrpc_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmgrpc"
+	"go.elastic.co/apm/transport/transporttest"
+)
+
+func TestStatsHandlers(t *testing.T) {
+	serverTracer, serverTransport := transporttest.NewRecorderTracer()
+	defer serverTracer.Close()
+	clientTracer, clientTransport := transporttest.NewRecorderTracer()
+	defer clientTracer.Close()
+
+	s, addr := newStatsHandlerServer(t, serverTracer, false)
+	defer s.GracefulStop()
+	conn, client := newStatsHandlerClient(t, addr, false)
+	defer conn.Close()
+
+	tx := clientTracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	resp, err := client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+	require.NoError(t, err)
+	assert.Equal(t, &pb.HelloReply{Message: "hello, birita"}, resp)
+	tx.End()
+	serverTracer.Flush(nil)
+	clientTracer.Flush(nil)
+
+	clientSpans := clientTransport.Payloads().Spans
+	require.Len(t, clientSpans, 1)
+	clientSpan := clientSpans[0]
+	assert.Equal(t, "/helloworld.Greeter/SayHello", clientSpan.Name)
+	assert.Equal(t, "external", clientSpan.Type)
+	assert.Equal(t, "grpc", clientSpan.Subtype)
+	assert.Equal(t, "success", clientSpan.Outcome)
+	assert.Equal(t, &model.DestinationSpanContext{
+		Address: "127.0.0.1",
+		Port:    addr.(*net.TCPAddr).Port,
+		Service: &model.DestinationServiceSpanContext{
+			Type:     "external",
+			Name:     "grpc",
+			Resource: addr.String(),
+		},
+	}, clientSpan.Context.Destination)
+	checkStatsLabels(t, clientSpan.Context.Tags, "birita", "hello, birita")
+
+	serverTransactions := serverTransport.Payloads().Transactions
+	require.Len(t, serverTransactions, 1)
+	serverTx := serverTransactions[0]
+	assert.Equal(t, "/helloworld.Greeter/SayHello", serverTx.Name)
+	assert.Equal(t, "request", serverTx.Type)
+	assert.Equal(t, "OK", serverTx.Result)
+	assert.Equal(t, "success", serverTx.Outcome)
+	assert.Equal(t, clientSpan.TraceID, serverTx.TraceID)
+	assert.Equal(t, clientSpan.ID, serverTx.ParentID)
+	checkStatsLabels(t, serverTx.Context.Tags, "hello, birita", "birita")
+
+	require.NotNil(t, serverTx.Context.Request)
+	assert.Equal(t, "POST", serverTx.Context.Request.Method)
+	assert.Equal(t, "2.0", serverTx.Context.Request.HTTPVersion)
+	assert.Equal(t, "/helloworld.Greeter/SayHello", serverTx.Context.Request.URL.Path)
+}
+
+func TestStatsHandlersWithInterceptors(t *testing.T) {
+	serverTracer, serverTransport := transporttest.NewRecorderTracer()
+	defer serverTracer.Close()
+	clientTracer, clientTransport := transporttest.NewRecorderTracer()
+	defer clientTracer.Close()
+
+	s, addr := newStatsHandlerServer(t, serverTracer, true)
+	defer s.GracefulStop()
+	conn, client := newStatsHandlerClient(t, addr, true)
+	defer conn.Close()
+
+	tx := clientTracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+	require.NoError(t, err)
+	tx.End()
+	serverTracer.Flush(nil)
+	clientTracer.Flush(nil)
+
+	// The interceptors and stats handlers should
+	// record a single transaction and span.
+	clientSpans := clientTransport.Payloads().Spans
+	require.Len(t, clientSpans, 1)
+	assert.Equal(t, "success", clientSpans[0].Outcome)
+	checkStatsLabels(t, clientSpans[0].Context.Tags, "birita", "hello, birita")
+
+	serverTransactions := serverTransport.Payloads().Transactions
+	require.Len(t, serverTransactions, 1)
+	assert.Equal(t, "OK", serverTransactions[0].Result)
+	assert.Equal(t, clientSpans[0].ID, serverTransactions[0].ParentID)
+	checkStatsLabels(t, serverTransactions[0].Context.Tags, "hello, birita", "birita")
+}
+
+func TestServerStatsHandlerCaptureHeaders(t *testing.T) {
+	for _, captureHeaders := range []bool{false, true} {
+		tracer, transport := transporttest.NewRecorderTracer()
+		defer tracer.Close()
+		tracer.SetCaptureHeaders(captureHeaders)
+
+		s, addr := newStatsHandlerServer(t, tracer, false)
+		defer s.GracefulStop()
+		conn, client := newStatsHandlerClient(t, addr, false)
+		defer conn.Close()
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(),
+			"authorization", "secret",
+			"x-custom", "value",
+		)
+		_, err := client.SayHello(ctx, &pb.HelloRequest{Name: "birita"})
+		require.NoError(t, err)
+		tracer.Flush(nil)
+
+		transactions := transport.Payloads().Transactions
+		require.Len(t, transactions, 1)
+		request := transactions[0].Context.Request
+		require.NotNil(t, request)
+		if !captureHeaders {
+			assert.Empty(t, request.Headers)
+			continue
+		}
+		headers := make(map[string][]string)
+		for _, h := range request.Headers {
+			headers[h.Key] = h.Values
+		}
+		assert.Equal(t, []string{"[REDACTED]"}, headers["Authorization"])
+		assert.Equal(t, []string{"value"}, headers["X-Custom"])
+	}
+}
+
+func TestServerStatsHandlerIgnorer(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	s, addr := newStatsHandlerServer(t, tracer, false, apmgrpc.WithServerRequestIgnorer(func(*grpc.UnaryServerInfo) bool {
+		return true
+	}))
+	defer s.GracefulStop()
+	conn, client := newStatsHandlerClient(t, addr, false)
+	defer conn.Close()
+
+	_, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "birita"})
+	require.NoError(t, err)
+	tracer.Flush(nil)
+	assert.Empty(t, transport.Payloads())
+}
+
+// checkStatsLabels checks the payload size and time to first byte labels
+// recorded by the stats handlers, given the names in the sent and received
+// messages, which are the only fields set.
+func checkStatsLabels(t *testing.T, labels model.IfaceMap, sentName, receivedName string) {
+	values := make(map[string]interface{})
+	for _, label := range labels {
+		values[label.Key] = label.Value
+	}
+	// Each message has a one byte field tag, one byte
+	// length, and the name; on the wire, each message
+	// is prefixed with a 5 byte header.
+	sentBytes := float64(2 + len(sentName))
+	receivedBytes := float64(2 + len(receivedName))
+	assert.Equal(t, sentBytes, values["grpc_sent_bytes"])
+	assert.Equal(t, sentBytes+5, values["grpc_sent_wire_bytes"])
+	assert.Equal(t, receivedBytes, values["grpc_received_bytes"])
+	if value, ok := values["grpc_received_wire_bytes"]; ok {
+		// Older versions of gRPC do not report the
+		// wire length of received payloads.
+		assert.Equal(t, receivedBytes+5, value)
+	}
+	assert.IsType(t, float64(0), values["grpc_time_to_first_byte_ms"])
+}
+
+func newStatsHandlerServer(t *testing.T, tracer *apm.Tracer, interceptor bool, opts ...apmgrpc.ServerOption) (*grpc.Server, net.Addr) {
+	opts = append(opts, apmgrpc.WithTracer(tracer))
+	serverOpts := []grpc.ServerOption{grpc.StatsHandler(apmgrpc.NewServerStatsHandler(opts...))}
+	if interceptor {
+		serverOpts = append(serverOpts, grpc.UnaryInterceptor(apmgrpc.NewUnaryServerInterceptor(opts...)))
+	}
+	s := grpc.NewServer(serverOpts...)
+	pb.RegisterGreeterServer(s, &helloworldServer{})
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	go s.Serve(lis)
+	return s, lis.Addr()
+}
+
+func newStatsHandlerClient(t *testing.T, addr net.Addr, interceptor bool) (*grpc.ClientConn, pb.GreeterClient) {
+	dialOpts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithStatsHandler(apmgrpc.NewClientStatsHandler())}
+	if interceptor {
+		dialOpts = append(dialOpts, grpc.WithUnaryInterceptor(apmgrpc.NewUnaryClientInterceptor()))
+	}
+	conn, err := grpc.Dial(addr.String(), dialOpts...)
+	require.NoError(t, err)
+	return conn, pb.NewGreeterClient(conn)
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.9
+
+package apmgrpc
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.elastic.co/apm"
+)
+
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+func init() {
+	apm.RegisterErrorDetailer(apm.ErrorDetailerFunc(func(err error, details *apm.ErrorDetails) {
+		grpcStatusErr, ok := err.(interface {
+			GRPCStatus() *status.Status
+		})
+		if !ok {
+			return
+		}
+		s := grpcStatusErr.GRPCStatus()
+		details.Code.String = s.Code().String()
+		for _, detail := range s.Details() {
+			setStatusDetailAttrs(detail, details)
+		}
+	}))
+}
+
+// setStatusDetailAttrs records the known gRPC status detail message
+// as error attributes. Unknown status details are ignored.
+func setStatusDetailAttrs(detail interface{}, details *apm.ErrorDetails) {
+	switch detail := detail.(type) {
+	case *errdetails.BadRequest:
+		violations := make([]map[string]string, len(detail.FieldViolations))
+		for i, v := range detail.FieldViolations {
+			violations[i] = map[string]string{
+				"field":       v.Field,
+				"description": v.Description,
+			}
+		}
+		details.SetAttr("field_violations", violations)
+	case *errdetails.RetryInfo:
+		if d := detail.RetryDelay; d != nil {
+			delay := time.Duration(d.Seconds)*time.Second + time.Duration(d.Nanos)
+			details.SetAttr("retry_delay", delay.String())
+		}
+	case interface {
+		// ErrorInfo is not defined by all versions of
+		// genproto, so we match it by its methods.
+		GetReason() string
+		GetDomain() string
+		GetMetadata() map[string]string
+	}:
+		details.SetAttr("reason", detail.GetReason())
+		details.SetAttr("domain", detail.GetDomain())
+		if metadata := detail.GetMetadata(); len(metadata) > 0 {
+			details.SetAttr("metadata", metadata)
+		}
+	}
+}
+
+// statusCode returns the gRPC status code for err,
+// translating context errors to their equivalent codes.
+func statusCode(err error) codes.Code {
+	switch err {
+	case context.Canceled:
+		return codes.Canceled
+	case context.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	}
+	return status.Code(err)
+}
+
+// serverOutcome returns the transaction outcome for a server
+// request that completed with the given status code.
+//
+// Codes which indicate a client error, such as InvalidArgument
+// or NotFound, are not considered failures of the server.
+func serverOutcome(code codes.Code) string {
+	switch code {
+	case codes.Unknown,
+		codes.DeadlineExceeded,
+		codes.ResourceExhausted,
+		codes.FailedPrecondition,
+		codes.Aborted,
+		codes.Internal,
+		codes.Unavailable,
+		codes.DataLoss:
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// clientOutcome returns the span outcome for a client request
+// that completed with the given status code.
+func clientOutcome(code codes.Code) string {
+	if code == codes.OK {
+		return outcomeSuccess
+	}
+	return outcomeFailure
+}
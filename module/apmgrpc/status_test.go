@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.9
+
+package apmgrpc_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.elastic.co/apm/apmtest"
+
+	// Register the gRPC status ErrorDetailer.
+	_ "go.elastic.co/apm/module/apmgrpc"
+)
+
+func TestStatusErrorDetails(t *testing.T) {
+	s, err := status.New(codes.InvalidArgument, "invalid request").WithDetails(
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+				Field:       "name",
+				Description: "must not be empty",
+			}},
+		},
+		&errdetails.RetryInfo{RetryDelay: &duration.Duration{Seconds: 1, Nanos: 500000000}},
+		&errorInfo{
+			Reason:   "QUOTA_EXCEEDED",
+			Domain:   "example.com",
+			Metadata: map[string]string{"quota": "requests"},
+		},
+	)
+	require.NoError(t, err)
+
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	tracer.NewError(s.Err()).Send()
+	tracer.Flush(nil)
+
+	errors := tracer.Payloads().Errors
+	require.Len(t, errors, 1)
+	exception := errors[0].Exception
+	assert.Equal(t, "rpc error: code = InvalidArgument desc = invalid request", exception.Message)
+	assert.Equal(t, "InvalidArgument", exception.Code.String)
+	assert.Equal(t, map[string]interface{}{
+		"field_violations": []interface{}{
+			map[string]interface{}{
+				"field":       "name",
+				"description": "must not be empty",
+			},
+		},
+		"retry_delay": "1.5s",
+		"reason":      "QUOTA_EXCEEDED",
+		"domain":      "example.com",
+		"metadata":    map[string]interface{}{"quota": "requests"},
+	}, exception.Attributes)
+}
+
+// errorInfo mirrors google.rpc.ErrorInfo, which is not
+// defined by the version of genproto used for testing.
+type errorInfo struct {
+	Reason   string            `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	Domain   string            `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Metadata map[string]string `protobuf:"bytes,3,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func init() {
+	proto.RegisterType((*errorInfo)(nil), "google.rpc.ErrorInfo")
+}
+
+func (m *errorInfo) Reset()         { *m = errorInfo{} }
+func (m *errorInfo) String() string { return proto.CompactTextString(m) }
+func (*errorInfo) ProtoMessage()    {}
+
+func (m *errorInfo) GetReason() string              { return m.Reason }
+func (m *errorInfo) GetDomain() string              { return m.Domain }
+func (m *errorInfo) GetMetadata() map[string]string { return m.Metadata }
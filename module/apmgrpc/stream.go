@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.9
+
+package apmgrpc
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go.elastic.co/apm"
+)
+
+const (
+	messagesSentLabel     = "grpc.messages_sent"
+	messagesReceivedLabel = "grpc.messages_received"
+	statusCodeLabel       = "grpc.status_code"
+)
+
+// messageCounter counts the messages sent and received on a stream.
+//
+// Messages may be sent and received concurrently, so the counts
+// are updated atomically.
+type messageCounter struct {
+	sent     int64
+	received int64
+}
+
+func (c *messageCounter) counts() (sent, received int64) {
+	return atomic.LoadInt64(&c.sent), atomic.LoadInt64(&c.received)
+}
+
+// serverStream wraps a grpc.ServerStream, overriding its context
+// and counting the messages sent and received.
+type serverStream struct {
+	messageCounter
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream's context, which contains the transaction.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg calls the wrapped stream's SendMsg method,
+// counting the message if it is sent successfully.
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	}
+	return err
+}
+
+// RecvMsg calls the wrapped stream's RecvMsg method,
+// counting the message if it is received successfully.
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.received, 1)
+	}
+	return err
+}
+
+func (s *serverStream) setLabels(c *apm.Context) {
+	sent, received := s.counts()
+	c.SetLabel(messagesSentLabel, sent)
+	c.SetLabel(messagesReceivedLabel, received)
+}
+
+// clientStream wraps a grpc.ClientStream, counting the messages sent
+// and received, and ending the span when the stream completes.
+type clientStream struct {
+	messageCounter
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+	span *apm.Span
+
+	endOnce sync.Once
+	done    chan struct{}
+}
+
+func newClientStream(ctx context.Context, stream grpc.ClientStream, desc *grpc.StreamDesc, span *apm.Span) *clientStream {
+	s := &clientStream{
+		ClientStream: stream,
+		desc:         desc,
+		span:         span,
+		done:         make(chan struct{}),
+	}
+	go func() {
+		// End the span if the stream's context is done before
+		// the stream completes, as the stream will be aborted.
+		select {
+		case <-ctx.Done():
+			s.end(ctx.Err())
+		case <-s.done:
+		}
+	}()
+	return s
+}
+
+// Header calls the wrapped stream's Header method,
+// ending the span if an error is returned.
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+// SendMsg calls the wrapped stream's SendMsg method, counting the
+// message if it is sent successfully, and otherwise ending the span.
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.sent, 1)
+	} else if err != io.EOF {
+		// io.EOF indicates that the stream was terminated by the
+		// server; the status is obtained by a subsequent RecvMsg.
+		s.end(err)
+	}
+	return err
+}
+
+// RecvMsg calls the wrapped stream's RecvMsg method, counting the
+// message if it is received successfully, and ending the span when
+// the stream completes.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		atomic.AddInt64(&s.received, 1)
+		if !s.desc.ServerStreams {
+			// There will be no more messages if the
+			// server does not stream its responses.
+			s.end(nil)
+		}
+	case err == io.EOF:
+		s.end(nil)
+	default:
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) end(err error) {
+	s.endOnce.Do(func() {
+		close(s.done)
+		sent, received := s.counts()
+		endClientStreamSpan(s.span, sent, received, err)
+	})
+}
+
+// endClientStreamSpan records the message counts and
+// status code of a client stream, and ends its span.
+func endClientStreamSpan(span *apm.Span, sent, received int64, err error) {
+	if !span.Dropped() {
+		span.Context.SetLabel(messagesSentLabel, sent)
+		span.Context.SetLabel(messagesReceivedLabel, received)
+		code := statusCode(err)
+		span.Context.SetLabel(statusCodeLabel, code.String())
+		span.Outcome = clientOutcome(code)
+	}
+	span.End()
+}
@@ -0,0 +1,345 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.9
+
+package apmgrpc_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pb "google.golang.org/grpc/examples/route_guide/routeguide"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmgrpc"
+	"go.elastic.co/apm/transport/transporttest"
+)
+
+func TestStreamServerClient(t *testing.T) {
+	serverTracer, serverTransport := transporttest.NewRecorderTracer()
+	defer serverTracer.Close()
+	clientTracer, clientTransport := transporttest.NewRecorderTracer()
+	defer clientTracer.Close()
+
+	s, _, lis := newStreamServer(t, serverTracer)
+	defer s.Stop()
+	conn, client := newStreamClient(t, lis)
+	defer conn.Close()
+
+	tx := clientTracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+
+	// Bidirectional streaming.
+	chat, err := client.RouteChat(ctx)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, chat.Send(&pb.RouteNote{Message: "hello"}))
+		_, err := chat.Recv()
+		require.NoError(t, err)
+	}
+	require.NoError(t, chat.CloseSend())
+	_, err = chat.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	// Server streaming.
+	features, err := client.ListFeatures(ctx, &pb.Rectangle{})
+	require.NoError(t, err)
+	for {
+		if _, err := features.Recv(); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+
+	// Client streaming.
+	route, err := client.RecordRoute(ctx)
+	require.NoError(t, err)
+	require.NoError(t, route.Send(&pb.Point{}))
+	require.NoError(t, route.Send(&pb.Point{}))
+	summary, err := route.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), summary.PointCount)
+
+	tx.End()
+	serverTracer.Flush(nil)
+	clientTracer.Flush(nil)
+
+	clientSpans := clientTransport.Payloads().Spans
+	serverTransactions := serverTransport.Payloads().Transactions
+	require.Len(t, clientSpans, 3)
+	require.Len(t, serverTransactions, 3)
+
+	// The server span should be a child of the ListFeatures transaction.
+	serverSpans := serverTransport.Payloads().Spans
+	require.Len(t, serverSpans, 1)
+	assert.Equal(t, serverTransactions[1].ID, serverSpans[0].ParentID)
+
+	expectations := []struct {
+		method   string
+		sent     int64
+		received int64
+	}{
+		{"/routeguide.RouteGuide/RouteChat", 3, 3},
+		{"/routeguide.RouteGuide/ListFeatures", 1, 2},
+		{"/routeguide.RouteGuide/RecordRoute", 2, 1},
+	}
+	for i, expect := range expectations {
+		clientSpan := clientSpans[i]
+		serverTx := serverTransactions[i]
+		assert.Equal(t, expect.method, clientSpan.Name)
+		assert.Equal(t, "external", clientSpan.Type)
+		assert.Equal(t, "grpc", clientSpan.Subtype)
+		assert.Equal(t, "success", clientSpan.Outcome)
+		assert.Equal(t, model.IfaceMap{
+			{Key: "grpc_messages_received", Value: float64(expect.received)},
+			{Key: "grpc_messages_sent", Value: float64(expect.sent)},
+			{Key: "grpc_status_code", Value: "OK"},
+		}, clientSpan.Context.Tags)
+
+		assert.Equal(t, expect.method, serverTx.Name)
+		assert.Equal(t, "request", serverTx.Type)
+		assert.Equal(t, "OK", serverTx.Result)
+		assert.Equal(t, "success", serverTx.Outcome)
+		assert.Equal(t, clientSpan.TraceID, serverTx.TraceID)
+		assert.Equal(t, clientSpan.ID, serverTx.ParentID)
+		assert.Equal(t, model.IfaceMap{
+			{Key: "grpc_messages_received", Value: float64(expect.sent)},
+			{Key: "grpc_messages_sent", Value: float64(expect.received)},
+		}, serverTx.Context.Tags)
+	}
+}
+
+func TestStreamServerError(t *testing.T) {
+	serverTracer, serverTransport := transporttest.NewRecorderTracer()
+	defer serverTracer.Close()
+	clientTracer, clientTransport := transporttest.NewRecorderTracer()
+	defer clientTracer.Close()
+
+	s, server, lis := newStreamServer(t, serverTracer)
+	defer s.Stop()
+	conn, client := newStreamClient(t, lis)
+	defer conn.Close()
+
+	server.err = status.Error(codes.InvalidArgument, "no features here")
+	tx := clientTracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	features, err := client.ListFeatures(ctx, &pb.Rectangle{})
+	require.NoError(t, err)
+	_, err = features.Recv()
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = no features here")
+	tx.End()
+	serverTracer.Flush(nil)
+	clientTracer.Flush(nil)
+
+	serverTransactions := serverTransport.Payloads().Transactions
+	require.Len(t, serverTransactions, 1)
+	assert.Equal(t, "InvalidArgument", serverTransactions[0].Result)
+	assert.Equal(t, "success", serverTransactions[0].Outcome)
+
+	clientSpans := clientTransport.Payloads().Spans
+	require.Len(t, clientSpans, 1)
+	assert.Contains(t, clientSpans[0].Context.Tags, model.IfaceMapItem{Key: "grpc_status_code", Value: "InvalidArgument"})
+	assert.Equal(t, "failure", clientSpans[0].Outcome)
+}
+
+func TestStreamClientContextCanceled(t *testing.T) {
+	clientTracer, clientTransport := transporttest.NewRecorderTracer()
+	defer clientTracer.Close()
+
+	s, server, lis := newStreamServer(t, nil)
+	defer s.Stop()
+	conn, client := newStreamClient(t, lis)
+	defer conn.Close()
+
+	server.block = make(chan struct{})
+	defer close(server.block)
+
+	tx := clientTracer.StartTransaction("name", "type")
+	ctx, cancel := context.WithCancel(apm.ContextWithTransaction(context.Background(), tx))
+	_, err := client.ListFeatures(ctx, &pb.Rectangle{})
+	require.NoError(t, err)
+	cancel()
+
+	// The span is ended when the context is canceled,
+	// without any further calls to the stream's methods.
+	deadline := time.After(10 * time.Second)
+	for {
+		clientTracer.Flush(nil)
+		if spans := clientTransport.Payloads().Spans; len(spans) > 0 {
+			assert.Contains(t, spans[0].Context.Tags, model.IfaceMapItem{Key: "grpc_status_code", Value: "Canceled"})
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for span")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	tx.End()
+}
+
+func TestStreamServerRecovery(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	s, server, lis := newStreamServer(t, tracer, apmgrpc.WithRecovery())
+	defer s.Stop()
+	conn, client := newStreamClient(t, lis)
+	defer conn.Close()
+
+	server.panic = true
+	server.err = errors.New("boom")
+	features, err := client.ListFeatures(context.Background(), &pb.Rectangle{})
+	require.NoError(t, err)
+	_, err = features.Recv()
+	assert.EqualError(t, err, "rpc error: code = Internal desc = boom")
+
+	tracer.Flush(nil)
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Errors, 1)
+	e := payloads.Errors[0]
+	assert.NotEmpty(t, e.TransactionID)
+	assert.Equal(t, true, e.Exception.Handled)
+	assert.Equal(t, "(*routeGuideServer).ListFeatures", e.Culprit)
+	assert.Equal(t, "boom", e.Exception.Message)
+}
+
+func TestStreamServerIgnorer(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	var ignored []string
+	s, _, lis := newStreamServer(t, tracer, apmgrpc.WithServerRequestIgnorer(func(info *grpc.UnaryServerInfo) bool {
+		ignored = append(ignored, info.FullMethod)
+		return true
+	}))
+	defer s.Stop()
+	conn, client := newStreamClient(t, lis)
+	defer conn.Close()
+
+	features, err := client.ListFeatures(context.Background(), &pb.Rectangle{})
+	require.NoError(t, err)
+	for {
+		if _, err := features.Recv(); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+
+	tracer.Flush(nil)
+	assert.Empty(t, transport.Payloads())
+	assert.Equal(t, []string{"/routeguide.RouteGuide/ListFeatures"}, ignored)
+}
+
+func newStreamServer(t *testing.T, tracer *apm.Tracer, opts ...apmgrpc.ServerOption) (*grpc.Server, *routeGuideServer, *bufconn.Listener) {
+	// As in newServer, we always install grpc_recovery first.
+	interceptors := []grpc.StreamServerInterceptor{grpc_recovery.StreamServerInterceptor()}
+	if tracer != nil {
+		opts = append(opts, apmgrpc.WithTracer(tracer))
+		interceptors = append(interceptors, apmgrpc.NewStreamServerInterceptor(opts...))
+	}
+	s := grpc.NewServer(grpc_middleware.WithStreamServerChain(interceptors...))
+	server := &routeGuideServer{}
+	pb.RegisterRouteGuideServer(s, server)
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Serve(lis)
+	return s, server, lis
+}
+
+func newStreamClient(t *testing.T, lis *bufconn.Listener) (*grpc.ClientConn, pb.RouteGuideClient) {
+	conn, err := grpc.Dial(
+		"bufconn", grpc.WithInsecure(),
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithStreamInterceptor(apmgrpc.NewStreamClientInterceptor()),
+	)
+	require.NoError(t, err)
+	return conn, pb.NewRouteGuideClient(conn)
+}
+
+type routeGuideServer struct {
+	panic bool
+	err   error
+	block chan struct{}
+}
+
+func (s *routeGuideServer) GetFeature(ctx context.Context, point *pb.Point) (*pb.Feature, error) {
+	return &pb.Feature{Location: point}, nil
+}
+
+func (s *routeGuideServer) ListFeatures(rect *pb.Rectangle, stream pb.RouteGuide_ListFeaturesServer) error {
+	if s.block != nil {
+		<-s.block
+	}
+	if s.panic {
+		panic(s.err)
+	}
+	if s.err != nil {
+		return s.err
+	}
+	// The context passed to the server should contain a Transaction for the gRPC stream.
+	span, _ := apm.StartSpan(stream.Context(), "server_span", "type")
+	defer span.End()
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(&pb.Feature{Location: rect.Lo}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *routeGuideServer) RecordRoute(stream pb.RouteGuide_RecordRouteServer) error {
+	var summary pb.RouteSummary
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&summary)
+		} else if err != nil {
+			return err
+		}
+		summary.PointCount++
+	}
+}
+
+func (s *routeGuideServer) RouteChat(stream pb.RouteGuide_RouteChatServer) error {
+	for {
+		note, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.Send(note); err != nil {
+			return err
+		}
+	}
+}
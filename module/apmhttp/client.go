@@ -36,6 +36,9 @@ import (
 // timeout, but not a valid response with a non-200 status code),
 // or otherwise when the response body is fully consumed or closed.
 //
+// Baggage in the request context, added with apm.ContextWithBaggage,
+// is propagated in the W3C baggage header.
+//
 // If c is nil, then http.DefaultClient is wrapped.
 func WrapClient(c *http.Client, o ...ClientOption) *http.Client {
 	if c == nil {
@@ -81,23 +84,29 @@ func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	ctx := req.Context()
 	tx := apm.TransactionFromContext(ctx)
-	if tx == nil {
+	baggage := apm.BaggageFromContext(ctx)
+	if tx == nil && baggage.Len() == 0 {
 		return r.r.RoundTrip(req)
 	}
 
 	// RoundTrip is not supposed to mutate req, so copy req
-	// and set the trace-context headers only in the copy.
+	// and set the trace-context and baggage headers only in
+	// the copy.
 	reqCopy := *req
 	reqCopy.Header = make(http.Header, len(req.Header))
 	for k, v := range req.Header {
 		reqCopy.Header[k] = v
 	}
 	req = &reqCopy
+	apm.InjectBaggage(baggage, apm.HTTPHeaderCarrier(req.Header))
+	if tx == nil {
+		return r.r.RoundTrip(req)
+	}
 
-	propagateLegacyHeader := tx.ShouldPropagateLegacyHeader()
+	propagator := tx.Propagator()
 	traceContext := tx.TraceContext()
 	if !traceContext.Options.Recorded() {
-		r.setHeaders(req, traceContext, propagateLegacyHeader)
+		propagator.Inject(traceContext, apm.HTTPHeaderCarrier(req.Header))
 		return r.r.RoundTrip(req)
 	}
 
@@ -117,7 +126,7 @@ func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		span = nil
 	}
 
-	r.setHeaders(req, traceContext, propagateLegacyHeader)
+	propagator.Inject(traceContext, apm.HTTPHeaderCarrier(req.Header))
 	resp, err := r.r.RoundTrip(req)
 	if span != nil {
 		if err != nil {
@@ -133,17 +142,6 @@ func (r *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-func (r *roundTripper) setHeaders(req *http.Request, traceContext apm.TraceContext, propagateLegacyHeader bool) {
-	headerValue := FormatTraceparentHeader(traceContext)
-	if propagateLegacyHeader {
-		req.Header.Set(ElasticTraceparentHeader, headerValue)
-	}
-	req.Header.Set(W3CTraceparentHeader, headerValue)
-	if tracestate := traceContext.State.String(); tracestate != "" {
-		req.Header.Set(TracestateHeader, tracestate)
-	}
-}
-
 // CloseIdleConnections calls r.r.CloseIdleConnections if the method exists.
 func (r *roundTripper) CloseIdleConnections() {
 	type closeIdler interface {
@@ -159,6 +159,65 @@ func testClientTraceContextHeaders(t *testing.T, traceparentHeaders ...string) {
 	assert.Equal(t, "vendor=tracestate", headers["Tracestate"])
 }
 
+func TestClientPropagator(t *testing.T) {
+	var recorder transporttest.RecorderTransport
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{
+		Transport:  &recorder,
+		Propagator: apm.CompositePropagator{apm.B3Propagator{}, apm.XRayPropagator{}},
+	})
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(req.Header)
+	}))
+	defer server.Close()
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	_, responseBody := mustGET(ctx, server.URL)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := recorder.Payloads()
+	require.Len(t, payloads.Spans, 1)
+	span := payloads.Spans[0]
+
+	var headers http.Header
+	err = json.Unmarshal([]byte(responseBody), &headers)
+	require.NoError(t, err)
+	assert.NotContains(t, headers, "Traceparent")
+	assert.Equal(t, apm.TraceID(span.TraceID).String(), headers.Get("X-B3-Traceid"))
+	assert.Equal(t, apm.SpanID(span.ID).String(), headers.Get("X-B3-Spanid"))
+	assert.Equal(t, "1", headers.Get("X-B3-Sampled"))
+	assert.Contains(t, headers.Get("X-Amzn-Trace-Id"), "Parent="+apm.SpanID(span.ID).String())
+}
+
+func TestClientBaggage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Header.Get("Baggage")))
+	}))
+	defer server.Close()
+
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	baggage := apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme corp"})
+	ctx := apm.ContextWithBaggage(context.Background(), baggage)
+
+	// Baggage is propagated even if there is no transaction in the context.
+	_, responseBody := mustGET(ctx, server.URL)
+	assert.Equal(t, "tenant=acme%20corp", responseBody)
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx = apm.ContextWithTransaction(ctx, tx)
+	_, responseBody = mustGET(ctx, server.URL)
+	tx.End()
+	tracer.Flush(nil)
+	assert.Equal(t, "tenant=acme%20corp", responseBody)
+	assert.Len(t, transport.Payloads().Spans, 1)
+}
+
 func TestClientSpanDropped(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		w.Write([]byte(req.Header.Get("Elastic-Apm-Traceparent")))
@@ -38,3 +38,13 @@ func StatusCodeResult(statusCode int) string {
 	}
 	return fmt.Sprintf("HTTP %d", statusCode)
 }
+
+// ServerStatusCodeOutcome returns the transaction outcome value to use for
+// the given status code, from the perspective of the server handling the
+// request: 5xx responses are failures, and everything else is a success.
+func ServerStatusCodeOutcome(statusCode int) string {
+	if statusCode >= 500 {
+		return "failure"
+	}
+	return "success"
+}
@@ -44,3 +44,12 @@ func TestStatusCode(t *testing.T) {
 	assert.Equal(t, "HTTP 0", apmhttp.StatusCodeResult(0))
 	assert.Equal(t, "HTTP 600", apmhttp.StatusCodeResult(600))
 }
+
+func TestServerStatusCodeOutcome(t *testing.T) {
+	for i := 100; i < 500; i++ {
+		assert.Equal(t, "success", apmhttp.ServerStatusCodeOutcome(i))
+	}
+	for i := 500; i < 600; i++ {
+		assert.Equal(t, "failure", apmhttp.ServerStatusCodeOutcome(i))
+	}
+}
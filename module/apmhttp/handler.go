@@ -73,6 +73,7 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	tx, req := StartTransaction(h.tracer, h.requestName(req), req)
 	defer tx.End()
+	defer apm.SetProfilerLabels(req.Context())()
 
 	body := h.tracer.CaptureHTTPRequestBody(req)
 	w, resp := WrapResponseWriter(w)
@@ -100,37 +101,31 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 }
 
 // StartTransaction returns a new Transaction with name,
-// created with tracer, and taking trace context from req.
+// created with tracer, and taking trace context from req
+// using the tracer's Propagator.
 //
 // If the transaction is not ignored, the request will be
-// returned with the transaction added to its context.
+// returned with the transaction added to its context. Any
+// W3C baggage in the request headers is also added to the
+// request context, and may be obtained with apm.BaggageFromContext.
 func StartTransaction(tracer *apm.Tracer, name string, req *http.Request) (*apm.Transaction, *http.Request) {
-	traceContext, ok := getRequestTraceparent(req, ElasticTraceparentHeader)
-	if !ok {
-		traceContext, ok = getRequestTraceparent(req, W3CTraceparentHeader)
-	}
-	if ok {
-		traceContext.State, _ = ParseTracestateHeader(req.Header[TracestateHeader]...)
-	}
+	carrier := apm.HTTPHeaderCarrier(req.Header)
+	traceContext, _ := tracer.Propagator().Extract(carrier)
 	tx := tracer.StartTransactionOptions(name, "request", apm.TransactionOptions{TraceContext: traceContext})
-	ctx := apm.ContextWithTransaction(req.Context(), tx)
+	ctx := req.Context()
+	if baggage := apm.ExtractBaggage(carrier); baggage.Len() > 0 {
+		ctx = apm.ContextWithBaggage(ctx, baggage)
+	}
+	ctx = apm.ContextWithTransaction(ctx, tx)
 	req = RequestWithContext(ctx, req)
 	return tx, req
 }
 
-func getRequestTraceparent(req *http.Request, header string) (apm.TraceContext, bool) {
-	if values := req.Header[header]; len(values) == 1 && values[0] != "" {
-		if c, err := ParseTraceparentHeader(values[0]); err == nil {
-			return c, true
-		}
-	}
-	return apm.TraceContext{}, false
-}
-
-// SetTransactionContext sets tx.Result and, if the transaction is being
-// sampled, sets tx.Context with information from req, resp, and body.
+// SetTransactionContext sets tx.Result and tx.Outcome and, if the transaction
+// is being sampled, sets tx.Context with information from req, resp, and body.
 func SetTransactionContext(tx *apm.Transaction, req *http.Request, resp *Response, body *apm.BodyCapturer) {
 	tx.Result = StatusCodeResult(resp.StatusCode)
+	tx.Outcome = ServerStatusCodeOutcome(resp.StatusCode)
 	if !tx.Sampled() {
 		return
 	}
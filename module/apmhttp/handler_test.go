@@ -445,6 +445,55 @@ func TestHandlerTraceparentHeader(t *testing.T) {
 	}
 }
 
+func TestHandlerPropagator(t *testing.T) {
+	var recorder transporttest.RecorderTransport
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{
+		Transport:  &recorder,
+		Propagator: apm.CompositePropagator{apm.W3CTraceContextPropagator{}, apm.B3Propagator{}},
+	})
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	h := apmhttp.Wrap(http.NotFoundHandler(), apmhttp.WithTracer(tracer))
+	req, _ := http.NewRequest("GET", "http://server.testing/foo", nil)
+	req.Header.Set("X-B3-TraceId", "0af7651916cd43dd8448eb211c80319c")
+	req.Header.Set("X-B3-SpanId", "b7ad6b7169203331")
+	req.Header.Set("X-B3-Sampled", "1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	tracer.Flush(nil)
+
+	payloads := recorder.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", apm.TraceID(payloads.Transactions[0].TraceID).String())
+	assert.Equal(t, "b7ad6b7169203331", apm.SpanID(payloads.Transactions[0].ParentID).String())
+}
+
+func TestHandlerBaggage(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+	tracer.SetBaggageToAttach("tenant")
+
+	var baggage apm.Baggage
+	h := apmhttp.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		baggage = apm.BaggageFromContext(req.Context())
+	}), apmhttp.WithTracer(tracer))
+	req, _ := http.NewRequest("GET", "http://server.testing/foo", nil)
+	req.Header.Set("Baggage", "tenant=acme%20corp,bucket=b;prop=1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	tracer.Flush(nil)
+
+	assert.Equal(t, apm.NewBaggage(
+		apm.BaggageMember{Key: "tenant", Value: "acme corp"},
+		apm.BaggageMember{Key: "bucket", Value: "b"},
+	), baggage)
+
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, model.IfaceMap{
+		{Key: "baggage_tenant", Value: "acme corp"},
+	}, payloads.Transactions[0].Context.Tags)
+}
+
 func TestHandlerTracestateHeader(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.Handle("/foo", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
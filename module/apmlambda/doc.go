@@ -16,4 +16,8 @@
 // under the License.
 
 // Package apmlambda provides tracing for AWS Lambda functions.
+//
+// Functions should be started with apmlambda.Start in place of
+// lambda.Start, so that the transaction for each invocation is
+// made available to the handler through its context.
 package apmlambda
@@ -22,9 +22,8 @@ package main
 import (
 	"context"
 
-	"github.com/aws/aws-lambda-go/lambda"
-
-	_ "go.elastic.co/apm/module/apmlambda"
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/module/apmlambda"
 )
 
 type Request struct {
@@ -32,9 +31,11 @@ type Request struct {
 }
 
 func Handler(ctx context.Context, req Request) (string, error) {
+	span, _ := apm.StartSpan(ctx, "greet", "app")
+	defer span.End()
 	return "Hello, " + req.Name, nil
 }
 
 func main() {
-	lambda.Start(Handler)
+	apmlambda.Start(Handler)
 }
@@ -2,6 +2,7 @@ module go.elastic.co/apm/module/apmlambda
 
 require (
 	github.com/aws/aws-lambda-go v1.8.0
+	github.com/stretchr/testify v1.4.0
 	go.elastic.co/apm v1.8.0
 )
 
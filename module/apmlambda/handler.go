@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmlambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+
+	"go.elastic.co/apm"
+)
+
+// handlerWrapped is set to 1 once WrapHandler has been called, and
+// is used to disable the tracing performed by the RPC interception
+// in init, so that invocations are not traced twice.
+var handlerWrapped int32
+
+// Start wraps handler with WrapHandler, and starts the Lambda function
+// with the wrapped handler. Start should be used in place of lambda.Start,
+// and accepts the same handler function types.
+func Start(handler interface{}, o ...Option) {
+	lambda.StartHandler(WrapHandler(handler, o...))
+}
+
+// WrapHandler returns a lambda.Handler which traces invocations of handler,
+// which must be a function type accepted by lambda.Start.
+//
+// Each invocation is traced as a transaction, which is added to the context
+// passed to handler. The transaction is named according to the event that
+// triggered the invocation: API Gateway, Application Load Balancer, SQS, SNS,
+// S3 and EventBridge events are recognised, and trace context is extracted
+// from HTTP headers and message attributes. Other events are named after the
+// function.
+func WrapHandler(handler interface{}, o ...Option) lambda.Handler {
+	opts := options{tracer: apm.DefaultTracer}
+	for _, o := range o {
+		o(&opts)
+	}
+	atomic.StoreInt32(&handlerWrapped, 1)
+	return &tracingHandler{
+		handler:   lambda.NewHandler(handler),
+		tracer:    opts.tracer,
+		coldstart: 1,
+	}
+}
+
+type tracingHandler struct {
+	handler   lambda.Handler
+	tracer    *apm.Tracer
+	coldstart int32
+}
+
+// Invoke invokes the wrapped handler, tracing the invocation.
+func (h *tracingHandler) Invoke(ctx context.Context, payload []byte) (response []byte, err error) {
+	trigger := parseTrigger(payload, h.tracer.Propagator())
+	name := trigger.name
+	if name == "" {
+		name = lambdacontext.FunctionName
+	}
+	tx := h.tracer.StartTransactionOptions(name, trigger.transactionType, apm.TransactionOptions{
+		TraceContext: trigger.traceContext,
+	})
+	// The function may be frozen as soon as Invoke returns, so wait
+	// for the transaction to be sent, up until the function deadline.
+	defer h.tracer.Flush(ctx.Done())
+	defer tx.End()
+
+	tx.FaaS = apm.FaaS{
+		Coldstart:        atomic.SwapInt32(&h.coldstart, 0) == 1,
+		TriggerType:      trigger.triggerType,
+		TriggerRequestID: trigger.requestID,
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		tx.FaaS.Execution = lc.AwsRequestID
+	}
+	if trigger.request != nil && tx.Sampled() {
+		tx.Context.SetHTTPRequest(trigger.request)
+	}
+	if trigger.baggage.Len() > 0 {
+		ctx = apm.ContextWithBaggage(ctx, trigger.baggage)
+	}
+	ctx = apm.ContextWithTransaction(ctx, tx)
+
+	defer func() {
+		if v := recover(); v != nil {
+			e := h.tracer.Recovered(v)
+			e.SetTransaction(tx)
+			e.Send()
+			tx.Result = "failure"
+			tx.Outcome = "failure"
+			panic(v)
+		}
+	}()
+	response, err = h.handler.Invoke(ctx, payload)
+	if err != nil {
+		e := h.tracer.NewError(err)
+		e.SetTransaction(tx)
+		e.Send()
+		tx.Result = "failure"
+		tx.Outcome = "failure"
+		return response, err
+	}
+	if trigger.request != nil {
+		setHTTPResponse(tx, response)
+	} else {
+		tx.Result = "success"
+		tx.Outcome = "success"
+	}
+	return response, nil
+}
+
+// setHTTPResponse records the status code of an API Gateway or
+// Application Load Balancer response as the transaction result.
+func setHTTPResponse(tx *apm.Transaction, response []byte) {
+	var r struct {
+		StatusCode int `json:"statusCode"`
+	}
+	if err := json.Unmarshal(response, &r); err != nil || r.StatusCode == 0 {
+		return
+	}
+	tx.Context.SetHTTPStatusCode(r.StatusCode)
+	tx.Result = fmt.Sprintf("HTTP %dxx", r.StatusCode/100)
+	if r.StatusCode >= 500 {
+		tx.Outcome = "failure"
+	} else {
+		tx.Outcome = "success"
+	}
+}
+
+// Option sets options for tracing Lambda function invocations.
+type Option func(*options)
+
+type options struct {
+	tracer *apm.Tracer
+}
+
+// WithTracer returns an Option which sets t as the tracer
+// to use for tracing function invocations.
+func WithTracer(t *apm.Tracer) Option {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(o *options) {
+		o.tracer = t
+	}
+}
@@ -0,0 +1,226 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmlambda_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmlambda"
+)
+
+func TestWrapHandlerTriggers(t *testing.T) {
+	traceID := model.TraceID{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c}
+	parentID := model.SpanID{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31}
+
+	type test struct {
+		event       string
+		name        string
+		txType      string
+		trigger     model.FaaSTrigger
+		traceParent bool
+	}
+	for _, test := range []test{{
+		event:       "apigateway.json",
+		name:        "GET /prod/pets/{id}",
+		txType:      "request",
+		trigger:     model.FaaSTrigger{Type: "http", RequestID: "41b45ea3-70b5-11e6-b7bd-69b5aaebc7d9"},
+		traceParent: true,
+	}, {
+		event:   "alb.json",
+		name:    "POST /orders",
+		txType:  "request",
+		trigger: model.FaaSTrigger{Type: "http"},
+	}, {
+		event:       "sqs.json",
+		name:        "RECEIVE MyQueue",
+		txType:      "messaging",
+		trigger:     model.FaaSTrigger{Type: "pubsub", RequestID: "19dd0b57-b21e-4ac1-bd88-01bbb068cb78"},
+		traceParent: true,
+	}, {
+		event:       "sns.json",
+		name:        "RECEIVE MyTopic",
+		txType:      "messaging",
+		trigger:     model.FaaSTrigger{Type: "pubsub", RequestID: "95df01b4-ee98-5cb9-9903-4c221d41eb5e"},
+		traceParent: true,
+	}, {
+		event:   "s3.json",
+		name:    "ObjectCreated:Put lambda-artifacts-deafc19498e3f2df",
+		txType:  "request",
+		trigger: model.FaaSTrigger{Type: "datasource", RequestID: "D82B88E5F771F645"},
+	}, {
+		event:   "eventbridge.json",
+		name:    "aws.rds RDS DB Instance Event",
+		txType:  "messaging",
+		trigger: model.FaaSTrigger{Type: "pubsub", RequestID: "fe8d3c65-xmpl-c5c3-2c87-81584709a377"},
+	}} {
+		t.Run(test.event, func(t *testing.T) {
+			payload, err := ioutil.ReadFile(filepath.Join("testdata", test.event))
+			require.NoError(t, err)
+
+			tracer := apmtest.NewRecordingTracer()
+			defer tracer.Close()
+			h := apmlambda.WrapHandler(func() error { return nil }, apmlambda.WithTracer(tracer.Tracer))
+			_, err = h.Invoke(lambdaContext("request-id"), payload)
+			require.NoError(t, err)
+			tracer.Flush(nil)
+
+			payloads := tracer.Payloads()
+			require.Len(t, payloads.Transactions, 1)
+			tx := payloads.Transactions[0]
+			assert.Equal(t, test.name, tx.Name)
+			assert.Equal(t, test.txType, tx.Type)
+			require.NotNil(t, tx.FaaS)
+			assert.Equal(t, test.trigger, tx.FaaS.Trigger)
+			assert.Equal(t, "request-id", tx.FaaS.Execution)
+			if test.traceParent {
+				assert.Equal(t, traceID, tx.TraceID)
+				assert.Equal(t, parentID, tx.ParentID)
+			} else {
+				assert.NotEqual(t, traceID, tx.TraceID)
+				assert.Zero(t, tx.ParentID)
+			}
+		})
+	}
+}
+
+func TestWrapHandlerUnrecognisedEvent(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	h := apmlambda.WrapHandler(func(s string) (string, error) { return s, nil }, apmlambda.WithTracer(tracer.Tracer))
+	response, err := h.Invoke(context.Background(), []byte(`"hello"`))
+	require.NoError(t, err)
+	assert.Equal(t, `"hello"`, string(response))
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	tx := payloads.Transactions[0]
+	assert.Equal(t, lambdacontext.FunctionName, tx.Name)
+	assert.Equal(t, "request", tx.Type)
+	assert.Equal(t, "success", tx.Result)
+	assert.Equal(t, "success", tx.Outcome)
+	assert.Equal(t, &model.FaaS{Coldstart: true, Trigger: model.FaaSTrigger{Type: "other"}}, tx.FaaS)
+}
+
+func TestWrapHandlerContext(t *testing.T) {
+	payload, err := ioutil.ReadFile(filepath.Join("testdata", "apigateway.json"))
+	require.NoError(t, err)
+
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	var baggage apm.Baggage
+	h := apmlambda.WrapHandler(func(ctx context.Context) (map[string]interface{}, error) {
+		baggage = apm.BaggageFromContext(ctx)
+		span, _ := apm.StartSpan(ctx, "name", "type")
+		span.End()
+		return map[string]interface{}{"statusCode": 404}, nil
+	}, apmlambda.WithTracer(tracer.Tracer))
+	_, err = h.Invoke(context.Background(), payload)
+	require.NoError(t, err)
+	tracer.Flush(nil)
+
+	value, ok := baggage.Value("tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", value)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 1)
+	tx := payloads.Transactions[0]
+	assert.Equal(t, tx.ID, payloads.Spans[0].ParentID)
+	assert.Equal(t, "HTTP 4xx", tx.Result)
+	assert.Equal(t, "success", tx.Outcome)
+	require.NotNil(t, tx.Context)
+	require.NotNil(t, tx.Context.Request)
+	require.NotNil(t, tx.Context.Response)
+	assert.Equal(t, "GET", tx.Context.Request.Method)
+	assert.Equal(t, "https://abc123.execute-api.us-east-1.amazonaws.com/pets/123?verbose=true", tx.Context.Request.URL.Full)
+	assert.Equal(t, 404, tx.Context.Response.StatusCode)
+}
+
+func TestWrapHandlerColdstart(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	h := apmlambda.WrapHandler(func() {}, apmlambda.WithTracer(tracer.Tracer))
+	for i := 0; i < 3; i++ {
+		_, err := h.Invoke(context.Background(), []byte("{}"))
+		require.NoError(t, err)
+	}
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 3)
+	assert.True(t, payloads.Transactions[0].FaaS.Coldstart)
+	assert.False(t, payloads.Transactions[1].FaaS.Coldstart)
+	assert.False(t, payloads.Transactions[2].FaaS.Coldstart)
+}
+
+func TestWrapHandlerError(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	h := apmlambda.WrapHandler(func() error {
+		return errors.New("boom")
+	}, apmlambda.WithTracer(tracer.Tracer))
+	_, err := h.Invoke(context.Background(), []byte("{}"))
+	assert.EqualError(t, err, "boom")
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Errors, 1)
+	tx := payloads.Transactions[0]
+	assert.Equal(t, "failure", tx.Result)
+	assert.Equal(t, "failure", tx.Outcome)
+	assert.Equal(t, tx.ID, payloads.Errors[0].TransactionID)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+}
+
+func TestWrapHandlerPanic(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	h := apmlambda.WrapHandler(func() {
+		panic("boom")
+	}, apmlambda.WithTracer(tracer.Tracer))
+	assert.PanicsWithValue(t, "boom", func() {
+		h.Invoke(context.Background(), []byte("{}"))
+	})
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "failure", payloads.Transactions[0].Outcome)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+}
+
+func lambdaContext(requestID string) context.Context {
+	return lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{
+		AwsRequestID: requestID,
+	})
+}
@@ -22,6 +22,7 @@ import (
 	"net"
 	"net/rpc"
 	"os"
+	"sync/atomic"
 	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/lambda/messages"
@@ -72,8 +73,12 @@ func (f *Function) Ping(req *messages.PingRequest, response *messages.PingRespon
 	return f.client.Call("Function.Ping", req, response)
 }
 
-// Invoke invokes the Lambda function. This is our main trace point.
+// Invoke invokes the Lambda function. This is our main trace point,
+// unless the handler has been wrapped with WrapHandler.
 func (f *Function) Invoke(req *messages.InvokeRequest, response *messages.InvokeResponse) error {
+	if atomic.LoadInt32(&handlerWrapped) != 0 {
+		return f.client.Call("Function.Invoke", req, response)
+	}
 	tx := f.tracer.StartTransaction(lambdacontext.FunctionName, "function")
 	defer f.tracer.Flush(nonBlocking)
 	defer tx.End()
@@ -167,6 +172,3 @@ func init() {
 	// we don't use it.
 	os.Setenv("_LAMBDA_SERVER_PORT", "0")
 }
-
-// TODO(axw) Start() function, which wraps a given function
-// such that its context is updated with the transaction.
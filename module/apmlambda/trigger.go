@@ -0,0 +1,222 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmlambda
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.elastic.co/apm"
+)
+
+// trigger holds information about the event that triggered
+// a function invocation.
+type trigger struct {
+	name            string
+	transactionType string
+	triggerType     string
+	requestID       string
+	traceContext    apm.TraceContext
+	baggage         apm.Baggage
+
+	// request holds the HTTP request described by an
+	// API Gateway or Application Load Balancer event.
+	request *http.Request
+}
+
+// event holds the union of the event fields used for identifying
+// triggers. A single type is used so that the payload is decoded
+// only once, regardless of the event source.
+//
+// Note that encoding/json matches field names case-insensitively,
+// which means eventRecord covers both SQS/S3 records ("eventSource")
+// and SNS records ("EventSource").
+type event struct {
+	// API Gateway and Application Load Balancer.
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	RequestContext                  struct {
+		RequestID    string    `json:"requestId"`
+		Stage        string    `json:"stage"`
+		ResourcePath string    `json:"resourcePath"`
+		ELB          *struct{} `json:"elb"`
+	} `json:"requestContext"`
+
+	// SQS, SNS and S3.
+	Records []eventRecord `json:"Records"`
+
+	// EventBridge.
+	ID         string `json:"id"`
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+}
+
+type eventRecord struct {
+	EventSource       string                         `json:"eventSource"`
+	EventSourceARN    string                         `json:"eventSourceARN"`
+	EventName         string                         `json:"eventName"`
+	MessageID         string                         `json:"messageId"`
+	MessageAttributes map[string]sqsMessageAttribute `json:"messageAttributes"`
+	ResponseElements  map[string]string              `json:"responseElements"`
+
+	SNS *struct {
+		MessageID         string                         `json:"MessageId"`
+		TopicArn          string                         `json:"TopicArn"`
+		MessageAttributes map[string]snsMessageAttribute `json:"MessageAttributes"`
+	} `json:"Sns"`
+
+	S3 *struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+	} `json:"s3"`
+}
+
+type sqsMessageAttribute struct {
+	DataType    string `json:"dataType"`
+	StringValue string `json:"stringValue"`
+}
+
+type snsMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// parseTrigger identifies the trigger of a function invocation from
+// its payload, extracting trace context using propagator.
+func parseTrigger(payload []byte, propagator apm.Propagator) trigger {
+	t := trigger{transactionType: "request", triggerType: "other"}
+	var e event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		// The payload need not be a JSON object.
+		return t
+	}
+	switch {
+	case e.HTTPMethod != "":
+		t.parseHTTP(&e, propagator)
+	case len(e.Records) != 0:
+		t.parseRecords(e.Records, propagator)
+	case e.Source != "" && e.DetailType != "":
+		t.name = e.Source + " " + e.DetailType
+		t.transactionType = "messaging"
+		t.triggerType = "pubsub"
+		t.requestID = e.ID
+	}
+	return t
+}
+
+func (t *trigger) parseHTTP(e *event, propagator apm.Propagator) {
+	header := make(http.Header)
+	for k, v := range e.Headers {
+		header.Set(k, v)
+	}
+	for k, values := range e.MultiValueHeaders {
+		header.Del(k)
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+	query := make(url.Values)
+	for k, v := range e.QueryStringParameters {
+		query.Set(k, v)
+	}
+	for k, values := range e.MultiValueQueryStringParameters {
+		query[k] = values
+	}
+	t.request = &http.Request{
+		Method:     e.HTTPMethod,
+		URL:        &url.URL{Path: e.Path, RawQuery: query.Encode()},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       header.Get("Host"),
+	}
+
+	t.transactionType = "request"
+	t.triggerType = "http"
+	if e.RequestContext.ELB != nil {
+		// Application Load Balancer events do not
+		// identify the route, so use the path.
+		t.name = e.HTTPMethod + " " + e.Path
+	} else {
+		t.name = e.HTTPMethod + " /" + e.RequestContext.Stage + e.RequestContext.ResourcePath
+		t.requestID = e.RequestContext.RequestID
+	}
+	carrier := apm.HTTPHeaderCarrier(header)
+	if traceContext, err := propagator.Extract(carrier); err == nil {
+		t.traceContext = traceContext
+	}
+	t.baggage = apm.ExtractBaggage(carrier)
+}
+
+func (t *trigger) parseRecords(records []eventRecord, propagator apm.Propagator) {
+	record := &records[0]
+	header := make(http.Header)
+	switch {
+	case record.EventSource == "aws:sqs":
+		t.name = "RECEIVE " + arnResource(record.EventSourceARN)
+		t.transactionType = "messaging"
+		t.triggerType = "pubsub"
+		t.requestID = record.MessageID
+		for k, attr := range record.MessageAttributes {
+			if attr.DataType == "String" {
+				header.Set(k, attr.StringValue)
+			}
+		}
+	case record.EventSource == "aws:sns" && record.SNS != nil:
+		t.name = "RECEIVE " + arnResource(record.SNS.TopicArn)
+		t.transactionType = "messaging"
+		t.triggerType = "pubsub"
+		t.requestID = record.SNS.MessageID
+		for k, attr := range record.SNS.MessageAttributes {
+			if attr.Type == "String" {
+				header.Set(k, attr.Value)
+			}
+		}
+	case record.EventSource == "aws:s3" && record.S3 != nil:
+		t.name = record.EventName + " " + record.S3.Bucket.Name
+		t.transactionType = "request"
+		t.triggerType = "datasource"
+		t.requestID = record.ResponseElements["x-amz-request-id"]
+		return
+	default:
+		return
+	}
+	if len(records) > 1 {
+		// A batch of messages may originate from multiple
+		// traces, so we do not continue any one of them.
+		t.requestID = ""
+		return
+	}
+	if traceContext, err := propagator.Extract(apm.HTTPHeaderCarrier(header)); err == nil {
+		t.traceContext = traceContext
+	}
+}
+
+// arnResource returns the resource name of an ARN, e.g.
+// the queue name of an SQS queue ARN.
+func arnResource(arn string) string {
+	return arn[strings.LastIndexByte(arn, ':')+1:]
+}
@@ -49,6 +49,9 @@ func init() {
 // to the APM Server. If TraceContext is used to add trace IDs
 // to the log records, the errors reported will be associated
 // with them.
+//
+// If LogEventMinLevel is set, log records at or above that level will
+// also be sent to the APM Server as log events, with their fields.
 type Hook struct {
 	// Tracer is the apm.Tracer to use for reporting errors.
 	// If Tracer is nil, then apm.DefaultTracer will be used.
@@ -59,6 +62,11 @@ type Hook struct {
 	// be used.
 	LogLevels []logrus.Level
 
+	// LogEventMinLevel holds the minimum level of logs to send as
+	// log events, using apm.Tracer.SendLog. If LogEventMinLevel is
+	// nil, which is the default, no log events will be sent.
+	LogEventMinLevel *logrus.Level
+
 	// FatalFlushTimeout is the amount of time to wait while
 	// flushing a fatal log message to the APM Server before
 	// the process is exited. If this is 0, then
@@ -75,8 +83,7 @@ func (h *Hook) tracer() *apm.Tracer {
 	return tracer
 }
 
-// Levels returns h.LogLevels, satisfying the logrus.Hook interface.
-func (h *Hook) Levels() []logrus.Level {
+func (h *Hook) errorLevels() []logrus.Level {
 	levels := h.LogLevels
 	if levels == nil {
 		levels = DefaultLogLevels
@@ -84,12 +91,37 @@ func (h *Hook) Levels() []logrus.Level {
 	return levels
 }
 
-// Fire reports the log entry as an error to the APM Server.
+// Levels returns the union of h.LogLevels and the levels at or
+// above h.LogEventMinLevel, satisfying the logrus.Hook interface.
+func (h *Hook) Levels() []logrus.Level {
+	levels := h.errorLevels()
+	for _, level := range logrus.AllLevels {
+		if h.logEventEnabled(level) && !containsLevel(levels, level) {
+			levels = append(levels[:len(levels):len(levels)], level)
+		}
+	}
+	return levels
+}
+
+// logEventEnabled reports whether log records with the given
+// level should be sent as log events. Logrus levels are ordered
+// from most to least severe.
+func (h *Hook) logEventEnabled(level logrus.Level) bool {
+	return h.LogEventMinLevel != nil && level <= *h.LogEventMinLevel
+}
+
+// Fire reports the log entry as an error and/or log event to the APM Server.
 func (h *Hook) Fire(entry *logrus.Entry) error {
 	tracer := h.tracer()
 	if !tracer.Recording() {
 		return nil
 	}
+	if h.logEventEnabled(entry.Level) {
+		sendLog(tracer, entry)
+	}
+	if !containsLevel(h.errorLevels(), entry.Level) {
+		return nil
+	}
 
 	err, _ := entry.Data[logrus.ErrorKey].(error)
 	errlog := tracer.NewErrorLog(apm.ErrorLogRecord{
@@ -130,3 +162,39 @@ func (h *Hook) Fire(entry *logrus.Entry) error {
 	}
 	return nil
 }
+
+func sendLog(tracer *apm.Tracer, entry *logrus.Entry) {
+	record := apm.LogRecord{
+		Timestamp: entry.Time,
+		Message:   entry.Message,
+		Level:     entry.Level.String(),
+	}
+	for k, v := range entry.Data {
+		switch k {
+		case FieldKeyTraceID:
+			record.TraceID, _ = v.(apm.TraceID)
+		case FieldKeyTransactionID:
+			record.TransactionID, _ = v.(apm.SpanID)
+		case FieldKeySpanID:
+			record.SpanID, _ = v.(apm.SpanID)
+		default:
+			if record.Fields == nil {
+				record.Fields = make(map[string]interface{}, len(entry.Data))
+			}
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			record.Fields[k] = v
+		}
+	}
+	tracer.SendLog(record)
+}
+
+func containsLevel(levels []logrus.Level, level logrus.Level) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
@@ -94,6 +94,50 @@ func TestHookTransactionTraceContext(t *testing.T) {
 	assert.Equal(t, payloads.Transactions[0].ID, err0.TransactionID)
 }
 
+func TestHookLogEventMinLevel(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	logger := newLogger(ioutil.Discard)
+	logger.SetLevel(logrus.DebugLevel)
+	logEventMinLevel := logrus.InfoLevel
+	logger.AddHook(&apmlogrus.Hook{
+		Tracer:           tracer,
+		LogEventMinLevel: &logEventMinLevel,
+	})
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	span, ctx := apm.StartSpan(ctx, "name", "type")
+	entry := logger.WithFields(apmlogrus.TraceContext(ctx)).WithField("component", "test")
+	entry.Debug("debug")
+	entry.WithField("attempt", 2).Info("info")
+	entry.Error("error")
+	span.End()
+	tx.End()
+
+	tracer.Flush(nil)
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Logs, 2)
+	require.Len(t, payloads.Errors, 1)
+
+	log0 := payloads.Logs[0]
+	assert.Equal(t, "info", log0.Message)
+	assert.Equal(t, "info", log0.Level)
+	assert.Equal(t, model.TraceID(tx.TraceContext().Trace), log0.TraceID)
+	assert.Equal(t, model.SpanID(tx.TraceContext().Span), log0.TransactionID)
+	assert.Equal(t, model.SpanID(span.TraceContext().Span), log0.SpanID)
+	assert.Equal(t, model.IfaceMap{
+		{Key: "attempt", Value: 2.0},
+		{Key: "component", Value: "test"},
+	}, log0.Labels)
+
+	log1 := payloads.Logs[1]
+	assert.Equal(t, "error", log1.Message)
+	assert.Equal(t, "error", log1.Level)
+	assert.Equal(t, "error", payloads.Errors[0].Log.Message)
+}
+
 func TestHookWithError(t *testing.T) {
 	tracer, transport := transporttest.NewRecorderTracer()
 	defer tracer.Close()
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmnats
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// replyDestination is the destination recorded for spans
+// reporting replies sent with Conn.Respond.
+const replyDestination = "_INBOX"
+
+// WrapConn wraps nc such that messages published and requests made with
+// a context containing a transaction are traced, and trace context is
+// propagated through message headers.
+//
+// Trace context is propagated only if the server supports headers.
+func WrapConn(nc *nats.Conn, o ...Option) *Conn {
+	return &Conn{Conn: nc, opts: newOptions(o...)}
+}
+
+// Conn wraps a *nats.Conn, reporting spans for messages published and
+// requests made within a transaction, and reporting a transaction for
+// each message received by subscription handlers.
+type Conn struct {
+	*nats.Conn
+	opts options
+}
+
+// Publish publishes data to subj, reporting a span within the
+// transaction in ctx, if any.
+func (c *Conn) Publish(ctx context.Context, subj string, data []byte) error {
+	return c.PublishMsg(ctx, &nats.Msg{Subject: subj, Data: data})
+}
+
+// PublishMsg publishes msg, reporting a span within the transaction
+// in ctx, if any, and propagating trace context through the header of
+// the published message. msg and its header are not modified.
+func (c *Conn) PublishMsg(ctx context.Context, msg *nats.Msg) error {
+	span, msg := c.startSpan(ctx, "SEND", msg.Subject, msg)
+	err := c.Conn.PublishMsg(msg)
+	apmmessaging.EndSpan(span, err)
+	return err
+}
+
+// Respond publishes data in reply to msg, reporting a span within the
+// transaction in ctx, if any. Respond should be used in place of
+// msg.Respond to propagate trace context to the requester.
+//
+// Reply subjects are typically unique inboxes, so the span is named
+// and its destination recorded as "_INBOX" rather than the subject.
+func (c *Conn) Respond(ctx context.Context, msg *nats.Msg, data []byte) error {
+	if msg.Reply == "" {
+		return nats.ErrMsgNoReply
+	}
+	span, reply := c.startSpan(ctx, "SEND", replyDestination, &nats.Msg{Subject: msg.Reply, Data: data})
+	err := c.Conn.PublishMsg(reply)
+	apmmessaging.EndSpan(span, err)
+	return err
+}
+
+// Request sends data to subj and waits for a response, reporting a span
+// within the transaction in ctx, if any. The request is bounded by ctx,
+// which must have a deadline.
+func (c *Conn) Request(ctx context.Context, subj string, data []byte) (*nats.Msg, error) {
+	return c.RequestMsg(ctx, &nats.Msg{Subject: subj, Data: data})
+}
+
+// RequestMsg sends msg and waits for a response, reporting a span within
+// the transaction in ctx, if any, and propagating trace context through
+// the header of the sent message. msg and its header are not modified.
+// The request is bounded by ctx, which must have a deadline.
+func (c *Conn) RequestMsg(ctx context.Context, msg *nats.Msg) (*nats.Msg, error) {
+	span, msg := c.startSpan(ctx, "REQUEST", msg.Subject, msg)
+	response, err := c.Conn.RequestMsgWithContext(ctx, msg)
+	apmmessaging.EndSpan(span, err)
+	return response, err
+}
+
+// Subscribe subscribes to subj, calling h for each message received
+// within a transaction. See WrapMsgHandler for details.
+func (c *Conn) Subscribe(subj string, h MsgHandler) (*nats.Subscription, error) {
+	return c.Conn.Subscribe(subj, wrapMsgHandler(h, c.opts))
+}
+
+// QueueSubscribe subscribes to subj as a member of the queue group,
+// calling h for each message received within a transaction. See
+// WrapMsgHandler for details.
+func (c *Conn) QueueSubscribe(subj, queue string, h MsgHandler) (*nats.Subscription, error) {
+	return c.Conn.QueueSubscribe(subj, queue, wrapMsgHandler(h, c.opts))
+}
+
+// startSpan starts a span for sending msg to dest, if ctx contains a
+// transaction, and returns the message to send in place of msg. If the
+// server supports headers, the returned message is a copy of msg with
+// trace context injected into a copy of its header; the caller's header
+// may be shared by other messages, so it is not modified. If ctx does not
+// contain a transaction, startSpan returns a nil span and msg.
+func (c *Conn) startSpan(ctx context.Context, action, dest string, msg *nats.Msg) (*apm.Span, *nats.Msg) {
+	tx := apm.TransactionFromContext(ctx)
+	if tx == nil {
+		return nil, msg
+	}
+	var carrier apm.PropagationCarrier
+	if c.Conn.HeadersSupported() {
+		header := make(nats.Header, len(msg.Header)+2)
+		for k, v := range msg.Header {
+			header[k] = v
+		}
+		m := *msg
+		m.Header = header
+		msg = &m
+		carrier = apmmessaging.Carrier{Headers: headers(header)}
+	}
+	return apmmessaging.StartSpan(ctx, tx, "NATS", "nats", action, dest, carrier), msg
+}
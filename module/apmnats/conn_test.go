@@ -0,0 +1,221 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmnats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmnats"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	serverTracer := apmtest.NewRecordingTracer()
+	defer serverTracer.Close()
+	conn := newConn(t, apmnats.WithTracer(serverTracer.Tracer))
+
+	received := make(chan context.Context, 1)
+	sub, err := conn.Subscribe("orders.*", func(ctx context.Context, msg *nats.Msg) {
+		received <- ctx
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	require.NoError(t, conn.Flush())
+
+	tx, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		ctx = apm.ContextWithBaggage(ctx, apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme"}))
+		require.NoError(t, conn.Publish(ctx, "orders.new", []byte("hello")))
+	})
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "NATS SEND to orders.new", span.Name)
+	assert.Equal(t, "messaging", span.Type)
+	assert.Equal(t, "nats", span.Subtype)
+	assert.Equal(t, "send", span.Action)
+	assert.Equal(t, "success", span.Outcome)
+	assert.Equal(t, &model.DestinationServiceSpanContext{
+		Type:     "messaging",
+		Name:     "nats",
+		Resource: "nats/orders.new",
+	}, span.Context.Destination.Service)
+
+	var ctx context.Context
+	select {
+	case ctx = <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	assert.NotNil(t, apm.TransactionFromContext(ctx))
+	value, _ := apm.BaggageFromContext(ctx).Value("tenant")
+	assert.Equal(t, "acme", value)
+
+	serverTracer.Flush(nil)
+	payloads := serverTracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	serverTx := payloads.Transactions[0]
+	assert.Equal(t, "NATS RECEIVE from orders.*", serverTx.Name)
+	assert.Equal(t, "messaging", serverTx.Type)
+	assert.Equal(t, "success", serverTx.Outcome)
+	assert.Equal(t, tx.TraceID, serverTx.TraceID)
+	assert.Equal(t, span.ID, serverTx.ParentID)
+	assert.Equal(t, &model.MessageContext{
+		Queue: &model.MessageQueueContext{Name: "orders.*"},
+	}, serverTx.Context.Message)
+}
+
+func TestRequestReply(t *testing.T) {
+	serverTracer := apmtest.NewRecordingTracer()
+	defer serverTracer.Close()
+	conn := newConn(t, apmnats.WithTracer(serverTracer.Tracer))
+
+	sub, err := conn.QueueSubscribe("greet", "greeters", func(ctx context.Context, msg *nats.Msg) {
+		assert.NoError(t, conn.Respond(ctx, msg, append([]byte("hello, "), msg.Data...)))
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	require.NoError(t, conn.Flush())
+
+	tx, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		response, err := conn.Request(ctx, "greet", []byte("world"))
+		require.NoError(t, err)
+		assert.Equal(t, "hello, world", string(response.Data))
+	})
+	require.Len(t, spans, 1)
+	assert.Equal(t, "NATS REQUEST to greet", spans[0].Name)
+	assert.Equal(t, "request", spans[0].Action)
+	assert.Equal(t, "success", spans[0].Outcome)
+
+	serverTracer.Flush(nil)
+	payloads := serverTracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 1)
+	assert.Equal(t, "NATS RECEIVE from greet", payloads.Transactions[0].Name)
+	assert.Equal(t, tx.TraceID, payloads.Transactions[0].TraceID)
+	assert.Equal(t, spans[0].ID, payloads.Transactions[0].ParentID)
+	assert.Equal(t, "NATS SEND to _INBOX", payloads.Spans[0].Name)
+	assert.Equal(t, "success", payloads.Spans[0].Outcome)
+	assert.Equal(t, "nats/_INBOX", payloads.Spans[0].Context.Destination.Service.Resource)
+	assert.Equal(t, "_INBOX", payloads.Spans[0].Context.Message.Queue.Name)
+}
+
+func TestRequestNoResponders(t *testing.T) {
+	conn := newConn(t)
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err := conn.Request(ctx, "nobody.home", nil)
+		assert.Error(t, err)
+	})
+	require.Len(t, spans, 1)
+	assert.Equal(t, "failure", spans[0].Outcome)
+}
+
+func TestPublishNoTransaction(t *testing.T) {
+	conn := newConn(t)
+	msg := &nats.Msg{Subject: "orders.new"}
+	require.NoError(t, conn.PublishMsg(context.Background(), msg))
+	assert.Nil(t, msg.Header)
+}
+
+func TestPublishMsgSharedHeader(t *testing.T) {
+	conn := newConn(t)
+
+	received := make(chan *nats.Msg, 2)
+	sub, err := conn.Conn.ChanSubscribe("orders.*", received)
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	require.NoError(t, conn.Flush())
+
+	// Messages may share a header; injecting trace
+	// context must not modify it.
+	header := nats.Header{"X-Custom": []string{"value"}}
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		require.NoError(t, conn.PublishMsg(ctx, &nats.Msg{Subject: "orders.new", Header: header}))
+		require.NoError(t, conn.PublishMsg(ctx, &nats.Msg{Subject: "orders.old", Header: header}))
+	})
+	require.Len(t, spans, 2)
+	assert.Equal(t, nats.Header{"X-Custom": []string{"value"}}, header)
+
+	for i := range spans {
+		var msg *nats.Msg
+		select {
+		case msg = <-received:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+		assert.Equal(t, []string{"value"}, msg.Header["X-Custom"])
+		assert.Equal(t, []string{apm.FormatTraceparentHeader(apm.TraceContext{
+			Trace:   apm.TraceID(spans[i].TraceID),
+			Span:    apm.SpanID(spans[i].ID),
+			Options: apm.TraceOptions(0).WithRecorded(true),
+		})}, msg.Header["traceparent"])
+	}
+}
+
+func TestWrapMsgHandlerPanic(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	h := apmnats.WrapMsgHandler(func(ctx context.Context, msg *nats.Msg) {
+		panic("boom")
+	}, apmnats.WithTracer(tracer.Tracer))
+	assert.PanicsWithValue(t, "boom", func() {
+		h(&nats.Msg{Subject: "orders.new"})
+	})
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "NATS RECEIVE from orders.new", payloads.Transactions[0].Name)
+	assert.Equal(t, "failure", payloads.Transactions[0].Outcome)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+}
+
+// newConn runs an in-process NATS server, and returns a traced
+// connection to it. The server and connection are closed when
+// the test completes.
+func newConn(t *testing.T, o ...apmnats.Option) *apmnats.Conn {
+	s, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	require.NoError(t, err)
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("NATS server not ready for connections")
+	}
+	t.Cleanup(s.Shutdown)
+
+	nc, err := nats.Connect(s.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	return apmnats.WrapConn(nc, o...)
+}
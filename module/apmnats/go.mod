@@ -0,0 +1,12 @@
+module go.elastic.co/apm/module/apmnats
+
+require (
+	github.com/nats-io/nats-server/v2 v2.2.6
+	github.com/nats-io/nats.go v1.11.0
+	github.com/stretchr/testify v1.4.0
+	go.elastic.co/apm v1.8.0
+)
+
+replace go.elastic.co/apm => ../..
+
+go 1.14
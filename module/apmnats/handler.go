@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmnats
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// MsgHandler is a message handler which accepts a context, through which
+// the transaction for the received message is made available.
+type MsgHandler func(ctx context.Context, msg *nats.Msg)
+
+// WrapMsgHandler returns a nats.MsgHandler which calls h for each message
+// within a transaction, continuing the trace propagated through the message
+// header, if any.
+//
+// The transaction is named after the subscription subject, which may contain
+// wildcards, and records the subject as the message queue name. If h panics,
+// the panic is reported as an error before the panic continues.
+func WrapMsgHandler(h MsgHandler, o ...Option) nats.MsgHandler {
+	return wrapMsgHandler(h, newOptions(o...))
+}
+
+func wrapMsgHandler(h MsgHandler, opts options) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		subject := msg.Subject
+		if msg.Sub != nil && msg.Sub.Subject != "" {
+			subject = msg.Sub.Subject
+		}
+		carrier := apmmessaging.Carrier{Headers: headers(msg.Header)}
+		tx, ctx := apmmessaging.StartTransaction(context.Background(), opts.tracer, "NATS", subject, time.Now(), carrier)
+		defer tx.End()
+		if tx.Sampled() {
+			tx.Context.SetMessage(apm.MessageContext{QueueName: subject})
+		}
+		apmmessaging.Process(opts.tracer, tx, func() { h(ctx, msg) })
+	}
+}
+
+// Option sets options for tracing NATS subscription handlers.
+type Option func(*options)
+
+type options struct {
+	tracer *apm.Tracer
+}
+
+func newOptions(o ...Option) options {
+	opts := options{tracer: apm.DefaultTracer}
+	for _, o := range o {
+		o(&opts)
+	}
+	return opts
+}
+
+// WithTracer returns an Option which sets t as the tracer
+// to use for tracing subscription handlers.
+func WithTracer(t *apm.Tracer) Option {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(o *options) {
+		o.tracer = t
+	}
+}
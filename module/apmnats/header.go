@@ -0,0 +1,45 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmnats
+
+import "github.com/nats-io/nats.go"
+
+// headers is apmmessaging.Headers backed by nats.Header.
+type headers nats.Header
+
+// Keys calls f with each key in h.
+func (h headers) Keys(f func(key string)) {
+	for k := range h {
+		f(k)
+	}
+}
+
+// Values returns the values for key in h.
+func (h headers) Values(key string) []string {
+	return h[key]
+}
+
+// Set sets the value for key in h.
+func (h headers) Set(key, value string) {
+	h[key] = []string{value}
+}
+
+// Del deletes key from h.
+func (h headers) Del(key string) {
+	delete(h, key)
+}
@@ -0,0 +1,53 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmot
+
+import (
+	"sort"
+
+	"go.elastic.co/apm"
+)
+
+// otBaggageHeaderPrefix is the prefix of the headers used by
+// OpenTracing tracers to propagate individual baggage items.
+const otBaggageHeaderPrefix = "Ot-Baggage-"
+
+// copyBaggage returns a copy of baggage with the additional key/value pair.
+func copyBaggage(baggage map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(baggage)+1)
+	for k, v := range baggage {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
+
+// makeBaggage returns apm.Baggage holding the items of baggage,
+// ordered by key so that the propagated baggage is deterministic.
+func makeBaggage(baggage map[string]string) apm.Baggage {
+	keys := make([]string, 0, len(baggage))
+	for k := range baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	members := make([]apm.BaggageMember, len(keys))
+	for i, k := range keys {
+		members[i] = apm.BaggageMember{Key: k, Value: baggage[k]}
+	}
+	return apm.NewBaggage(members...)
+}
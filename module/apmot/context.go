@@ -18,6 +18,7 @@
 package apmot
 
 import (
+	"sync"
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
@@ -31,6 +32,9 @@ type spanContext struct {
 	tx           *apm.Transaction
 	traceContext apm.TraceContext
 	startTime    time.Time
+
+	baggageMu sync.RWMutex
+	baggage   map[string]string // copied on write
 }
 
 // TraceContext returns the trace context for the transaction or span
@@ -44,8 +48,56 @@ func (s *spanContext) Transaction() *apm.Transaction {
 	return s.tx
 }
 
-// ForeachBaggageItem is a no-op; we do not support baggage propagation.
-func (*spanContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+// ForeachBaggageItem calls handler for each baggage item in the span
+// context, until handler returns false.
+func (s *spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	foreachBaggageItem(s.getBaggage(), handler)
+}
+
+// getBaggage returns the span context's baggage, which must not be modified.
+func (s *spanContext) getBaggage() map[string]string {
+	s.baggageMu.RLock()
+	defer s.baggageMu.RUnlock()
+	return s.baggage
+}
+
+func (s *spanContext) baggageItem(key string) string {
+	s.baggageMu.RLock()
+	defer s.baggageMu.RUnlock()
+	return s.baggage[key]
+}
+
+func (s *spanContext) setBaggageItem(key, value string) {
+	s.baggageMu.Lock()
+	defer s.baggageMu.Unlock()
+	s.baggage = copyBaggage(s.baggage, key, value)
+}
+
+// referencedBaggage returns the baggage items of all referenced span contexts.
+func referencedBaggage(refs []opentracing.SpanReference) map[string]string {
+	var baggage map[string]string
+	for _, ref := range refs {
+		if ref.ReferencedContext == nil {
+			continue
+		}
+		ref.ReferencedContext.ForeachBaggageItem(func(k, v string) bool {
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[k] = v
+			return true
+		})
+	}
+	return baggage
+}
+
+func foreachBaggageItem(baggage map[string]string, handler func(k, v string) bool) {
+	for k, v := range baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
 
 func parentSpanContext(refs []opentracing.SpanReference) (*spanContext, bool) {
 	for _, ref := range refs {
@@ -19,6 +19,7 @@
 //
 // Things not implemented by this tracer:
 //  - binary propagation format
-//  - baggage
-//  - logging (generally; errors are reported)
+//
+// Span logs with the "event" field set to "error" are reported as errors;
+// the fields of other span logs are recorded as labels.
 package apmot
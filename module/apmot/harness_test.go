@@ -62,6 +62,7 @@ func TestHarness(t *testing.T) {
 		}
 	}()
 	harness.RunAPIChecks(t, newTracer,
+		harness.CheckBaggageValues(true),
 		harness.CheckExtract(true),
 		harness.CheckInject(true),
 		harness.UseProbe(harnessAPIProbe{}),
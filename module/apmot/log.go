@@ -25,45 +25,66 @@ import (
 	"go.elastic.co/apm"
 )
 
-func logKV(tracer *apm.Tracer, tx *apm.Transaction, span *apm.Span, time time.Time, keyValues []interface{}) {
+// maxLogLabels is the maximum number of distinct log field keys
+// recorded as labels for a span or transaction. Fields with keys
+// beyond this limit are dropped.
+const maxLogLabels = 64
+
+func logKV(tracer *apm.Tracer, tx *apm.Transaction, span *apm.Span, labels *logLabels, time time.Time, keyValues []interface{}) {
 	var ctx logContext
 	for i := 0; i*2 < len(keyValues); i++ {
 		key, ok := keyValues[2*i].(string)
 		if !ok {
 			continue
 		}
-		value := keyValues[2*i+1]
-		if !ctx.field(key, value) {
-			return
-		}
+		ctx.field(key, keyValues[2*i+1])
 	}
-	ctx.emit(tracer, tx, span, time)
+	ctx.emit(tracer, tx, span, labels, time)
 }
 
-func logFields(tracer *apm.Tracer, tx *apm.Transaction, span *apm.Span, time time.Time, fields []log.Field) {
+func logFields(tracer *apm.Tracer, tx *apm.Transaction, span *apm.Span, labels *logLabels, time time.Time, fields []log.Field) {
 	var ctx logContext
 	for _, field := range fields {
-		if !ctx.field(field.Key(), field.Value()) {
-			return
-		}
+		ctx.field(field.Key(), field.Value())
 	}
-	ctx.emit(tracer, tx, span, time)
+	ctx.emit(tracer, tx, span, labels, time)
+}
+
+// logLabels holds the most recent value for each key of the fields
+// of non-error log events, to be recorded as labels when the span
+// or transaction is finished.
+type logLabels map[string]interface{}
+
+// set records value for key, replacing any existing value.
+// New keys are ignored once maxLogLabels keys are recorded.
+func (l *logLabels) set(key string, value interface{}) {
+	if *l == nil {
+		*l = make(logLabels)
+	}
+	if _, ok := (*l)[key]; !ok && len(*l) >= maxLogLabels {
+		return
+	}
+	(*l)[key] = value
 }
 
 type logContext struct {
 	errorEvent bool
 	message    string
 	err        error
+	fields     []logField
+}
+
+type logField struct {
+	key   string
+	value interface{}
 }
 
-// field processes a log field, returning false if the log event should be ignored.
-func (c *logContext) field(key string, value interface{}) bool {
+// field processes a log field.
+func (c *logContext) field(key string, value interface{}) {
+	c.fields = append(c.fields, logField{key: key, value: value})
 	switch key {
 	case "event":
 		c.errorEvent = value == "error"
-		if !c.errorEvent {
-			return false
-		}
 	case "message":
 		if v, ok := value.(string); ok {
 			c.message = v
@@ -78,12 +99,16 @@ func (c *logContext) field(key string, value interface{}) bool {
 			c.err = v
 		}
 	}
-	return true
 }
 
-// emit emits an error log record if the context is complete.
-func (c *logContext) emit(tracer *apm.Tracer, tx *apm.Transaction, span *apm.Span, time time.Time) {
+// emit emits an error log record if the log event is an error, and
+// otherwise records the log fields in labels, to be recorded as span
+// or transaction labels when the span or transaction is finished.
+func (c *logContext) emit(tracer *apm.Tracer, tx *apm.Transaction, span *apm.Span, labels *logLabels, time time.Time) {
 	if !c.errorEvent {
+		for _, field := range c.fields {
+			labels.set(field.key, field.value)
+		}
 		return
 	}
 	if c.message == "" && c.err != nil {
@@ -35,10 +35,11 @@ import (
 type otSpan struct {
 	tracer *otTracer
 
-	mu   sync.Mutex
-	span *apm.Span
-	tags opentracing.Tags
-	ctx  spanContext
+	mu        sync.Mutex
+	span      *apm.Span
+	tags      opentracing.Tags
+	logLabels logLabels
+	ctx       spanContext
 }
 
 // Span returns s.span, the underlying apm.Span. This is used to satisfy
@@ -94,9 +95,10 @@ func (s *otSpan) FinishWithOptions(opts opentracing.FinishOptions) {
 			if timestamp.IsZero() {
 				timestamp = opts.FinishTime
 			}
-			logFields(s.tracer.tracer, nil, s.span, timestamp, record.Fields)
+			logFields(s.tracer.tracer, nil, s.span, &s.logLabels, timestamp, record.Fields)
 		}
 		s.setSpanContext()
+		s.setLogLabels()
 		s.span.End()
 	} else {
 		s.setTransactionContext()
@@ -105,8 +107,9 @@ func (s *otSpan) FinishWithOptions(opts opentracing.FinishOptions) {
 			if timestamp.IsZero() {
 				timestamp = opts.FinishTime
 			}
-			logFields(s.tracer.tracer, s.ctx.tx, nil, timestamp, record.Fields)
+			logFields(s.tracer.tracer, s.ctx.tx, nil, &s.logLabels, timestamp, record.Fields)
 		}
+		s.setLogLabels()
 		s.ctx.tx.End()
 	}
 }
@@ -124,14 +127,16 @@ func (s *otSpan) Context() opentracing.SpanContext {
 	return &s.ctx
 }
 
-// BaggageItem returns the empty string; we do not support baggage.
-func (*otSpan) BaggageItem(key string) string {
-	return ""
+// BaggageItem returns the value of the baggage item with the given key,
+// or the empty string if there is none.
+func (s *otSpan) BaggageItem(key string) string {
+	return s.ctx.baggageItem(key)
 }
 
-// SetBaggageItem is a no-op; we do not support baggage.
+// SetBaggageItem sets a baggage item, which will be propagated to
+// descendant spans, and injected into carriers with Tracer.Inject.
 func (s *otSpan) SetBaggageItem(key, val string) opentracing.Span {
-	// We do not support baggage.
+	s.ctx.setBaggageItem(key, val)
 	return s
 }
 
@@ -279,16 +284,37 @@ func (s *otSpan) setTransactionContext() {
 	}
 }
 
+// setLogLabels records the fields of non-error log events as labels.
+// Tags take precedence over log fields with the same key.
+func (s *otSpan) setLogLabels() {
+	for k, v := range s.logLabels {
+		if _, ok := s.tags[k]; ok {
+			continue
+		}
+		if s.span != nil {
+			s.span.Context.SetLabel(k, v)
+		} else {
+			s.ctx.tx.Context.SetLabel(k, v)
+		}
+	}
+}
+
 // LogKV is part of the opentracing.Span interface.
-// We send error events to Elastic APM.
+// We send error events to Elastic APM, and record
+// the fields of other events as labels.
 func (s *otSpan) LogKV(keyValues ...interface{}) {
-	logKV(s.tracer.tracer, s.ctx.tx, s.span, time.Time{}, keyValues)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logKV(s.tracer.tracer, s.ctx.tx, s.span, &s.logLabels, time.Time{}, keyValues)
 }
 
 // LogFields is part of the opentracing.Span interface.
-// We send error events to Elastic APM.
+// We send error events to Elastic APM, and record
+// the fields of other events as labels.
 func (s *otSpan) LogFields(fields ...log.Field) {
-	logFields(s.tracer.tracer, s.ctx.tx, s.span, time.Time{}, fields)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logFields(s.tracer.tracer, s.ctx.tx, s.span, &s.logLabels, time.Time{}, fields)
 }
 
 // LogEvent is deprecated, and is a no-op.
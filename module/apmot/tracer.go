@@ -19,14 +19,14 @@ package apmot
 
 import (
 	"io"
-	"net/http"
 	"net/textproto"
+	"net/url"
+	"strings"
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
 
 	"go.elastic.co/apm"
-	"go.elastic.co/apm/module/apmhttp"
 )
 
 // New returns a new opentracing.Tracer backed by the supplied
@@ -66,6 +66,7 @@ func (t *otTracer) StartSpanWithOptions(name string, opts opentracing.StartSpanO
 		ctx: spanContext{
 			tracer:    t,
 			startTime: opts.StartTime,
+			baggage:   referencedBaggage(opts.References),
 		},
 	}
 	if opts.StartTime.IsZero() {
@@ -107,13 +108,15 @@ func (t *otTracer) Inject(sc opentracing.SpanContext, format interface{}, carrie
 		if !ok {
 			return opentracing.ErrInvalidCarrier
 		}
-		headerValue := apmhttp.FormatTraceparentHeader(spanContext.traceContext)
-		writer.Set(apmhttp.W3CTraceparentHeader, headerValue)
-		if t.tracer.ShouldPropagateLegacyHeader() {
-			writer.Set(apmhttp.ElasticTraceparentHeader, headerValue)
-		}
-		if tracestate := spanContext.traceContext.State.String(); tracestate != "" {
-			writer.Set(apmhttp.TracestateHeader, tracestate)
+		t.tracer.Propagator().Inject(spanContext.traceContext, textMapCarrier{writer: writer})
+		if baggage := spanContext.getBaggage(); len(baggage) > 0 {
+			for k, v := range baggage {
+				if format == opentracing.HTTPHeaders {
+					v = url.QueryEscape(v)
+				}
+				writer.Set(otBaggageHeaderPrefix+k, v)
+			}
+			apm.InjectBaggage(makeBaggage(baggage), textMapCarrier{writer: writer})
 		}
 		return nil
 	case opentracing.Binary:
@@ -130,44 +133,55 @@ func (t *otTracer) Inject(sc opentracing.SpanContext, format interface{}, carrie
 func (t *otTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
 	switch format {
 	case opentracing.TextMap, opentracing.HTTPHeaders:
-		var traceparentHeaderValue string
-		var tracestateHeaderValues []string
-		switch carrier := carrier.(type) {
-		case opentracing.HTTPHeadersCarrier:
-			traceparentHeaderValue = http.Header(carrier).Get(apmhttp.ElasticTraceparentHeader)
-			if traceparentHeaderValue == "" {
-				traceparentHeaderValue = http.Header(carrier).Get(apmhttp.W3CTraceparentHeader)
-			}
-			tracestateHeaderValues = http.Header(carrier)[apmhttp.TracestateHeader]
-		case opentracing.TextMapReader:
-			carrier.ForeachKey(func(key, val string) error {
-				switch textproto.CanonicalMIMEHeaderKey(key) {
-				case apmhttp.ElasticTraceparentHeader:
-					traceparentHeaderValue = val
-				case apmhttp.W3CTraceparentHeader:
-					// The Elastic header value always trumps the W3C one,
-					// to ensure backwards compatibility, hence we only set
-					// the value if not set already.
-					if traceparentHeaderValue == "" {
-						traceparentHeaderValue = val
-					}
-				case apmhttp.TracestateHeader:
-					tracestateHeaderValues = append(tracestateHeaderValues, val)
-				}
-				return nil
-			})
-		default:
+		reader, ok := carrier.(opentracing.TextMapReader)
+		if !ok {
 			return nil, opentracing.ErrInvalidCarrier
 		}
-		if traceparentHeaderValue == "" {
+		var otBaggage map[string]string
+		values := make(map[string][]string)
+		reader.ForeachKey(func(key, val string) error {
+			canonicalKey := textproto.CanonicalMIMEHeaderKey(key)
+			switch {
+			case strings.HasPrefix(canonicalKey, otBaggageHeaderPrefix):
+				key = key[len(otBaggageHeaderPrefix):]
+				if format == opentracing.HTTPHeaders {
+					// HTTP header names are case-insensitive,
+					// so we always use lower case baggage keys.
+					key = strings.ToLower(key)
+					if unescaped, err := url.QueryUnescape(val); err == nil {
+						val = unescaped
+					}
+				}
+				if otBaggage == nil {
+					otBaggage = make(map[string]string)
+				}
+				otBaggage[key] = val
+			default:
+				values[canonicalKey] = append(values[canonicalKey], val)
+			}
+			return nil
+		})
+		carrier := textMapCarrier{values: values}
+		traceContext, err := t.tracer.Propagator().Extract(carrier)
+		if err == apm.ErrTraceContextNotFound {
 			return nil, opentracing.ErrSpanContextNotFound
-		}
-		traceContext, err := apmhttp.ParseTraceparentHeader(traceparentHeaderValue)
-		if err != nil {
+		} else if err != nil {
 			return nil, err
 		}
-		traceContext.State, _ = apmhttp.ParseTracestateHeader(tracestateHeaderValues...)
-		return &spanContext{tracer: t, traceContext: traceContext}, nil
+
+		// Baggage items propagated with ot-baggage-* headers
+		// take precedence over those in the W3C Baggage header.
+		baggage := otBaggage
+		if members := apm.ExtractBaggage(carrier).Members(); len(members) > 0 {
+			baggage = make(map[string]string, len(members)+len(otBaggage))
+			for _, m := range members {
+				baggage[m.Key] = m.Value
+			}
+			for k, v := range otBaggage {
+				baggage[k] = v
+			}
+		}
+		return &spanContext{tracer: t, traceContext: traceContext, baggage: baggage}, nil
 	case opentracing.Binary:
 		reader, ok := carrier.(io.Reader)
 		if !ok {
@@ -206,6 +220,24 @@ var (
 	binaryExtract = binaryExtractUnsupported
 )
 
+// textMapCarrier is an apm.PropagationCarrier which gets values collected
+// from an opentracing.TextMapReader, and sets values in an
+// opentracing.TextMapWriter.
+type textMapCarrier struct {
+	values map[string][]string // keyed by canonical MIME header key
+	writer opentracing.TextMapWriter
+}
+
+// Get returns the values collected for key.
+func (c textMapCarrier) Get(key string) []string {
+	return c.values[textproto.CanonicalMIMEHeaderKey(key)]
+}
+
+// Set sets the value for key in the writer.
+func (c textMapCarrier) Set(key, value string) {
+	c.writer.Set(key, value)
+}
+
 func binaryInjectUnsupported(w io.Writer, traceContext apm.TraceContext) error {
 	return opentracing.ErrUnsupportedFormat
 }
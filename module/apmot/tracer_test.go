@@ -20,10 +20,12 @@ package apmot_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -298,13 +300,13 @@ func TestSpanLogError(t *testing.T) {
 	span := tracer.StartSpan("parent")
 	span.LogKV("event", "error", "message", "foo")
 	span.LogKV("event", "error", "message", "bar", "error.object", errors.New("boom"))
-	span.LogKV("event", "warning") // non-error, ignored
+	span.LogKV("event", "warning") // non-error, recorded as labels
 	span.LogKV(1, "two")           // non-string keys ignored
 	span.LogKV()                   // no fields, no-op
 
 	childSpan := tracer.StartSpan("child", opentracing.ChildOf(span.Context()))
 	childSpan.LogFields(log.String("event", "error"), log.Error(errors.New("baz")))
-	childSpan.LogFields(log.String("event", "warning"), log.String("message", "meh")) // non-error, ignored
+	childSpan.LogFields(log.String("event", "warning"), log.String("message", "meh")) // non-error, recorded as labels
 	childSpan.LogFields()                                                             // no fields, ignored
 	childSpan.Finish()
 	span.Finish()
@@ -330,6 +332,65 @@ func TestSpanLogError(t *testing.T) {
 	assert.Equal(t, payloads.Spans[0].ID, errors[2].ParentID)
 }
 
+func TestSpanLogLabels(t *testing.T) {
+	tracer, apmtracer, recorder := newTestTracer()
+	defer apmtracer.Close()
+
+	span := tracer.StartSpan("parent")
+	span.LogKV("event", "cache.miss", "key", "foo")
+	childSpan := tracer.StartSpan("child", opentracing.ChildOf(span.Context()))
+	childSpan.LogFields(log.String("event", "retry"), log.Int("attempt", 2), log.Bool("final", true))
+	childSpan.Finish()
+	span.Finish()
+
+	apmtracer.Flush(nil)
+	payloads := recorder.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 1)
+	assert.Empty(t, payloads.Errors)
+
+	assert.Equal(t, model.IfaceMap{
+		{Key: "event", Value: "cache.miss"},
+		{Key: "key", Value: "foo"},
+	}, payloads.Transactions[0].Context.Tags)
+	assert.Equal(t, model.IfaceMap{
+		{Key: "attempt", Value: float64(2)},
+		{Key: "event", Value: "retry"},
+		{Key: "final", Value: true},
+	}, payloads.Spans[0].Context.Tags)
+}
+
+func TestSpanLogLabelsManyEvents(t *testing.T) {
+	tracer, apmtracer, recorder := newTestTracer()
+	defer apmtracer.Close()
+
+	// Logging many events on one span records only the most
+	// recent value for each key, up to a limited number of keys.
+	span := tracer.StartSpan("parent")
+	span.SetTag("key", "tag")
+	for i := 0; i < 1000; i++ {
+		span.LogKV("event", "progress", "i", i, "key", "log")
+		span.LogKV(fmt.Sprintf("key%d", i), i)
+	}
+	span.Finish()
+
+	apmtracer.Flush(nil)
+	payloads := recorder.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	labels := payloads.Transactions[0].Context.Tags
+	assert.Len(t, labels, 64)
+
+	values := make(map[string]interface{})
+	for _, label := range labels {
+		_, dup := values[label.Key]
+		assert.False(t, dup, "duplicate label %q", label.Key)
+		values[label.Key] = label.Value
+	}
+	assert.Equal(t, "progress", values["event"])
+	assert.Equal(t, float64(999), values["i"])
+	assert.Equal(t, "tag", values["key"])
+}
+
 func TestSpanFinishWithOptionsLogs(t *testing.T) {
 	tracer, apmtracer, recorder := newTestTracer()
 	defer apmtracer.Close()
@@ -401,6 +462,134 @@ func testTraceInjectExtract(t *testing.T, shouldPropagateLegacyHeader bool) {
 	}
 }
 
+func TestInjectExtractPropagator(t *testing.T) {
+	apmtracer, err := apm.NewTracerOptions(apm.TracerOptions{
+		Transport:  transporttest.Discard,
+		Propagator: apm.JaegerPropagator{},
+	})
+	require.NoError(t, err)
+	defer apmtracer.Close()
+	tracer := apmot.New(apmot.WithTracer(apmtracer))
+
+	span := tracer.StartSpan("span")
+	defer span.Finish()
+	textMap := make(opentracing.TextMapCarrier)
+	err = tracer.Inject(span.Context(), opentracing.TextMap, textMap)
+	require.NoError(t, err)
+	assert.Contains(t, textMap, "Uber-Trace-Id")
+	assert.NotContains(t, textMap, "Traceparent")
+
+	spanContext, err := tracer.Extract(opentracing.TextMap, textMap)
+	require.NoError(t, err)
+	type traceContexter interface {
+		TraceContext() apm.TraceContext
+	}
+	expect := span.Context().(traceContexter).TraceContext()
+	expect.State = apm.TraceState{}
+	assert.Equal(t, expect, spanContext.(traceContexter).TraceContext())
+
+	_, err = tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{})
+	assert.Equal(t, opentracing.ErrSpanContextNotFound, err)
+}
+
+func TestBaggage(t *testing.T) {
+	tracer, apmtracer, _ := newTestTracer()
+	defer apmtracer.Close()
+
+	parent := tracer.StartSpan("parent")
+	parent.SetBaggageItem("tenant", "acme")
+	child := tracer.StartSpan("child", opentracing.ChildOf(parent.Context()))
+	assert.Equal(t, "acme", child.BaggageItem("tenant"))
+
+	// Baggage set on the child is not visible to the parent.
+	child.SetBaggageItem("region", "eu")
+	assert.Equal(t, "", parent.BaggageItem("region"))
+
+	// Baggage is retained across spans started with the native API.
+	ctx := opentracing.ContextWithSpan(context.Background(), child)
+	_, ctx = apm.StartSpan(ctx, "native", "native")
+	grandchild, _ := opentracing.StartSpanFromContextWithTracer(ctx, tracer, "grandchild")
+	assert.Equal(t, "acme", grandchild.BaggageItem("tenant"))
+	assert.Equal(t, "eu", grandchild.BaggageItem("region"))
+}
+
+func TestBaggageInjectExtract(t *testing.T) {
+	tracer, apmtracer, _ := newTestTracer()
+	defer apmtracer.Close()
+
+	span := tracer.StartSpan("span")
+	span.SetBaggageItem("tenant", "acme corp")
+	span.SetBaggageItem("Region", "eu")
+
+	headers := make(http.Header)
+	err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	require.NoError(t, err)
+	assert.Equal(t, "acme+corp", headers.Get("Ot-Baggage-Tenant"))
+	assert.Equal(t, "eu", headers.Get("Ot-Baggage-Region"))
+	assert.Equal(t, "Region=eu,tenant=acme%20corp", headers.Get("Baggage"))
+
+	spanContext, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"tenant": "acme corp",
+		"region": "eu",
+		"Region": "eu", // from the W3C Baggage header, which is case-sensitive
+	}, baggageItems(spanContext))
+
+	textMap := make(opentracing.TextMapCarrier)
+	err = tracer.Inject(span.Context(), opentracing.TextMap, textMap)
+	require.NoError(t, err)
+	assert.Equal(t, "acme corp", textMap["Ot-Baggage-tenant"])
+
+	spanContext, err = tracer.Extract(opentracing.TextMap, textMap)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"tenant": "acme corp",
+		"Region": "eu",
+	}, baggageItems(spanContext))
+
+	// Baggage is extracted from the W3C Baggage header alone,
+	// ignoring any properties and invalid list members.
+	headers = http.Header{
+		"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		"Baggage":     {"tenant=acme;prop=1, invalid, a b=c", "region=eu"},
+	}
+	spanContext, err = tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"tenant": "acme",
+		"region": "eu",
+	}, baggageItems(spanContext))
+
+	child := tracer.StartSpan("child", opentracing.ChildOf(spanContext))
+	assert.Equal(t, "acme", child.BaggageItem("tenant"))
+}
+
+func TestBaggageInjectLimits(t *testing.T) {
+	tracer, apmtracer, _ := newTestTracer()
+	defer apmtracer.Close()
+
+	// The W3C Baggage header is limited to 180 members.
+	span := tracer.StartSpan("span")
+	for i := 0; i < 200; i++ {
+		span.SetBaggageItem(fmt.Sprintf("key%03d", i), "value")
+	}
+
+	headers := make(http.Header)
+	err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(headers.Get("Baggage"), ","), 180)
+}
+
+func baggageItems(spanContext opentracing.SpanContext) map[string]string {
+	items := make(map[string]string)
+	spanContext.ForeachBaggageItem(func(k, v string) bool {
+		items[k] = v
+		return true
+	})
+	return items
+}
+
 func BenchmarkSpanSetSpanContext(b *testing.B) {
 	tags := opentracing.Tags{
 		"component":    "myComponent",
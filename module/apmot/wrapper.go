@@ -43,6 +43,7 @@ func contextWithSpan(ctx context.Context, apmSpan interface{}) context.Context {
 		spanContext: apmSpanWrapperContext{
 			span:        apmSpan.(*apm.Span),
 			transaction: tx,
+			baggage:     contextBaggage(ctx),
 		},
 	})
 }
@@ -51,10 +52,21 @@ func contextWithTransaction(ctx context.Context, apmTransaction interface{}) con
 	return opentracing.ContextWithSpan(ctx, apmTransactionWrapper{
 		spanContext: apmTransactionWrapperContext{
 			transaction: apmTransaction.(*apm.Transaction),
+			baggage:     contextBaggage(ctx),
 		},
 	})
 }
 
+// contextBaggage returns the baggage of the OpenTracing span in ctx, if any,
+// so that baggage is retained across spans started with the native API.
+func contextBaggage(ctx context.Context) map[string]string {
+	otSpan := opentracing.SpanFromContext(ctx)
+	if otSpan == nil {
+		return nil
+	}
+	return referencedBaggage([]opentracing.SpanReference{{ReferencedContext: otSpan.Context()}})
+}
+
 func spanFromContext(ctx context.Context) interface{} {
 	otSpan, _ := opentracing.SpanFromContext(ctx).(interface {
 		Span() *apm.Span
@@ -83,6 +95,7 @@ func transactionFromContext(ctx context.Context) interface{} {
 type apmSpanWrapperContext struct {
 	span        *apm.Span
 	transaction *apm.Transaction
+	baggage     map[string]string
 }
 
 // TraceContext returns ctx.span.TraceContext(). This is used to set the
@@ -97,8 +110,11 @@ func (ctx apmSpanWrapperContext) Transaction() *apm.Transaction {
 	return ctx.transaction
 }
 
-// ForeachBaggageItem is a no-op; we do not support baggage propagation.
-func (apmSpanWrapperContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+// ForeachBaggageItem calls handler for each baggage item inherited
+// from the OpenTracing span in context when the span was started.
+func (ctx apmSpanWrapperContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	foreachBaggageItem(ctx.baggage, handler)
+}
 
 // apmSpanWrapper is an opentracing.Span that wraps an apmSpanWrapperContext.
 type apmSpanWrapper struct {
@@ -129,14 +145,14 @@ func (s apmSpanWrapper) Context() opentracing.SpanContext {
 	return s.spanContext
 }
 
-// BaggageItem returns the empty string; we do not support baggage.
-func (apmSpanWrapper) BaggageItem(key string) string {
-	return ""
+// BaggageItem returns the value of the baggage item with the given key,
+// or the empty string if there is none.
+func (s apmSpanWrapper) BaggageItem(key string) string {
+	return s.spanContext.baggage[key]
 }
 
-// SetBaggageItem is a no-op; we do not support baggage.
+// SetBaggageItem is a no-op; wrapper spans are immutable.
 func (s apmSpanWrapper) SetBaggageItem(key, val string) opentracing.Span {
-	// We do not support baggage.
 	return s
 }
 
@@ -144,6 +160,7 @@ func (s apmSpanWrapper) SetBaggageItem(key, val string) opentracing.Span {
 // an apm.Transaction.
 type apmTransactionWrapperContext struct {
 	transaction *apm.Transaction
+	baggage     map[string]string
 }
 
 // TraceContext returns ctx.transaction.TraceContext(). This is used to set the
@@ -158,8 +175,11 @@ func (ctx apmTransactionWrapperContext) Transaction() *apm.Transaction {
 	return ctx.transaction
 }
 
-// ForeachBaggageItem is a no-op; we do not support baggage propagation.
-func (apmTransactionWrapperContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+// ForeachBaggageItem calls handler for each baggage item inherited
+// from the OpenTracing span in context when the transaction was started.
+func (ctx apmTransactionWrapperContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	foreachBaggageItem(ctx.baggage, handler)
+}
 
 // apmTransactionWrapper is an opentracing.Span that wraps an apmTransactionWrapperContext.
 type apmTransactionWrapper struct {
@@ -185,14 +205,14 @@ func (s apmTransactionWrapper) Context() opentracing.SpanContext {
 	return s.spanContext
 }
 
-// BaggageItem returns the empty string; we do not support baggage.
-func (apmTransactionWrapper) BaggageItem(key string) string {
-	return ""
+// BaggageItem returns the value of the baggage item with the given key,
+// or the empty string if there is none.
+func (s apmTransactionWrapper) BaggageItem(key string) string {
+	return s.spanContext.baggage[key]
 }
 
-// SetBaggageItem is a no-op; we do not support baggage.
+// SetBaggageItem is a no-op; wrapper spans are immutable.
 func (s apmTransactionWrapper) SetBaggageItem(key, val string) opentracing.Span {
-	// We do not support baggage.
 	return s
 }
 
@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmcontext"
+	"go.elastic.co/apm/module/apmhttp"
+)
+
+func init() {
+	// We wrap the apmcontext functions so that transactions and spans
+	// started with the native API are made available as OpenTelemetry
+	// spans. The existing functions are called first, so that other
+	// bridges (e.g. apmot) continue to work if they were installed
+	// before this package was initialised.
+	contextWithSpan := apmcontext.ContextWithSpan
+	contextWithTransaction := apmcontext.ContextWithTransaction
+	apmcontext.ContextWithSpan = func(ctx context.Context, apmSpan interface{}) context.Context {
+		provider := providerFromContext(ctx)
+		ctx = contextWithSpan(ctx, apmSpan)
+		if s, ok := apmSpan.(*apm.Span); ok && s != nil {
+			tx := apm.TransactionFromContext(ctx)
+			ctx = trace.ContextWithSpan(ctx, newNativeSpan(provider, tx, s))
+		}
+		return ctx
+	}
+	apmcontext.ContextWithTransaction = func(ctx context.Context, apmTransaction interface{}) context.Context {
+		provider := providerFromContext(ctx)
+		ctx = contextWithTransaction(ctx, apmTransaction)
+		if tx, ok := apmTransaction.(*apm.Transaction); ok && tx != nil {
+			ctx = trace.ContextWithSpan(ctx, newNativeSpan(provider, tx, nil))
+		}
+		return ctx
+	}
+}
+
+// defaultProvider is the tracerProvider used for native transactions
+// and spans whose tracer cannot be determined.
+var defaultProvider = &tracerProvider{tracer: apm.DefaultTracer}
+
+// providerFromContext returns the tracerProvider of the span in ctx,
+// if it was created by this package. Otherwise, the tracer of native
+// transactions and spans cannot be determined, and defaultProvider
+// is returned.
+func providerFromContext(ctx context.Context) *tracerProvider {
+	if s, ok := trace.SpanFromContext(ctx).(*span); ok {
+		return s.provider
+	}
+	return defaultProvider
+}
+
+// newNativeSpan returns a span wrapping a transaction or span started
+// with the native API. The wrapped transaction or span is not owned by
+// the span, but it may be modified and ended through it.
+//
+// Errors recorded for the span, and spans started through its
+// TracerProvider, will be reported using provider's tracer.
+//
+// The start time of the wrapped transaction or span is not known,
+// so a timestamp passed to the span's End method is ignored.
+func newNativeSpan(provider *tracerProvider, tx *apm.Transaction, apmSpan *apm.Span) *span {
+	s := &span{
+		provider: provider,
+		tx:       tx,
+		span:     apmSpan,
+	}
+	if apmSpan != nil {
+		s.traceContext = apmSpan.TraceContext()
+	} else {
+		s.traceContext = tx.TraceContext()
+	}
+	s.spanContext = makeSpanContext(s.traceContext, false)
+	return s
+}
+
+// makeSpanContext returns a trace.SpanContext for the given apm.TraceContext.
+func makeSpanContext(traceContext apm.TraceContext, remote bool) trace.SpanContext {
+	var flags trace.TraceFlags
+	if traceContext.Options.Recorded() {
+		flags = trace.FlagsSampled
+	}
+	traceState, _ := trace.ParseTraceState(traceContext.State.String())
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID(traceContext.Trace),
+		SpanID:     trace.SpanID(traceContext.Span),
+		TraceFlags: flags,
+		TraceState: traceState,
+		Remote:     remote,
+	})
+}
+
+// makeTraceContext returns an apm.TraceContext for the given trace.SpanContext.
+func makeTraceContext(spanContext trace.SpanContext) apm.TraceContext {
+	traceContext := apm.TraceContext{
+		Trace:   apm.TraceID(spanContext.TraceID()),
+		Span:    apm.SpanID(spanContext.SpanID()),
+		Options: apm.TraceOptions(0).WithRecorded(spanContext.IsSampled()),
+	}
+	if traceState := spanContext.TraceState().String(); traceState != "" {
+		traceContext.State, _ = apmhttp.ParseTracestateHeader(traceState)
+	}
+	return traceContext
+}
@@ -0,0 +1,31 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package apmotel provides an Elastic APM implementation of the
+// OpenTelemetry tracing API.
+//
+// Root spans started through the OpenTelemetry API are recorded as
+// Elastic APM transactions, and their descendants as spans. Spans
+// started through the OpenTelemetry API are available to the native
+// API via apm.TransactionFromContext and apm.SpanFromContext, and
+// transactions and spans added to a context with the native API are
+// available to the OpenTelemetry API via trace.SpanFromContext.
+//
+// Things not implemented by this tracer:
+//  - span links
+//  - span events, other than errors recorded with RecordError
+package apmotel
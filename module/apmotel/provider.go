@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmotel
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go.elastic.co/apm"
+)
+
+// NewTracerProvider returns a new trace.TracerProvider backed by the
+// supplied Elastic APM tracer.
+//
+// By default, the returned tracer provider will use apm.DefaultTracer.
+// This can be overridden by using a WithTracer option.
+func NewTracerProvider(opts ...Option) trace.TracerProvider {
+	p := &tracerProvider{tracer: apm.DefaultTracer}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// tracerProvider is a trace.TracerProvider backed by an apm.Tracer.
+type tracerProvider struct {
+	tracer *apm.Tracer
+}
+
+// Tracer returns a trace.Tracer with the given instrumentation name.
+//
+// All tracers returned by p share the same underlying apm.Tracer;
+// the instrumentation name and options are ignored.
+func (p *tracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &otelTracer{provider: p}
+}
+
+// otelTracer is a trace.Tracer backed by an apm.Tracer.
+type otelTracer struct {
+	provider *tracerProvider
+}
+
+// Start starts a new span with the given name and options, returning the
+// span and a context containing it. The span will be recorded as a
+// transaction if it has no local parent in ctx, and otherwise as a span.
+func (t *otelTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	s := &span{
+		provider:  t.provider,
+		kind:      cfg.SpanKind(),
+		startTime: cfg.Timestamp(),
+	}
+	if s.startTime.IsZero() {
+		s.startTime = time.Now()
+	}
+	s.setAttributes(cfg.Attributes()...)
+
+	var parentTraceContext apm.TraceContext
+	if !cfg.NewRoot() {
+		if parent, ok := trace.SpanFromContext(ctx).(*span); ok && parent.tx != nil {
+			s.tx = parent.tx
+			s.span = s.tx.StartSpanOptions(name, "", apm.SpanOptions{
+				Parent: parent.traceContext,
+				Start:  s.startTime,
+			})
+			s.traceContext = s.span.TraceContext()
+			s.spanContext = makeSpanContext(s.traceContext, false)
+			ctx = apm.ContextWithSpan(ctx, s.span)
+			return trace.ContextWithSpan(ctx, s), s
+		}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			parentTraceContext = makeTraceContext(spanContext)
+		}
+	}
+
+	// There's no local parent, so start a transaction.
+	s.tx = t.provider.tracer.StartTransactionOptions(name, "", apm.TransactionOptions{
+		TraceContext: parentTraceContext,
+		Start:        s.startTime,
+	})
+	s.traceContext = s.tx.TraceContext()
+	s.spanContext = makeSpanContext(s.traceContext, false)
+	ctx = apm.ContextWithTransaction(ctx, s.tx)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+// Option sets options for the OpenTelemetry TracerProvider implementation.
+type Option func(*tracerProvider)
+
+// WithTracer returns an Option which sets t as the underlying
+// apm.Tracer for constructing an OpenTelemetry TracerProvider.
+func WithTracer(t *apm.Tracer) Option {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(p *tracerProvider) {
+		p.tracer = t
+	}
+}
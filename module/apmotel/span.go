@@ -0,0 +1,422 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmotel
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/module/apmhttp"
+)
+
+// span wraps apm objects to implement the trace.Span interface.
+type span struct {
+	provider *tracerProvider
+
+	mu           sync.Mutex
+	tx           *apm.Transaction
+	span         *apm.Span // nil if the span is a transaction
+	traceContext apm.TraceContext
+	spanContext  trace.SpanContext
+	kind         trace.SpanKind
+	startTime    time.Time
+	attributes   []attribute.KeyValue
+	statusCode   codes.Code
+	ended        bool
+}
+
+// End ends the span, recording its attributes and status
+// in the underlying transaction or span.
+//
+// A timestamp passed with trace.WithTimestamp is used to set the
+// duration, except for spans wrapping transactions and spans started
+// with the native API, whose start time is unknown.
+func (s *span) End(opts ...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+
+	cfg := trace.NewSpanEndConfig(opts...)
+	if endTime := cfg.Timestamp(); !endTime.IsZero() && !s.startTime.IsZero() {
+		duration := endTime.Sub(s.startTime)
+		if s.span != nil {
+			s.span.Duration = duration
+		} else {
+			s.tx.Duration = duration
+		}
+	}
+	if s.span != nil {
+		s.setSpanContext()
+		s.span.End()
+	} else {
+		s.setTransactionContext()
+		s.tx.End()
+	}
+}
+
+// AddEvent is a no-op; only errors, recorded with RecordError, are supported.
+func (s *span) AddEvent(name string, opts ...trace.EventOption) {}
+
+// IsRecording reports whether the span is recording information,
+// i.e. it has not ended and its transaction is sampled.
+func (s *span) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.ended && s.traceContext.Options.Recorded()
+}
+
+// RecordError reports err as an error to Elastic APM,
+// associated with the span.
+func (s *span) RecordError(err error, opts ...trace.EventOption) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	e := s.provider.tracer.NewError(err)
+	e.Handled = true
+	cfg := trace.NewEventConfig(opts...)
+	if timestamp := cfg.Timestamp(); !timestamp.IsZero() {
+		e.Timestamp = timestamp
+	}
+	if s.span != nil {
+		e.SetSpan(s.span)
+	} else {
+		e.SetTransaction(s.tx)
+	}
+	e.Send()
+}
+
+// SpanContext returns the span's trace.SpanContext.
+func (s *span) SpanContext() trace.SpanContext {
+	return s.spanContext
+}
+
+// SetStatus sets the status of the span. An error status
+// results in a "failure" transaction or span outcome, and an
+// ok status in a "success" outcome.
+func (s *span) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = code
+}
+
+// SetName sets the name of the span.
+func (s *span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.span != nil {
+		s.span.Name = name
+	} else {
+		s.tx.Name = name
+	}
+}
+
+// SetAttributes sets attributes of the span, which will be recorded
+// when the span is ended.
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setAttributes(kv...)
+}
+
+func (s *span) setAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		if !attr.Valid() {
+			continue
+		}
+		replaced := false
+		for i := range s.attributes {
+			if s.attributes[i].Key == attr.Key {
+				s.attributes[i] = attr
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.attributes = append(s.attributes, attr)
+		}
+	}
+}
+
+// TracerProvider returns the trace.TracerProvider that created the span.
+func (s *span) TracerProvider() trace.TracerProvider {
+	return s.provider
+}
+
+// spanAttributes holds the attributes of a span which are mapped
+// to Elastic APM fields, according to the OpenTelemetry semantic
+// conventions.
+type spanAttributes struct {
+	httpMethod     string
+	httpURL        string
+	httpScheme     string
+	httpHost       string
+	httpTarget     string
+	httpStatusCode int
+
+	netPeerName string
+	netPeerIP   string
+	netPeerPort int
+
+	dbSystem    string
+	dbName      string
+	dbStatement string
+	dbUser      string
+
+	messagingSystem      string
+	messagingDestination string
+
+	rpcSystem string
+
+	// labels holds attributes which are not otherwise mapped.
+	labels []attribute.KeyValue
+}
+
+func (s *span) parseAttributes() spanAttributes {
+	var out spanAttributes
+	for _, attr := range s.attributes {
+		switch attr.Key {
+		case "http.method":
+			out.httpMethod = attr.Value.Emit()
+		case "http.url":
+			out.httpURL = attr.Value.Emit()
+		case "http.scheme":
+			out.httpScheme = attr.Value.Emit()
+		case "http.host":
+			out.httpHost = attr.Value.Emit()
+		case "http.target":
+			out.httpTarget = attr.Value.Emit()
+		case "http.status_code":
+			out.httpStatusCode = int(attr.Value.AsInt64())
+		case "net.peer.name":
+			out.netPeerName = attr.Value.Emit()
+		case "net.peer.ip":
+			out.netPeerIP = attr.Value.Emit()
+		case "net.peer.port":
+			out.netPeerPort = int(attr.Value.AsInt64())
+		case "db.system":
+			out.dbSystem = attr.Value.Emit()
+		case "db.name":
+			out.dbName = attr.Value.Emit()
+		case "db.statement":
+			out.dbStatement = attr.Value.Emit()
+		case "db.user":
+			out.dbUser = attr.Value.Emit()
+		case "messaging.system":
+			out.messagingSystem = attr.Value.Emit()
+		case "messaging.destination":
+			out.messagingDestination = attr.Value.Emit()
+		case "rpc.system":
+			out.rpcSystem = attr.Value.Emit()
+		default:
+			out.labels = append(out.labels, attr)
+		}
+	}
+	return out
+}
+
+// fullURL returns the URL of the HTTP request described by the
+// attributes, or nil if there is none.
+func (attrs *spanAttributes) fullURL() *url.URL {
+	if attrs.httpURL != "" {
+		u, err := url.Parse(attrs.httpURL)
+		if err != nil {
+			return nil
+		}
+		return u
+	}
+	if attrs.httpTarget == "" {
+		return nil
+	}
+	u, err := url.ParseRequestURI(attrs.httpTarget)
+	if err != nil {
+		return nil
+	}
+	u.Scheme = attrs.httpScheme
+	u.Host = attrs.httpHost
+	return u
+}
+
+func (attrs *spanAttributes) peerAddress() string {
+	if attrs.netPeerName != "" {
+		return attrs.netPeerName
+	}
+	return attrs.netPeerIP
+}
+
+func (s *span) setSpanContext() {
+	attrs := s.parseAttributes()
+	for _, attr := range attrs.labels {
+		s.span.Context.SetLabel(string(attr.Key), labelValue(attr.Value))
+	}
+
+	var resource string
+	switch {
+	case attrs.dbSystem != "":
+		s.span.Type = "db"
+		s.span.Subtype = attrs.dbSystem
+		s.span.Action = "query"
+		s.span.Context.SetDatabase(apm.DatabaseSpanContext{
+			Instance:  attrs.dbName,
+			Statement: attrs.dbStatement,
+			Type:      attrs.dbSystem,
+			User:      attrs.dbUser,
+		})
+		resource = attrs.dbSystem
+		if attrs.dbName != "" {
+			resource += "/" + attrs.dbName
+		}
+	case attrs.messagingSystem != "":
+		s.span.Type = "messaging"
+		s.span.Subtype = attrs.messagingSystem
+		if s.kind == trace.SpanKindConsumer {
+			s.span.Action = "receive"
+		} else {
+			s.span.Action = "send"
+		}
+		resource = attrs.messagingSystem
+		if attrs.messagingDestination != "" {
+			resource += "/" + attrs.messagingDestination
+		}
+	case attrs.rpcSystem != "":
+		s.span.Type = "external"
+		s.span.Subtype = attrs.rpcSystem
+		resource = attrs.peerAddress()
+		if resource != "" && attrs.netPeerPort > 0 {
+			resource = net.JoinHostPort(resource, strconv.Itoa(attrs.netPeerPort))
+		}
+	case attrs.httpMethod != "" || attrs.httpURL != "":
+		s.span.Type = "external"
+		s.span.Subtype = "http"
+		if u := attrs.fullURL(); u != nil {
+			req := http.Request{
+				ProtoMajor: 1, // Assume HTTP/1.1
+				ProtoMinor: 1,
+				Method:     attrs.httpMethod,
+				URL:        u,
+			}
+			s.span.Context.SetHTTPRequest(&req)
+		}
+		if attrs.httpStatusCode > 0 {
+			s.span.Context.SetHTTPStatusCode(attrs.httpStatusCode)
+		}
+	default:
+		switch s.kind {
+		case trace.SpanKindClient:
+			s.span.Type = "external"
+		case trace.SpanKindProducer, trace.SpanKindConsumer:
+			s.span.Type = "messaging"
+		default:
+			s.span.Type = "custom"
+		}
+	}
+
+	if addr := attrs.peerAddress(); addr != "" {
+		s.span.Context.SetDestinationAddress(addr, attrs.netPeerPort)
+	}
+	if resource != "" {
+		s.span.Context.SetDestinationService(apm.DestinationServiceSpanContext{
+			Name:     s.span.Subtype,
+			Resource: resource,
+		})
+	}
+	switch s.statusCode {
+	case codes.Error:
+		s.span.Outcome = "failure"
+	case codes.Ok:
+		s.span.Outcome = "success"
+	}
+}
+
+func (s *span) setTransactionContext() {
+	attrs := s.parseAttributes()
+	for _, attr := range attrs.labels {
+		s.tx.Context.SetLabel(string(attr.Key), labelValue(attr.Value))
+	}
+
+	if s.tx.Type == "" {
+		switch {
+		case attrs.httpMethod != "" || s.kind == trace.SpanKindServer:
+			s.tx.Type = "request"
+		case attrs.messagingSystem != "" || s.kind == trace.SpanKindConsumer:
+			s.tx.Type = "messaging"
+		default:
+			s.tx.Type = "custom"
+		}
+	}
+	if u := attrs.fullURL(); u != nil {
+		req := http.Request{
+			ProtoMajor: 1, // Assume HTTP/1.1
+			ProtoMinor: 1,
+			Method:     attrs.httpMethod,
+			URL:        u,
+			Host:       u.Host,
+		}
+		s.tx.Context.SetHTTPRequest(&req)
+	}
+	if attrs.httpStatusCode > 0 {
+		s.tx.Context.SetHTTPStatusCode(attrs.httpStatusCode)
+		if s.tx.Result == "" {
+			s.tx.Result = apmhttp.StatusCodeResult(attrs.httpStatusCode)
+		}
+	}
+	switch s.statusCode {
+	case codes.Error:
+		s.tx.Outcome = "failure"
+		if s.tx.Result == "" {
+			s.tx.Result = "error"
+		}
+	case codes.Ok:
+		s.tx.Outcome = "success"
+	}
+}
+
+// labelValue returns v as a value suitable for Context.SetLabel.
+func labelValue(v attribute.Value) interface{} {
+	switch v.Type() {
+	case attribute.BOOL:
+		return v.AsBool()
+	case attribute.INT64:
+		return v.AsInt64()
+	case attribute.FLOAT64:
+		return v.AsFloat64()
+	case attribute.STRING:
+		return v.AsString()
+	}
+	return fmt.Sprint(v.AsInterface())
+}
@@ -0,0 +1,390 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmotel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmotel"
+)
+
+func TestSpanParenting(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	childCtx, child := otelTracer.Start(ctx, "child")
+	_, grandchild := otelTracer.Start(childCtx, "grandchild")
+	grandchild.End()
+	child.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 2)
+	tx := payloads.Transactions[0]
+	assert.Equal(t, "root", tx.Name)
+	assert.Equal(t, "custom", tx.Type)
+	assert.Equal(t, "grandchild", payloads.Spans[0].Name)
+	assert.Equal(t, "child", payloads.Spans[1].Name)
+	assert.Equal(t, "custom", payloads.Spans[1].Type)
+	assert.Equal(t, tx.ID, payloads.Spans[1].ParentID)
+	assert.Equal(t, payloads.Spans[1].ID, payloads.Spans[0].ParentID)
+	for _, span := range payloads.Spans {
+		assert.Equal(t, tx.TraceID, span.TraceID)
+		assert.Equal(t, tx.ID, span.TransactionID)
+	}
+
+	assert.Equal(t, trace.TraceID(tx.TraceID), root.SpanContext().TraceID())
+	assert.Equal(t, trace.SpanID(tx.ID), root.SpanContext().SpanID())
+	assert.True(t, root.SpanContext().IsSampled())
+	assert.False(t, root.IsRecording())
+}
+
+func TestNewRoot(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	_, other := otelTracer.Start(ctx, "other", trace.WithNewRoot())
+	other.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 2)
+	assert.Empty(t, payloads.Spans)
+	assert.NotEqual(t, payloads.Transactions[0].TraceID, payloads.Transactions[1].TraceID)
+}
+
+func TestRemoteParent(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	spanID := trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	traceState, err := trace.ParseTraceState("vendor=value")
+	require.NoError(t, err)
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		TraceState: traceState,
+		Remote:     true,
+	}))
+
+	_, span := otelTracer.Start(ctx, "name", trace.WithSpanKind(trace.SpanKindServer))
+	assert.Equal(t, traceID, span.SpanContext().TraceID())
+	assert.Equal(t, "vendor=value", span.SpanContext().TraceState().String())
+	span.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, model.TraceID(traceID), payloads.Transactions[0].TraceID)
+	assert.Equal(t, model.SpanID(spanID), payloads.Transactions[0].ParentID)
+	assert.Equal(t, "request", payloads.Transactions[0].Type)
+}
+
+func TestContextInterop(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	tx := apm.TransactionFromContext(ctx)
+	require.NotNil(t, tx)
+	assert.Equal(t, root.SpanContext().SpanID(), trace.SpanID(tx.TraceContext().Span))
+
+	// A native span started within the OpenTelemetry span's context
+	// is visible to OpenTelemetry, and OpenTelemetry spans started
+	// within its context are children of it.
+	nativeSpan, ctx := apm.StartSpan(ctx, "native", "type")
+	assert.Equal(t, trace.SpanID(nativeSpan.TraceContext().Span), trace.SpanFromContext(ctx).SpanContext().SpanID())
+	_, child := otelTracer.Start(ctx, "child")
+	child.End()
+	nativeSpan.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 2)
+	assert.Equal(t, "child", payloads.Spans[0].Name)
+	assert.Equal(t, "native", payloads.Spans[1].Name)
+	assert.Equal(t, payloads.Spans[1].ID, payloads.Spans[0].ParentID)
+	assert.Equal(t, payloads.Transactions[0].ID, payloads.Spans[1].ParentID)
+}
+
+func TestNativeSpanTracer(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	// Native spans started within a bridged span's context use the
+	// bridge's tracer for errors and spans started through them.
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	nativeSpan, ctx := apm.StartSpan(ctx, "native", "type")
+	otelSpan := trace.SpanFromContext(ctx)
+	otelSpan.RecordError(errors.New("boom"))
+	_, child := otelSpan.TracerProvider().Tracer("test").Start(ctx, "child")
+	child.End()
+	nativeSpan.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 2)
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+	assert.Equal(t, payloads.Spans[1].ID, payloads.Errors[0].ParentID)
+	assert.Equal(t, "child", payloads.Spans[0].Name)
+	assert.Equal(t, payloads.Spans[1].ID, payloads.Spans[0].ParentID)
+}
+
+func TestNativeTransactionContext(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	tx := tracer.StartTransaction("native", "request")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	assert.Equal(t, trace.SpanID(tx.TraceContext().Span), trace.SpanFromContext(ctx).SpanContext().SpanID())
+
+	_, span := otelTracer.Start(ctx, "child")
+	span.End()
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 1)
+	assert.Equal(t, payloads.Transactions[0].ID, payloads.Spans[0].ParentID)
+}
+
+func TestDatabaseSpan(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	_, span := otelTracer.Start(ctx, "SELECT FROM foo",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mysql"),
+			attribute.String("db.name", "test"),
+			attribute.String("db.statement", "SELECT * FROM foo"),
+			attribute.String("db.user", "root"),
+			attribute.String("net.peer.name", "dbhost"),
+			attribute.Int("net.peer.port", 3306),
+			attribute.Bool("custom", true),
+		),
+	)
+	span.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Spans, 1)
+	assert.Equal(t, "db", payloads.Spans[0].Type)
+	assert.Equal(t, "mysql", payloads.Spans[0].Subtype)
+	assert.Equal(t, "query", payloads.Spans[0].Action)
+	assert.Equal(t, &model.SpanContext{
+		Database: &model.DatabaseSpanContext{
+			Instance:  "test",
+			Statement: "SELECT * FROM foo",
+			Type:      "mysql",
+			User:      "root",
+		},
+		Destination: &model.DestinationSpanContext{
+			Address: "dbhost",
+			Port:    3306,
+			Service: &model.DestinationServiceSpanContext{
+				Type:     "db",
+				Name:     "mysql",
+				Resource: "mysql/test",
+			},
+		},
+		Tags: model.IfaceMap{{Key: "custom", Value: true}},
+	}, payloads.Spans[0].Context)
+}
+
+func TestHTTPSpan(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	_, span := otelTracer.Start(ctx, "GET", trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(
+		attribute.String("http.method", "GET"),
+		attribute.String("http.url", "http://testing.invalid:8080/foo"),
+		attribute.Int("http.status_code", 200),
+	)
+	span.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Spans, 1)
+	assert.Equal(t, "external", payloads.Spans[0].Type)
+	assert.Equal(t, "http", payloads.Spans[0].Subtype)
+	require.NotNil(t, payloads.Spans[0].Context)
+	require.NotNil(t, payloads.Spans[0].Context.HTTP)
+	assert.Equal(t, "http://testing.invalid:8080/foo", payloads.Spans[0].Context.HTTP.URL.String())
+	assert.Equal(t, 200, payloads.Spans[0].Context.HTTP.StatusCode)
+	require.NotNil(t, payloads.Spans[0].Context.Destination)
+	assert.Equal(t, "testing.invalid", payloads.Spans[0].Context.Destination.Address)
+	assert.Equal(t, 8080, payloads.Spans[0].Context.Destination.Port)
+}
+
+func TestSpanKind(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root", trace.WithSpanKind(trace.SpanKindConsumer))
+	for _, kind := range []trace.SpanKind{
+		trace.SpanKindInternal,
+		trace.SpanKindClient,
+		trace.SpanKindProducer,
+	} {
+		_, span := otelTracer.Start(ctx, kind.String(), trace.WithSpanKind(kind))
+		span.End()
+	}
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, "messaging", payloads.Transactions[0].Type)
+	require.Len(t, payloads.Spans, 3)
+	assert.Equal(t, "custom", payloads.Spans[0].Type)
+	assert.Equal(t, "external", payloads.Spans[1].Type)
+	assert.Equal(t, "messaging", payloads.Spans[2].Type)
+}
+
+func TestHTTPTransaction(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	_, span := otelTracer.Start(context.Background(), "GET /foo", trace.WithSpanKind(trace.SpanKindServer))
+	span.SetAttributes(
+		attribute.String("http.method", "GET"),
+		attribute.String("http.scheme", "https"),
+		attribute.String("http.host", "testing.invalid"),
+		attribute.String("http.target", "/foo?bar=baz"),
+		attribute.Int("http.status_code", 404),
+	)
+	span.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	tx := payloads.Transactions[0]
+	assert.Equal(t, "request", tx.Type)
+	assert.Equal(t, "HTTP 4xx", tx.Result)
+	require.NotNil(t, tx.Context.Request)
+	assert.Equal(t, "GET", tx.Context.Request.Method)
+	assert.Equal(t, "testing.invalid", tx.Context.Request.URL.Hostname)
+	assert.Equal(t, "/foo", tx.Context.Request.URL.Path)
+	assert.Equal(t, "bar=baz", tx.Context.Request.URL.Search)
+	require.NotNil(t, tx.Context.Response)
+	assert.Equal(t, 404, tx.Context.Response.StatusCode)
+}
+
+func TestStatusAndRecordError(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	_, span := otelTracer.Start(ctx, "child")
+	span.RecordError(errors.New("boom"))
+	span.End()
+	root.SetStatus(codes.Error, "failed")
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Spans, 1)
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "failure", payloads.Transactions[0].Outcome)
+	assert.Equal(t, "error", payloads.Transactions[0].Result)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+	assert.True(t, payloads.Errors[0].Exception.Handled)
+	assert.Equal(t, payloads.Spans[0].ID, payloads.Errors[0].ParentID)
+}
+
+func TestSpanStatus(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	ctx, root := otelTracer.Start(context.Background(), "root")
+	_, failed := otelTracer.Start(ctx, "failed")
+	failed.SetStatus(codes.Error, "failed")
+	failed.End()
+	_, succeeded := otelTracer.Start(ctx, "succeeded")
+	succeeded.SetStatus(codes.Ok, "")
+	succeeded.End()
+	root.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Spans, 2)
+	assert.Equal(t, "failed", payloads.Spans[0].Name)
+	assert.Equal(t, "failure", payloads.Spans[0].Outcome)
+	assert.Equal(t, "succeeded", payloads.Spans[1].Name)
+	assert.Equal(t, "success", payloads.Spans[1].Outcome)
+}
+
+func TestStartEndTimestamp(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	otelTracer := apmotel.NewTracerProvider(apmotel.WithTracer(tracer.Tracer)).Tracer("test")
+
+	start := time.Unix(123, 0).UTC()
+	_, span := otelTracer.Start(context.Background(), "name", trace.WithTimestamp(start))
+	span.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, model.Time(start), payloads.Transactions[0].Timestamp)
+	assert.Equal(t, 2000.0, payloads.Transactions[0].Duration)
+}
@@ -19,6 +19,7 @@ package apmprometheus
 
 import (
 	"context"
+	"math"
 	"strconv"
 
 	"github.com/pkg/errors"
@@ -80,13 +81,59 @@ func (g gatherer) GatherMetrics(ctx context.Context, out *apm.Metrics) error {
 					out.Add(name+".percentile."+strconv.Itoa(p), labels, q.GetValue())
 				}
 			}
-		default:
-			// TODO(axw) MetricType_HISTOGRAM
+		case dto.MetricType_HISTOGRAM:
+			for _, m := range mf.GetMetric() {
+				h := m.GetHistogram()
+				if h.GetSampleCount() == 0 {
+					continue
+				}
+				values, counts := histogramBuckets(h)
+				out.AddHistogram(name, makeLabels(m.GetLabel()), values, counts)
+			}
 		}
 	}
 	return nil
 }
 
+// histogramBuckets converts the cumulative Prometheus histogram buckets
+// in h to the non-cumulative form expected by Metrics.AddHistogram.
+//
+// Each bucket's value is the midpoint between its upper bound and that of
+// the preceding bucket; the first bucket's value is half its upper bound.
+// Observations which fall into the implicit +Inf bucket are reported using
+// the upper bound of the last finite bucket. Empty buckets are omitted.
+func histogramBuckets(h *dto.Histogram) ([]float64, []uint64) {
+	buckets := h.GetBucket()
+	values := make([]float64, 0, len(buckets)+1)
+	counts := make([]uint64, 0, len(buckets)+1)
+	var prevCount uint64
+	var prevUpperBound float64
+	for i, b := range buckets {
+		upperBound := b.GetUpperBound()
+		if math.IsInf(upperBound, +1) {
+			// client_golang does not encode the +Inf bucket,
+			// but other exporters might; handle it below.
+			break
+		}
+		value := upperBound / 2
+		if i > 0 {
+			value = prevUpperBound + (upperBound-prevUpperBound)/2
+		}
+		count := b.GetCumulativeCount() - prevCount
+		if count > 0 {
+			values = append(values, value)
+			counts = append(counts, count)
+		}
+		prevCount = b.GetCumulativeCount()
+		prevUpperBound = upperBound
+	}
+	if n := h.GetSampleCount(); n > prevCount {
+		values = append(values, prevUpperBound)
+		counts = append(counts, n-prevCount)
+	}
+	return values, counts
+}
+
 func makeLabels(lps []*dto.LabelPair) []apm.MetricLabel {
 	labels := make([]apm.MetricLabel, len(lps))
 	for i, lp := range lps {
@@ -72,6 +72,37 @@ func TestSummary(t *testing.T) {
 	}, metrics[0].Samples)
 }
 
+func TestHistogram(t *testing.T) {
+	r := prometheus.NewRegistry()
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "histogram",
+		Help:    "halp",
+		Buckets: []float64{1, 3, 5, 10},
+	})
+	r.MustRegister(h)
+
+	h.Observe(0.5)
+	h.Observe(2)
+	h.Observe(2)
+	h.Observe(7)
+	h.Observe(11)
+
+	g := apmprometheus.Wrap(r)
+	metrics := gatherMetrics(g)
+	for name := range metrics[0].Samples {
+		if !strings.HasPrefix(name, "histogram") {
+			delete(metrics[0].Samples, name)
+		}
+	}
+	assert.Equal(t, map[string]model.Metric{
+		"histogram": {
+			Type:   "histogram",
+			Values: []float64{0.5, 2, 7.5, 10},
+			Counts: []uint64{1, 2, 1, 1},
+		},
+	}, metrics[0].Samples)
+}
+
 func TestLabels(t *testing.T) {
 	r := prometheus.NewRegistry()
 	httpReqsTotal := prometheus.NewCounterVec(
@@ -0,0 +1,13 @@
+module go.elastic.co/apm/module/apmpubsub
+
+require (
+	cloud.google.com/go/pubsub v1.8.3
+	github.com/stretchr/testify v1.6.1
+	go.elastic.co/apm v1.8.0
+	google.golang.org/api v0.35.0
+	google.golang.org/grpc v1.33.2
+)
+
+replace go.elastic.co/apm => ../..
+
+go 1.13
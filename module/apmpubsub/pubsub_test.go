@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmpubsub_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmpubsub"
+)
+
+func TestPublishReceive(t *testing.T) {
+	client := newClient(t)
+	defer client.Close()
+	topic, sub := newTopicSubscription(t, client)
+
+	publisherTracer := apmtest.NewRecordingTracer()
+	defer publisherTracer.Close()
+	subscriberTracer := apmtest.NewRecordingTracer()
+	defer subscriberTracer.Close()
+
+	tx := publisherTracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	ctx = apm.ContextWithBaggage(ctx, apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme"}))
+	msg := &pubsub.Message{Data: []byte("hello"), Attributes: map[string]string{"a": "b"}}
+	_, err := apmpubsub.WrapTopic(topic).Publish(ctx, msg).Get(ctx)
+	require.NoError(t, err)
+	tx.End()
+	spans := waitSpans(t, publisherTracer, 1)
+
+	span := spans[0]
+	assert.Equal(t, "Pub/Sub SEND to topic", span.Name)
+	assert.Equal(t, "messaging", span.Type)
+	assert.Equal(t, "pubsub", span.Subtype)
+	assert.Equal(t, "send", span.Action)
+	assert.Equal(t, "success", span.Outcome)
+	assert.Equal(t, &model.DestinationServiceSpanContext{
+		Type:     "messaging",
+		Name:     "pubsub",
+		Resource: "pubsub/topic",
+	}, span.Context.Destination.Service)
+	assert.Equal(t, "topic", span.Context.Message.Queue.Name)
+	assert.Equal(t, "b", msg.Attributes["a"])
+
+	received := make(chan context.Context, 1)
+	receiveCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	receiveErr := make(chan error, 1)
+	go func() {
+		wrapped := apmpubsub.WrapSubscription(sub, apmpubsub.WithTracer(subscriberTracer.Tracer))
+		receiveErr <- wrapped.Receive(receiveCtx, func(ctx context.Context, msg *pubsub.Message) {
+			msg.Ack()
+			received <- ctx
+		})
+	}()
+
+	var receivedCtx context.Context
+	select {
+	case receivedCtx = <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	cancel()
+	require.NoError(t, <-receiveErr)
+	assert.NotNil(t, apm.TransactionFromContext(receivedCtx))
+	value, _ := apm.BaggageFromContext(receivedCtx).Value("tenant")
+	assert.Equal(t, "acme", value)
+
+	subscriberTracer.Flush(nil)
+	payloads := subscriberTracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	receiveTx := payloads.Transactions[0]
+	assert.Equal(t, "Pub/Sub RECEIVE from subscription", receiveTx.Name)
+	assert.Equal(t, "messaging", receiveTx.Type)
+	assert.Equal(t, "success", receiveTx.Outcome)
+	assert.Equal(t, span.TraceID, receiveTx.TraceID)
+	assert.Equal(t, span.ID, receiveTx.ParentID)
+	assert.Equal(t, "subscription", receiveTx.Context.Message.Queue.Name)
+	assert.NotNil(t, receiveTx.Context.Message.Age)
+}
+
+func TestPublishNoTransaction(t *testing.T) {
+	client := newClient(t)
+	defer client.Close()
+	topic, _ := newTopicSubscription(t, client)
+
+	ctx := context.Background()
+	msg := &pubsub.Message{Data: []byte("hello")}
+	_, err := apmpubsub.WrapTopic(topic).Publish(ctx, msg).Get(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, msg.Attributes)
+}
+
+func TestWrapReceiveFuncDeliveryAttempt(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	deliveryAttempt := 3
+	msg := &pubsub.Message{
+		Data:            []byte("hello"),
+		PublishTime:     time.Now().Add(-time.Second),
+		DeliveryAttempt: &deliveryAttempt,
+	}
+	f := apmpubsub.WrapReceiveFunc("subscription", func(ctx context.Context, msg *pubsub.Message) {}, apmpubsub.WithTracer(tracer.Tracer))
+	f(context.Background(), msg)
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	tx := payloads.Transactions[0]
+	assert.Equal(t, model.IfaceMap{{Key: "pubsub_delivery_attempt", Value: 3.0}}, tx.Context.Tags)
+	require.NotNil(t, tx.Context.Message.Age)
+	assert.True(t, tx.Context.Message.Age.Milliseconds >= 1000)
+}
+
+func TestWrapReceiveFuncPanic(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	f := apmpubsub.WrapReceiveFunc("subscription", func(ctx context.Context, msg *pubsub.Message) {
+		panic("boom")
+	}, apmpubsub.WithTracer(tracer.Tracer))
+	assert.Panics(t, func() { f(context.Background(), &pubsub.Message{}) })
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "failure", payloads.Transactions[0].Outcome)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+}
+
+func newClient(t *testing.T) *pubsub.Client {
+	srv := pstest.NewServer()
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	require.NoError(t, err)
+	client, err := pubsub.NewClient(context.Background(), "project", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	return client
+}
+
+func newTopicSubscription(t *testing.T, client *pubsub.Client) (*pubsub.Topic, *pubsub.Subscription) {
+	ctx := context.Background()
+	topic, err := client.CreateTopic(ctx, "topic")
+	require.NoError(t, err)
+	sub, err := client.CreateSubscription(ctx, "subscription", pubsub.SubscriptionConfig{Topic: topic})
+	require.NoError(t, err)
+	return topic, sub
+}
+
+// waitSpans waits for the tracer to record n spans, as spans for
+// published messages are ended asynchronously.
+func waitSpans(t *testing.T, tracer *apmtest.RecordingTracer, n int) []model.Span {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		tracer.Flush(nil)
+		if spans := tracer.Payloads().Spans; len(spans) >= n || time.Now().After(deadline) {
+			require.Len(t, spans, n)
+			return spans
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
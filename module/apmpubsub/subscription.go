@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmpubsub
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// WrapSubscription wraps s such that messages received with Receive
+// are processed within a transaction.
+func WrapSubscription(s *pubsub.Subscription, o ...Option) *Subscription {
+	return &Subscription{Subscription: s, opts: newOptions(o...)}
+}
+
+// Subscription wraps a *pubsub.Subscription, reporting a transaction
+// for each message received.
+type Subscription struct {
+	*pubsub.Subscription
+	opts options
+}
+
+// Receive calls f for each message received, as with
+// pubsub.Subscription.Receive, within a transaction.
+// See WrapReceiveFunc for details.
+func (s *Subscription) Receive(ctx context.Context, f func(context.Context, *pubsub.Message)) error {
+	return s.Subscription.Receive(ctx, wrapReceiveFunc(s.Subscription.ID(), f, s.opts))
+}
+
+// WrapReceiveFunc returns a function, suitable for passing to
+// pubsub.Subscription.Receive, which calls f for each message within
+// a transaction, continuing the trace propagated through the message
+// attributes, if any.
+//
+// The transaction is named after subscription, the ID of the subscription,
+// and records the message age and delivery attempt, if known. If f panics,
+// the panic is reported as an error before the panic continues.
+func WrapReceiveFunc(subscription string, f func(context.Context, *pubsub.Message), o ...Option) func(context.Context, *pubsub.Message) {
+	return wrapReceiveFunc(subscription, f, newOptions(o...))
+}
+
+func wrapReceiveFunc(subscription string, f func(context.Context, *pubsub.Message), opts options) func(context.Context, *pubsub.Message) {
+	return func(ctx context.Context, msg *pubsub.Message) {
+		start := time.Now()
+		carrier := apmmessaging.Carrier{Headers: apmmessaging.StringMap(msg.Attributes)}
+		tx, ctx := apmmessaging.StartTransaction(ctx, opts.tracer, "Pub/Sub", subscription, start, carrier)
+		defer tx.End()
+		if tx.Sampled() {
+			var age time.Duration
+			if !msg.PublishTime.IsZero() {
+				age = start.Sub(msg.PublishTime)
+			}
+			tx.Context.SetMessage(apm.MessageContext{QueueName: subscription, Age: age})
+			if msg.DeliveryAttempt != nil {
+				tx.Context.SetLabel("pubsub.delivery_attempt", *msg.DeliveryAttempt)
+			}
+		}
+		apmmessaging.Process(opts.tracer, tx, func() { f(ctx, msg) })
+	}
+}
+
+// Option sets options for tracing Pub/Sub subscribers.
+type Option func(*options)
+
+type options struct {
+	tracer *apm.Tracer
+}
+
+func newOptions(o ...Option) options {
+	opts := options{tracer: apm.DefaultTracer}
+	for _, o := range o {
+		o(&opts)
+	}
+	return opts
+}
+
+// WithTracer returns an Option which sets t as the tracer
+// to use for tracing received messages.
+func WithTracer(t *apm.Tracer) Option {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(o *options) {
+		o.tracer = t
+	}
+}
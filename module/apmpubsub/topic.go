@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmpubsub
+
+import (
+	"context"
+
+	"cloud.google.com/go/pubsub"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// WrapTopic wraps t such that messages published with a context
+// containing a transaction are traced, and trace context is propagated
+// to subscribers through message attributes.
+func WrapTopic(t *pubsub.Topic) *Topic {
+	return &Topic{Topic: t}
+}
+
+// Topic wraps a *pubsub.Topic, reporting a span for each message
+// published within a transaction.
+type Topic struct {
+	*pubsub.Topic
+}
+
+// Publish publishes msg, as with pubsub.Topic.Publish, reporting a span
+// within the transaction in ctx, if any, and injecting trace context into
+// msg's attributes. If ctx does not contain a transaction, msg is published
+// without modification.
+//
+// Messages are published asynchronously; the span is ended once the
+// result of publishing is known.
+func (t *Topic) Publish(ctx context.Context, msg *pubsub.Message) *pubsub.PublishResult {
+	tx := apm.TransactionFromContext(ctx)
+	if tx == nil {
+		return t.Topic.Publish(ctx, msg)
+	}
+	topic := t.Topic.ID()
+
+	// Copy the attributes so the caller's map is not modified.
+	attributes := make(apmmessaging.StringMap, len(msg.Attributes)+2)
+	for k, v := range msg.Attributes {
+		attributes[k] = v
+	}
+	carrier := apmmessaging.Carrier{Headers: attributes}
+	span := apmmessaging.StartSpan(ctx, tx, "Pub/Sub", "pubsub", "SEND", topic, carrier)
+	msg.Attributes = attributes
+
+	result := t.Topic.Publish(ctx, msg)
+	if span != nil {
+		go func() {
+			<-result.Ready()
+			_, err := result.Get(context.Background())
+			apmmessaging.EndSpan(span, err)
+		}()
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmsarama
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// StartTransaction starts a transaction for processing msg, continuing
+// the trace propagated through msg's headers, if any. The transaction
+// and any propagated baggage are added to the returned context.
+//
+// The transaction records the topic, partition and offset of msg, and
+// the age of msg if its timestamp is known. It is the caller's
+// responsibility to end the transaction once msg has been processed.
+func StartTransaction(ctx context.Context, msg *sarama.ConsumerMessage, o ...Option) (*apm.Transaction, context.Context) {
+	opts := newOptions(o...)
+	start := time.Now()
+	tx, ctx := apmmessaging.StartTransaction(ctx, opts.tracer, "Kafka", msg.Topic, start, consumerHeaders(msg.Headers))
+	if tx.Sampled() {
+		tx.Context.SetMessage(apm.MessageContext{
+			QueueName: msg.Topic,
+			Age:       messageAge(msg, start),
+		})
+		tx.Context.SetLabel("kafka.partition", msg.Partition)
+		tx.Context.SetLabel("kafka.offset", msg.Offset)
+	}
+	return tx, ctx
+}
+
+// StartBatchTransaction starts a transaction for processing msgs as a
+// batch. Rather than continuing any one trace, the transaction is linked
+// to the trace context propagated through each message's headers.
+//
+// If all messages were consumed from the same topic, the transaction is
+// named after, and records, the topic. It is the caller's responsibility
+// to end the transaction once msgs have been processed.
+func StartBatchTransaction(ctx context.Context, msgs []*sarama.ConsumerMessage, o ...Option) (*apm.Transaction, context.Context) {
+	opts := newOptions(o...)
+	var txOpts apm.TransactionOptions
+	var topic string
+	for i, msg := range msgs {
+		if i == 0 {
+			topic = msg.Topic
+		} else if msg.Topic != topic {
+			topic = ""
+		}
+		if traceContext, err := opts.tracer.Propagator().Extract(consumerHeaders(msg.Headers)); err == nil {
+			txOpts.Links = append(txOpts.Links, apm.SpanLink{
+				Trace: traceContext.Trace,
+				Span:  traceContext.Span,
+			})
+		}
+	}
+	name := "Kafka RECEIVE"
+	if topic != "" {
+		name += " from " + topic
+	}
+	tx := opts.tracer.StartTransactionOptions(name, "messaging", txOpts)
+	if tx.Sampled() {
+		tx.Context.SetMessage(apm.MessageContext{QueueName: topic})
+		tx.Context.SetLabel("kafka.messages", len(msgs))
+	}
+	return tx, apm.ContextWithTransaction(ctx, tx)
+}
+
+// messageAge returns the time elapsed between msg being produced and
+// received, or zero if the message timestamp is unknown.
+func messageAge(msg *sarama.ConsumerMessage, received time.Time) time.Duration {
+	if msg.Timestamp.IsZero() {
+		return 0
+	}
+	return received.Sub(msg.Timestamp)
+}
+
+// Option sets options for tracing Kafka consumers.
+type Option func(*options)
+
+type options struct {
+	tracer *apm.Tracer
+}
+
+func newOptions(o ...Option) options {
+	opts := options{tracer: apm.DefaultTracer}
+	for _, o := range o {
+		o(&opts)
+	}
+	return opts
+}
+
+// WithTracer returns an Option which sets t as the tracer
+// to use for tracing message consumption.
+func WithTracer(t *apm.Tracer) Option {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(o *options) {
+		o.tracer = t
+	}
+}
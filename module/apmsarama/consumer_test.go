@@ -0,0 +1,156 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmsarama_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmsarama"
+)
+
+func TestStartTransaction(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	msg := consumeMessage(t, &sarama.ConsumerMessage{
+		Topic:     "orders",
+		Partition: 2,
+		Timestamp: time.Now().Add(-time.Minute),
+		Headers: []*sarama.RecordHeader{{
+			Key:   []byte("traceparent"),
+			Value: []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"),
+		}, {
+			Key:   []byte("baggage"),
+			Value: []byte("tenant=acme"),
+		}},
+	})
+	tx, ctx := apmsarama.StartTransaction(context.Background(), msg, apmsarama.WithTracer(tracer.Tracer))
+	assert.Equal(t, tx, apm.TransactionFromContext(ctx))
+	value, ok := apm.BaggageFromContext(ctx).Value("tenant")
+	assert.True(t, ok)
+	assert.Equal(t, "acme", value)
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	transaction := payloads.Transactions[0]
+	assert.Equal(t, "Kafka RECEIVE from orders", transaction.Name)
+	assert.Equal(t, "messaging", transaction.Type)
+	assert.Equal(t, model.TraceID{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c}, transaction.TraceID)
+	assert.Equal(t, model.SpanID{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31}, transaction.ParentID)
+	require.NotNil(t, transaction.Context)
+	require.NotNil(t, transaction.Context.Message)
+	assert.Equal(t, &model.MessageQueueContext{Name: "orders"}, transaction.Context.Message.Queue)
+	require.NotNil(t, transaction.Context.Message.Age)
+	assert.InDelta(t, time.Minute/time.Millisecond, transaction.Context.Message.Age.Milliseconds, float64(10*time.Second/time.Millisecond))
+	assert.Equal(t, model.IfaceMap{
+		{Key: "kafka_offset", Value: float64(msg.Offset)},
+		{Key: "kafka_partition", Value: float64(2)},
+	}, transaction.Context.Tags)
+}
+
+func TestStartTransactionNoTraceContext(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	msg := consumeMessage(t, &sarama.ConsumerMessage{Topic: "orders"})
+	tx, _ := apmsarama.StartTransaction(context.Background(), msg, apmsarama.WithTracer(tracer.Tracer))
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Zero(t, payloads.Transactions[0].ParentID)
+	assert.Nil(t, payloads.Transactions[0].Context.Message.Age)
+}
+
+func TestStartBatchTransaction(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	msgs := []*sarama.ConsumerMessage{{
+		Topic: "orders",
+		Headers: []*sarama.RecordHeader{{
+			Key:   []byte("traceparent"),
+			Value: []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"),
+		}},
+	}, {
+		Topic: "orders",
+	}, {
+		Topic: "orders",
+		Headers: []*sarama.RecordHeader{{
+			Key:   []byte("traceparent"),
+			Value: []byte("00-11111111111111111111111111111111-2222222222222222-01"),
+		}},
+	}}
+	tx, _ := apmsarama.StartBatchTransaction(context.Background(), msgs, apmsarama.WithTracer(tracer.Tracer))
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	transaction := payloads.Transactions[0]
+	assert.Equal(t, "Kafka RECEIVE from orders", transaction.Name)
+	assert.Zero(t, transaction.ParentID)
+	assert.Equal(t, []model.SpanLink{{
+		TraceID: model.TraceID{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c},
+		SpanID:  model.SpanID{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+	}, {
+		TraceID: model.TraceID{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11},
+		SpanID:  model.SpanID{0x22, 0x22, 0x22, 0x22, 0x22, 0x22, 0x22, 0x22},
+	}}, transaction.Links)
+}
+
+func TestStartBatchTransactionMultipleTopics(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	msgs := []*sarama.ConsumerMessage{{Topic: "orders"}, {Topic: "invoices"}}
+	tx, _ := apmsarama.StartBatchTransaction(context.Background(), msgs, apmsarama.WithTracer(tracer.Tracer))
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 1)
+	assert.Equal(t, "Kafka RECEIVE", payloads.Transactions[0].Name)
+	assert.Nil(t, payloads.Transactions[0].Context.Message)
+}
+
+// consumeMessage yields msg through a mock partition consumer,
+// and returns the consumed message.
+func consumeMessage(t *testing.T, msg *sarama.ConsumerMessage) *sarama.ConsumerMessage {
+	consumer := mocks.NewConsumer(t, nil)
+	defer consumer.Close()
+	consumer.ExpectConsumePartition(msg.Topic, msg.Partition, sarama.OffsetOldest).YieldMessage(msg)
+
+	pc, err := consumer.ConsumePartition(msg.Topic, msg.Partition, sarama.OffsetOldest)
+	require.NoError(t, err)
+	defer pc.Close()
+	return <-pc.Messages()
+}
@@ -0,0 +1,11 @@
+module go.elastic.co/apm/module/apmsarama
+
+require (
+	github.com/Shopify/sarama v1.27.2
+	github.com/stretchr/testify v1.6.1
+	go.elastic.co/apm v1.8.0
+)
+
+replace go.elastic.co/apm => ../..
+
+go 1.13
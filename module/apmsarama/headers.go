@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmsarama
+
+import (
+	"strings"
+
+	"github.com/Shopify/sarama"
+)
+
+// producerHeaders is an apm.PropagationCarrier backed
+// by the record headers of a sarama.ProducerMessage.
+type producerHeaders struct {
+	msg *sarama.ProducerMessage
+}
+
+// Get returns the values of the headers with the given key.
+func (c producerHeaders) Get(key string) []string {
+	var values []string
+	for _, h := range c.msg.Headers {
+		if strings.EqualFold(string(h.Key), key) {
+			values = append(values, string(h.Value))
+		}
+	}
+	return values
+}
+
+// Set sets the value of the header with the given key,
+// removing any existing headers with the same key.
+//
+// The headers are copied to a new slice, so the caller's
+// slice, which may be shared by other messages, is not
+// modified.
+func (c producerHeaders) Set(key, value string) {
+	headers := make([]sarama.RecordHeader, 0, len(c.msg.Headers)+1)
+	for _, h := range c.msg.Headers {
+		if !strings.EqualFold(string(h.Key), key) {
+			headers = append(headers, h)
+		}
+	}
+	c.msg.Headers = append(headers, sarama.RecordHeader{
+		Key:   []byte(strings.ToLower(key)),
+		Value: []byte(value),
+	})
+}
+
+// consumerHeaders is an apm.PropagationCarrier backed
+// by the record headers of a sarama.ConsumerMessage.
+//
+// consumerHeaders is read-only: Set has no effect.
+type consumerHeaders []*sarama.RecordHeader
+
+// Get returns the values of the headers with the given key.
+func (c consumerHeaders) Get(key string) []string {
+	var values []string
+	for _, h := range c {
+		if h != nil && strings.EqualFold(string(h.Key), key) {
+			values = append(values, string(h.Value))
+		}
+	}
+	return values
+}
+
+// Set has no effect; received messages are not modified.
+func (consumerHeaders) Set(key, value string) {}
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmsarama
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/internal/apmmessaging"
+)
+
+// WrapSyncProducer wraps p such that messages sent with a context
+// containing a transaction are traced, and trace context is propagated
+// to consumers through message headers.
+func WrapSyncProducer(p sarama.SyncProducer) *SyncProducer {
+	return &SyncProducer{SyncProducer: p}
+}
+
+// SyncProducer wraps a sarama.SyncProducer, reporting a span for
+// each message sent within a transaction.
+type SyncProducer struct {
+	sarama.SyncProducer
+}
+
+// SendMessage sends msg, reporting a span within the transaction in ctx,
+// and injecting trace context into msg's headers. If ctx does not contain
+// a transaction, msg is sent without modification.
+func (p *SyncProducer) SendMessage(ctx context.Context, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	span := startProducerSpan(ctx, msg)
+	partition, offset, err = p.SyncProducer.SendMessage(msg)
+	if span != nil {
+		endProducerSpan(span, partition, offset, err)
+	}
+	return partition, offset, err
+}
+
+// SendMessages sends msgs, reporting a span for each message within
+// the transaction in ctx, and injecting trace context into the headers
+// of each message. If ctx does not contain a transaction, msgs are sent
+// without modification.
+func (p *SyncProducer) SendMessages(ctx context.Context, msgs []*sarama.ProducerMessage) error {
+	spans := make([]*apm.Span, len(msgs))
+	for i, msg := range msgs {
+		spans[i] = startProducerSpan(ctx, msg)
+	}
+	err := p.SyncProducer.SendMessages(msgs)
+	var failed map[*sarama.ProducerMessage]error
+	if errs, ok := err.(sarama.ProducerErrors); ok {
+		failed = make(map[*sarama.ProducerMessage]error, len(errs))
+		for _, e := range errs {
+			failed[e.Msg] = e.Err
+		}
+	}
+	for i, span := range spans {
+		if span == nil {
+			continue
+		}
+		msgErr := err
+		if failed != nil {
+			msgErr = failed[msgs[i]]
+		}
+		endProducerSpan(span, msgs[i].Partition, msgs[i].Offset, msgErr)
+	}
+	return err
+}
+
+// startProducerSpan starts a span for sending msg, if ctx contains a
+// transaction, and injects trace context into msg's headers. If ctx
+// does not contain a transaction, startProducerSpan returns nil.
+func startProducerSpan(ctx context.Context, msg *sarama.ProducerMessage) *apm.Span {
+	tx := apm.TransactionFromContext(ctx)
+	if tx == nil {
+		return nil
+	}
+	return apmmessaging.StartSpan(ctx, tx, "Kafka", "kafka", "SEND", msg.Topic, producerHeaders{msg})
+}
+
+func endProducerSpan(span *apm.Span, partition int32, offset int64, err error) {
+	if err == nil {
+		span.Context.SetLabel("kafka.partition", partition)
+		span.Context.SetLabel("kafka.offset", offset)
+	}
+	apmmessaging.EndSpan(span, err)
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apmsarama_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/module/apmsarama"
+)
+
+func TestSyncProducerSendMessage(t *testing.T) {
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	producer := apmsarama.WrapSyncProducer(mock)
+	defer producer.Close()
+
+	msg := &sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("hello")}
+	tx, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		ctx = apm.ContextWithBaggage(ctx, apm.NewBaggage(apm.BaggageMember{Key: "tenant", Value: "acme"}))
+		_, _, err := producer.SendMessage(ctx, msg)
+		require.NoError(t, err)
+	})
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "Kafka SEND to orders", span.Name)
+	assert.Equal(t, "messaging", span.Type)
+	assert.Equal(t, "kafka", span.Subtype)
+	assert.Equal(t, "send", span.Action)
+	assert.Equal(t, "success", span.Outcome)
+	assert.Equal(t, &model.DestinationSpanContext{
+		Service: &model.DestinationServiceSpanContext{
+			Type:     "messaging",
+			Name:     "kafka",
+			Resource: "kafka/orders",
+		},
+	}, span.Context.Destination)
+	assert.Equal(t, &model.MessageContext{
+		Queue: &model.MessageQueueContext{Name: "orders"},
+	}, span.Context.Message)
+
+	headers := make(map[string]string)
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	traceparent := apm.W3CTraceContextPropagator{}
+	carrier := make(apm.HTTPHeaderCarrier)
+	traceparent.Inject(apm.TraceContext{
+		Trace:   apm.TraceID(tx.TraceID),
+		Span:    apm.SpanID(span.ID),
+		Options: apm.TraceOptions(0).WithRecorded(true),
+	}, carrier)
+	assert.Equal(t, carrier.Get("traceparent")[0], headers["traceparent"])
+	assert.Equal(t, "tenant=acme", headers["baggage"])
+}
+
+func TestSyncProducerSendMessageNoTransaction(t *testing.T) {
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	producer := apmsarama.WrapSyncProducer(mock)
+	defer producer.Close()
+
+	msg := &sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("hello")}
+	_, _, err := producer.SendMessage(context.Background(), msg)
+	require.NoError(t, err)
+	assert.Empty(t, msg.Headers)
+}
+
+func TestSyncProducerSendMessageError(t *testing.T) {
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndFail(errors.New("boom"))
+	producer := apmsarama.WrapSyncProducer(mock)
+	defer producer.Close()
+
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		_, _, err := producer.SendMessage(ctx, &sarama.ProducerMessage{Topic: "orders"})
+		assert.EqualError(t, err, "boom")
+	})
+	require.Len(t, spans, 1)
+	assert.Equal(t, "failure", spans[0].Outcome)
+}
+
+func TestSyncProducerSendMessages(t *testing.T) {
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	producer := apmsarama.WrapSyncProducer(mock)
+	defer producer.Close()
+
+	msgs := []*sarama.ProducerMessage{{Topic: "orders"}, {Topic: "invoices"}}
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		require.NoError(t, producer.SendMessages(ctx, msgs))
+	})
+	require.Len(t, spans, 2)
+	assert.Equal(t, "Kafka SEND to orders", spans[0].Name)
+	assert.Equal(t, "Kafka SEND to invoices", spans[1].Name)
+	for _, msg := range msgs {
+		assert.NotEmpty(t, msg.Headers)
+	}
+}
+
+func TestSyncProducerSendMessagesSharedHeaders(t *testing.T) {
+	mock := mocks.NewSyncProducer(t, nil)
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	producer := apmsarama.WrapSyncProducer(mock)
+	defer producer.Close()
+
+	// Messages may share a headers slice with spare capacity;
+	// injecting trace context must not modify it.
+	shared := make([]sarama.RecordHeader, 1, 10)
+	shared[0] = sarama.RecordHeader{Key: []byte("k"), Value: []byte("v")}
+	msgs := []*sarama.ProducerMessage{
+		{Topic: "orders", Headers: shared},
+		{Topic: "invoices", Headers: shared},
+	}
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		require.NoError(t, producer.SendMessages(ctx, msgs))
+	})
+	require.Len(t, spans, 2)
+	assert.Equal(t, []sarama.RecordHeader{{Key: []byte("k"), Value: []byte("v")}}, shared)
+
+	for i, msg := range msgs {
+		headers := make(map[string]string)
+		for _, h := range msg.Headers {
+			headers[string(h.Key)] = string(h.Value)
+		}
+		assert.Equal(t, "v", headers["k"])
+		assert.Equal(t, apm.FormatTraceparentHeader(apm.TraceContext{
+			Trace:   apm.TraceID(spans[i].TraceID),
+			Span:    apm.SpanID(spans[i].ID),
+			Options: apm.TraceOptions(0).WithRecorded(true),
+		}), headers["traceparent"])
+	}
+}
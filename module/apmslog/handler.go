@@ -0,0 +1,213 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.21
+
+package apmslog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/stacktrace"
+)
+
+const (
+	// FieldKeyTraceID is the attribute key for the trace ID.
+	FieldKeyTraceID = "trace.id"
+
+	// FieldKeyTransactionID is the attribute key for the transaction ID.
+	FieldKeyTransactionID = "transaction.id"
+
+	// FieldKeySpanID is the attribute key for the span ID.
+	FieldKeySpanID = "span.id"
+
+	// FieldKeyError is the attribute key for errors reported to
+	// Elastic APM.
+	FieldKeyError = "error"
+)
+
+func init() {
+	stacktrace.RegisterLibraryPackage("log/slog")
+}
+
+// Handler is an implementation of slog.Handler which wraps another
+// slog.Handler, adding trace context attributes to log records and
+// reporting error records to Elastic APM.
+//
+// Records logged with a context containing a transaction, e.g. using
+// slog.Logger.InfoContext, have trace.id, transaction.id and span.id
+// attributes added. Records at or above the error level which have an
+// "error" attribute holding an error value are reported as errors to
+// Elastic APM, associated with the transaction and span in the context.
+//
+// If the Handler has an open group (see slog.Handler.WithGroup), the
+// trace context attributes will be qualified by the group.
+type Handler struct {
+	handler    slog.Handler
+	tracer     *apm.Tracer
+	errorLevel slog.Level
+
+	// err holds an error added with WithAttrs,
+	// outside of any group.
+	err     error
+	grouped bool
+}
+
+// NewHandler returns a new Handler wrapping h.
+func NewHandler(h slog.Handler, o ...HandlerOption) *Handler {
+	handler := &Handler{
+		handler:    h,
+		errorLevel: slog.LevelError,
+	}
+	for _, o := range o {
+		o(handler)
+	}
+	if handler.tracer == nil {
+		handler.tracer = apm.DefaultTracer
+	}
+	return handler
+}
+
+// Enabled reports whether the wrapped handler handles records at level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler has the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.handler = h.handler.WithAttrs(attrs)
+	if !h.grouped {
+		if err := findError(attrs); err != nil {
+			out.err = err
+		}
+	}
+	return &out
+}
+
+// WithGroup returns a new Handler whose wrapped handler has the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.handler = h.handler.WithGroup(name)
+	out.grouped = true
+	return &out
+}
+
+// Handle adds trace context attributes to r if ctx contains a transaction,
+// reports r as an error if appropriate, and then passes r to the wrapped
+// handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	tx := apm.TransactionFromContext(ctx)
+	span := apm.SpanFromContext(ctx)
+	if tx != nil {
+		traceContext := tx.TraceContext()
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String(FieldKeyTraceID, traceContext.Trace.String()),
+			slog.String(FieldKeyTransactionID, traceContext.Span.String()),
+		)
+		if span != nil {
+			r.AddAttrs(slog.String(FieldKeySpanID, span.TraceContext().Span.String()))
+		}
+	}
+	if r.Level >= h.errorLevel && h.tracer.Recording() {
+		h.reportError(r, tx, span)
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *Handler) reportError(r slog.Record, tx *apm.Transaction, span *apm.Span) {
+	err := h.err
+	if !h.grouped {
+		r.Attrs(func(attr slog.Attr) bool {
+			if e := errorValue(attr); e != nil {
+				err = e
+				return false
+			}
+			return true
+		})
+	}
+	if err == nil {
+		return
+	}
+	errlog := h.tracer.NewErrorLog(apm.ErrorLogRecord{
+		Message: r.Message,
+		Level:   strings.ToLower(r.Level.String()),
+		Error:   err,
+	})
+	errlog.Handled = true
+	if !r.Time.IsZero() {
+		errlog.Timestamp = r.Time
+	}
+	errlog.SetStacktrace(2)
+	if span != nil {
+		errlog.SetSpan(span)
+	} else if tx != nil {
+		errlog.SetTransaction(tx)
+	}
+	errlog.Send()
+}
+
+func findError(attrs []slog.Attr) error {
+	var err error
+	for _, attr := range attrs {
+		if e := errorValue(attr); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func errorValue(attr slog.Attr) error {
+	if attr.Key != FieldKeyError {
+		return nil
+	}
+	err, _ := attr.Value.Resolve().Any().(error)
+	return err
+}
+
+// HandlerOption sets options for a Handler.
+type HandlerOption func(*Handler)
+
+// WithTracer returns a HandlerOption which sets t as the tracer
+// to use for reporting errors.
+//
+// By default, apm.DefaultTracer will be used.
+func WithTracer(t *apm.Tracer) HandlerOption {
+	if t == nil {
+		panic("t == nil")
+	}
+	return func(h *Handler) {
+		h.tracer = t
+	}
+}
+
+// WithErrorLevel returns a HandlerOption which sets the minimum level
+// of records to report as errors, if they have an "error" attribute.
+//
+// By default, records at slog.LevelError or higher are reported.
+func WithErrorLevel(level slog.Level) HandlerOption {
+	return func(h *Handler) {
+		h.errorLevel = level
+	}
+}
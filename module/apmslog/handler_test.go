@@ -0,0 +1,151 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build go1.21
+
+package apmslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/module/apmslog"
+)
+
+func TestHandlerTraceContext(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(apmslog.NewHandler(slog.NewJSONHandler(&buf, nil), apmslog.WithTracer(tracer.Tracer)))
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	span, ctx := apm.StartSpan(ctx, "name", "type")
+	logger.InfoContext(ctx, "hello")
+	span.End()
+	tx.End()
+
+	record := decodeRecord(t, &buf)
+	assert.Equal(t, "hello", record["msg"])
+	assert.Equal(t, tx.TraceContext().Trace.String(), record["trace.id"])
+	assert.Equal(t, tx.TraceContext().Span.String(), record["transaction.id"])
+	assert.Equal(t, span.TraceContext().Span.String(), record["span.id"])
+}
+
+func TestHandlerNoTransaction(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(apmslog.NewHandler(slog.NewJSONHandler(&buf, nil), apmslog.WithTracer(tracer.Tracer)))
+	logger.InfoContext(context.Background(), "hello")
+
+	record := decodeRecord(t, &buf)
+	assert.NotContains(t, record, "trace.id")
+	assert.NotContains(t, record, "transaction.id")
+	assert.NotContains(t, record, "span.id")
+}
+
+func TestHandlerError(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(apmslog.NewHandler(slog.NewJSONHandler(&buf, nil), apmslog.WithTracer(tracer.Tracer)))
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	logger.ErrorContext(ctx, "request failed", "error", errors.New("boom"))
+	logger.ErrorContext(ctx, "no error attribute")
+	logger.WarnContext(ctx, "warning", "error", errors.New("not reported"))
+	tx.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Errors, 1)
+	err0 := payloads.Errors[0]
+	assert.Equal(t, "request failed", err0.Log.Message)
+	assert.Equal(t, "error", err0.Log.Level)
+	assert.Equal(t, "boom", err0.Exception.Message)
+	assert.Equal(t, "TestHandlerError", err0.Culprit)
+	assert.Equal(t, payloads.Transactions[0].TraceID, err0.TraceID)
+	assert.Equal(t, payloads.Transactions[0].ID, err0.TransactionID)
+	assert.Equal(t, payloads.Transactions[0].ID, err0.ParentID)
+}
+
+func TestHandlerErrorWithAttrs(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(apmslog.NewHandler(slog.NewJSONHandler(&buf, nil), apmslog.WithTracer(tracer.Tracer)))
+	logger.With("error", errors.New("boom")).Error("request failed")
+	logger.WithGroup("group").Error("grouped", "error", errors.New("not reported"))
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "boom", payloads.Errors[0].Exception.Message)
+}
+
+func TestHandlerErrorLevel(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(apmslog.NewHandler(
+		slog.NewJSONHandler(&buf, nil),
+		apmslog.WithTracer(tracer.Tracer),
+		apmslog.WithErrorLevel(slog.LevelWarn),
+	))
+	logger.Warn("warning", "error", errors.New("boom"))
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Errors, 1)
+	assert.Equal(t, "warn", payloads.Errors[0].Log.Level)
+}
+
+func TestHandlerTracerInactive(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	tracer.SetRecording(false)
+
+	var buf bytes.Buffer
+	logger := slog.New(apmslog.NewHandler(slog.NewJSONHandler(&buf, nil), apmslog.WithTracer(tracer.Tracer)))
+	logger.Error("request failed", "error", errors.New("boom"))
+	tracer.Flush(nil)
+
+	assert.Empty(t, tracer.Payloads().Errors)
+	assert.Equal(t, "request failed", decodeRecord(t, &buf)["msg"])
+}
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var record map[string]interface{}
+	require.NoError(t, json.NewDecoder(buf).Decode(&record))
+	return record
+}
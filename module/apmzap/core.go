@@ -41,6 +41,9 @@ func init() {
 // Core is an implementation of zapcore.Core, reporting log records as
 // errors to the APM Server. If TraceContext is used to add trace IDs
 // to the log records, the errors reported will be associated with them.
+//
+// If LogEventMinLevel is set, log records at or above that level will also
+// be sent to the APM Server as log events, with their structured fields.
 type Core struct {
 	// Tracer is the apm.Tracer to use for reporting errors.
 	// If Tracer is nil, then apm.DefaultTracer will be used.
@@ -52,6 +55,14 @@ type Core struct {
 	// DefaultFatalFlushTimeout will be used. If the timeout
 	// is a negative value, then no flushing will be performed.
 	FatalFlushTimeout time.Duration
+
+	// LogEventMinLevel holds the minimum level of logs to send as
+	// log events, using apm.Tracer.SendLog. If LogEventMinLevel is
+	// nil, which is the default, no log events will be sent.
+	//
+	// Log records at zapcore.ErrorLevel or higher are reported as
+	// errors regardless of LogEventMinLevel.
+	LogEventMinLevel *zapcore.Level
 }
 
 func (c *Core) tracer() *apm.Tracer {
@@ -73,27 +84,33 @@ func (*Core) Sync() error {
 	return nil
 }
 
-// Enabled returns true if level is >= zapcore.ErrorLevel.
-func (*Core) Enabled(level zapcore.Level) bool {
-	return level >= zapcore.ErrorLevel
+// Enabled returns true if level is >= zapcore.ErrorLevel,
+// or if level is at or above c.LogEventMinLevel.
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.ErrorLevel || c.logEnabled(level)
+}
+
+func (c *Core) logEnabled(level zapcore.Level) bool {
+	return c.LogEventMinLevel != nil && level >= *c.LogEventMinLevel
 }
 
 // With returns a new zapcore.Core that decorates c with fields.
 func (c *Core) With(fields []zapcore.Field) zapcore.Core {
 	out := &contextCore{core: c}
 	out.traceContext.fields(fields)
+	out.fields = fields
 	return out
 }
 
 // Check checks if the entry should be logged, and adds c to checked if so.
 func (c *Core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if entry.Level < zapcore.ErrorLevel || !c.tracer().Recording() {
+	if !c.Enabled(entry.Level) || !c.tracer().Recording() {
 		return checked
 	}
 	return checked.AddCore(entry, c)
 }
 
-// Write reports entry and fields as an error using c.tracer.
+// Write reports entry and fields as an error and/or log event using c.tracer.
 func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
 	core := contextCore{core: c}
 	return core.Write(entry, fields)
@@ -102,6 +119,7 @@ func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
 type contextCore struct {
 	core         *Core
 	traceContext traceContext
+	fields       []zapcore.Field
 }
 
 func (c *contextCore) Sync() error {
@@ -109,20 +127,23 @@ func (c *contextCore) Sync() error {
 }
 
 func (c *contextCore) Enabled(level zapcore.Level) bool {
-	return level >= zapcore.ErrorLevel
+	return c.core.Enabled(level)
 }
 
 func (c *contextCore) With(fields []zapcore.Field) zapcore.Core {
 	newCore := &contextCore{
 		core:         c.core,
 		traceContext: c.traceContext,
+		fields:       make([]zapcore.Field, 0, len(c.fields)+len(fields)),
 	}
 	newCore.traceContext.fields(fields)
+	newCore.fields = append(newCore.fields, c.fields...)
+	newCore.fields = append(newCore.fields, fields...)
 	return newCore
 }
 
 func (c *contextCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if entry.Level < zapcore.ErrorLevel || !c.core.tracer().Recording() {
+	if !c.core.Enabled(entry.Level) || !c.core.tracer().Recording() {
 		return checked
 	}
 	return checked.AddCore(entry, c)
@@ -133,6 +154,13 @@ func (c *contextCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
 	traceContext.fields(fields)
 
 	tracer := c.core.tracer()
+	if c.core.logEnabled(entry.Level) {
+		c.sendLog(tracer, entry, traceContext, fields)
+	}
+	if entry.Level < zapcore.ErrorLevel {
+		return nil
+	}
+
 	errlog := tracer.NewErrorLog(apm.ErrorLogRecord{
 		Message:    entry.Message,
 		Level:      entry.Level.String(),
@@ -166,6 +194,31 @@ func (c *contextCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
 	return nil
 }
 
+func (c *contextCore) sendLog(tracer *apm.Tracer, entry zapcore.Entry, traceContext traceContext, fields []zapcore.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	addFields := func(fields []zapcore.Field) {
+		for _, field := range fields {
+			switch field.Key {
+			case FieldKeyTraceID, FieldKeyTransactionID, FieldKeySpanID:
+				continue
+			}
+			field.AddTo(enc)
+		}
+	}
+	addFields(c.fields)
+	addFields(fields)
+	tracer.SendLog(apm.LogRecord{
+		Timestamp:     entry.Time,
+		Message:       entry.Message,
+		Level:         entry.Level.String(),
+		LoggerName:    entry.LoggerName,
+		TraceID:       traceContext.traceID,
+		TransactionID: traceContext.transactionID,
+		SpanID:        traceContext.spanID,
+		Fields:        enc.Fields,
+	})
+}
+
 type traceContext struct {
 	err                   error
 	traceID               apm.TraceID
@@ -31,8 +31,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"go.elastic.co/apm"
+	"go.elastic.co/apm/model"
 	"go.elastic.co/apm/module/apmzap"
 	"go.elastic.co/apm/transport/transporttest"
 )
@@ -87,6 +89,48 @@ func TestCoreTransactionTraceContext(t *testing.T) {
 	assert.Equal(t, payloads.Transactions[0].ID, err0.TransactionID)
 }
 
+func TestCoreLogEventMinLevel(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	logEventMinLevel := zapcore.InfoLevel
+	core := &apmzap.Core{Tracer: tracer, LogEventMinLevel: &logEventMinLevel}
+	logger := zap.New(core).Named("myLogger")
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	span, ctx := apm.StartSpan(ctx, "name", "type")
+	logger = logger.With(apmzap.TraceContext(ctx)...).With(zap.String("component", "test"))
+	logger.Debug("debug")
+	logger.Info("info", zap.Int("attempt", 2))
+	logger.Error("error")
+	span.End()
+	tx.End()
+
+	tracer.Flush(nil)
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Logs, 2)
+	require.Len(t, payloads.Errors, 1)
+
+	log0 := payloads.Logs[0]
+	assert.Equal(t, "info", log0.Message)
+	assert.Equal(t, "info", log0.Level)
+	assert.Equal(t, "myLogger", log0.LoggerName)
+	assert.Equal(t, model.TraceID(tx.TraceContext().Trace), log0.TraceID)
+	assert.Equal(t, model.SpanID(tx.TraceContext().Span), log0.TransactionID)
+	assert.Equal(t, model.SpanID(span.TraceContext().Span), log0.SpanID)
+	assert.Equal(t, model.IfaceMap{
+		{Key: "attempt", Value: 2.0},
+		{Key: "component", Value: "test"},
+	}, log0.Labels)
+
+	log1 := payloads.Logs[1]
+	assert.Equal(t, "error", log1.Message)
+	assert.Equal(t, "error", log1.Level)
+	assert.Equal(t, model.IfaceMap{{Key: "component", Value: "test"}}, log1.Labels)
+	assert.Equal(t, "error", payloads.Errors[0].Log.Message)
+}
+
 func TestCoreWithError(t *testing.T) {
 	tracer, transport := transporttest.NewRecorderTracer()
 	defer tracer.Close()
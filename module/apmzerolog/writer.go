@@ -60,6 +60,9 @@ func init() {
 // apmzerolog.MarshalErrorStack in this package. The pkgerrors.MarshalStack
 // implementation omits some information, whereas apmzerolog is designed to
 // convey the complete file location and fully qualified function name.
+//
+// If LogEventMinLevel is set, log records at or above that level will also
+// be sent to the APM Server as log events, with their fields.
 type Writer struct {
 	// Tracer is the apm.Tracer to use for reporting errors.
 	// If Tracer is nil, then apm.DefaultTracer will be used.
@@ -79,6 +82,11 @@ type Writer struct {
 	// If it is less than this, zerolog.ErrorLevel will be used as
 	// the minimum instead.
 	MinLevel zerolog.Level
+
+	// LogEventMinLevel holds the minimum level of logs to send as
+	// log events, using apm.Tracer.SendLog. If LogEventMinLevel is
+	// nil, which is the default, no log events will be sent.
+	LogEventMinLevel *zerolog.Level
 }
 
 func (w *Writer) tracer() *apm.Tracer {
@@ -102,9 +110,19 @@ func (*Writer) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-// WriteLevel decodes the JSON-encoded log record in p, and reports it as an error using w.Tracer.
+func (w *Writer) logEventEnabled(level zerolog.Level) bool {
+	return w.LogEventMinLevel != nil && level >= *w.LogEventMinLevel
+}
+
+// WriteLevel decodes the JSON-encoded log record in p, and reports it as an
+// error and/or log event using w.Tracer.
 func (w *Writer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
-	if level < w.minLevel() || level >= zerolog.NoLevel {
+	if level >= zerolog.NoLevel {
+		return len(p), nil
+	}
+	sendError := level >= w.minLevel()
+	sendLog := w.logEventEnabled(level)
+	if !sendError && !sendLog {
 		return len(p), nil
 	}
 	tracer := w.tracer()
@@ -115,6 +133,20 @@ func (w *Writer) WriteLevel(level zerolog.Level, p []byte) (int, error) {
 	if err := logRecord.decode(bytes.NewReader(p)); err != nil {
 		return 0, err
 	}
+	if sendLog {
+		tracer.SendLog(apm.LogRecord{
+			Timestamp:     logRecord.timestamp,
+			Message:       logRecord.message,
+			Level:         level.String(),
+			TraceID:       logRecord.traceID,
+			TransactionID: logRecord.transactionID,
+			SpanID:        logRecord.spanID,
+			Fields:        logRecord.fields,
+		})
+	}
+	if !sendError {
+		return len(p), nil
+	}
 
 	errlog := tracer.NewErrorLog(apm.ErrorLogRecord{
 		Level:   level.String(),
@@ -156,6 +188,10 @@ type logRecord struct {
 	err                   error
 	traceID               apm.TraceID
 	transactionID, spanID apm.SpanID
+
+	// fields holds the fields of the log record other than
+	// those decoded above, and the level and error stack.
+	fields map[string]interface{}
 }
 
 func (l *logRecord) decode(r io.Reader) (result error) {
@@ -212,6 +248,25 @@ func (l *logRecord) decode(r io.Reader) (result error) {
 			return errors.Wrap(err, "invalid transaction.id")
 		}
 	}
+
+	for k, v := range m {
+		switch k {
+		case zerolog.MessageFieldName, zerolog.TimestampFieldName, zerolog.LevelFieldName,
+			zerolog.ErrorStackFieldName, TraceIDFieldName, TransactionIDFieldName, SpanIDFieldName:
+			continue
+		}
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				v = i
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			}
+		}
+		if l.fields == nil {
+			l.fields = make(map[string]interface{})
+		}
+		l.fields[k] = v
+	}
 	return nil
 }
 
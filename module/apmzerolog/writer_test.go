@@ -132,6 +132,55 @@ func TestWriterMinLevel(t *testing.T) {
 	assert.Empty(t, payloads.Errors)
 }
 
+func TestWriterLogEvents(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	t0 := time.Unix(0, 0).UTC()
+	zerolog.TimestampFunc = func() time.Time { return t0 }
+	defer func() {
+		zerolog.TimestampFunc = time.Now
+	}()
+
+	logEventMinLevel := zerolog.InfoLevel
+	writer := &apmzerolog.Writer{
+		Tracer:           tracer,
+		LogEventMinLevel: &logEventMinLevel,
+	}
+	logger := zerolog.New(writer).With().Timestamp().Str("component", "test").Logger()
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	span, ctx := apm.StartSpan(ctx, "name", "type")
+	logger = logger.Hook(apmzerolog.TraceContextHook(ctx))
+	logger.Debug().Msg("debug")
+	logger.Info().Int("attempt", 2).Msg("info")
+	logger.Error().Msg("error")
+	span.End()
+	tx.End()
+
+	tracer.Flush(nil)
+	payloads := transport.Payloads()
+	require.Len(t, payloads.Logs, 2)
+	require.Len(t, payloads.Errors, 1)
+
+	assert.Equal(t, model.LogEvent{
+		Timestamp:     model.Time(t0),
+		Message:       "info",
+		Level:         "info",
+		TraceID:       model.TraceID(tx.TraceContext().Trace),
+		TransactionID: model.SpanID(tx.TraceContext().Span),
+		SpanID:        model.SpanID(span.TraceContext().Span),
+		Labels: model.IfaceMap{
+			{Key: "attempt", Value: 2.0},
+			{Key: "component", Value: "test"},
+		},
+	}, payloads.Logs[0])
+	assert.Equal(t, "error", payloads.Logs[1].Message)
+	assert.Equal(t, "error", payloads.Logs[1].Level)
+	assert.Equal(t, "error", payloads.Errors[0].Log.Message)
+}
+
 func TestWriterWithError(t *testing.T) {
 	// Use our own ErrorStackMarshaler implementation,
 	// which records a fully qualified function name.
@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// profileFileTimeFormat is the format of the timestamps in profile
+	// file names. Timestamps are in UTC, and sort lexically in time order.
+	profileFileTimeFormat = "20060102T150405.000000000Z"
+)
+
+// profileDir is a local directory sink for profiles, for collecting
+// profiles without an APM Server. Profiles are written to files named
+// <type>-<timestamp>.pb.gz, or <type>-<timestamp>.pb if compression is
+// disabled, which may be read directly with "go tool pprof".
+type profileDir struct {
+	// path holds the path of the directory, which is created if
+	// it does not exist.
+	path string
+
+	// compress controls whether profiles are written gzip-compressed.
+	compress bool
+
+	// maxFiles, if positive, holds the maximum number of files to
+	// retain for each profile type; older files are removed.
+	maxFiles int
+
+	// maxAge, if positive, holds the maximum age of files to retain;
+	// older files are removed.
+	maxAge time.Duration
+}
+
+// writeProfile writes the profile to a new file in the directory,
+// and then applies the retention policy for the profile type.
+//
+// pprof profiles are gzip-compressed protobuf. If compression is
+// disabled, the profile is decompressed before it is written.
+func (d *profileDir) writeProfile(profileType string, profile io.Reader, now time.Time) error {
+	if err := os.MkdirAll(d.path, 0755); err != nil {
+		return err
+	}
+	ext := ".pb.gz"
+	if !d.compress {
+		ext = ".pb"
+		br := bufio.NewReader(profile)
+		if header, err := br.Peek(2); err == nil && bytes.Equal(header, []byte{0x1f, 0x8b}) {
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			profile = zr
+		} else {
+			profile = br
+		}
+	}
+
+	// Write to a temporary file first, and then rename, so that
+	// readers never observe partially written profiles.
+	f, err := ioutil.TempFile(d.path, "."+profileType+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, profile); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	name := profileType + "-" + now.UTC().Format(profileFileTimeFormat) + ext
+	if err := os.Rename(f.Name(), filepath.Join(d.path, name)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return errors.Wrap(d.removeExpired(profileType, now), "failed to apply profile retention")
+}
+
+// removeExpired removes profile files of the given type which exceed
+// the configured maximum number of files or maximum age.
+func (d *profileDir) removeExpired(profileType string, now time.Time) error {
+	if d.maxFiles <= 0 && d.maxAge <= 0 {
+		return nil
+	}
+	infos, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return err
+	}
+	type profileFile struct {
+		name      string
+		timestamp time.Time
+	}
+	var files []profileFile
+	prefix := profileType + "-"
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimPrefix(name, prefix)
+		ts = strings.TrimSuffix(strings.TrimSuffix(ts, ".gz"), ".pb")
+		timestamp, err := time.Parse(profileFileTimeFormat, ts)
+		if err != nil {
+			continue // not one of ours
+		}
+		files = append(files, profileFile{name: name, timestamp: timestamp})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].timestamp.After(files[j].timestamp)
+	})
+
+	var firstErr error
+	for i, file := range files {
+		expired := d.maxFiles > 0 && i >= d.maxFiles
+		if d.maxAge > 0 && now.Sub(file.timestamp) > d.maxAge {
+			expired = true
+		}
+		if !expired {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.path, file.name)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+)
+
+const (
+	// Profiler label keys set on goroutines for transactions
+	// started while CPU profiling is active.
+	profilerLabelTransactionName = "transaction.name"
+	profilerLabelTransactionID   = "transaction.id"
+	profilerLabelTraceID         = "trace.id"
+)
+
+// cpuProfilingActive reports whether t is currently capturing a CPU profile.
+func (t *Tracer) cpuProfilingActive() bool {
+	return atomic.LoadInt32(&t.cpuProfiling) != 0
+}
+
+// setCPUProfilingActive records whether or not t is capturing a CPU profile.
+func (t *Tracer) setCPUProfilingActive(active bool) {
+	var v int32
+	if active {
+		v = 1
+	}
+	atomic.StoreInt32(&t.cpuProfiling, v)
+}
+
+// profilerLabelsParentKey is the context key for the context whose
+// profiler labels are restored by the function returned by
+// SetProfilerLabels.
+type profilerLabelsParentKey struct{}
+
+// withProfilerLabels returns a copy of ctx with runtime/pprof labels
+// identifying tx, if the tracer is capturing a CPU profile.
+func (tx *Transaction) withProfilerLabels(ctx, parent context.Context) context.Context {
+	if tx.tracer == nil || !tx.tracer.cpuProfilingActive() {
+		return ctx
+	}
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	if tx.ended() {
+		return ctx
+	}
+	ctx = pprof.WithLabels(ctx, pprof.Labels(
+		profilerLabelTransactionName, tx.Name,
+		profilerLabelTransactionID, tx.traceContext.Span.String(),
+		profilerLabelTraceID, tx.traceContext.Trace.String(),
+	))
+	return context.WithValue(ctx, profilerLabelsParentKey{}, parent)
+}
+
+// SetProfilerLabels sets the runtime/pprof labels added to ctx by
+// ContextWithTransaction on the calling goroutine, and returns a function
+// which restores the goroutine's labels to those of the context passed
+// to ContextWithTransaction. If ctx has no such labels, SetProfilerLabels
+// does nothing, and returns a function which does nothing.
+//
+// The returned function must be called on the same goroutine, typically
+// by deferring it once the transaction has been added to the context:
+//
+//	ctx = apm.ContextWithTransaction(ctx, tx)
+//	defer apm.SetProfilerLabels(ctx)()
+func SetProfilerLabels(ctx context.Context) (restore func()) {
+	parent, ok := ctx.Value(profilerLabelsParentKey{}).(context.Context)
+	if !ok {
+		return func() {}
+	}
+	pprof.SetGoroutineLabels(ctx)
+	return func() { pprof.SetGoroutineLabels(parent) }
+}
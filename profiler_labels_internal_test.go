@@ -0,0 +1,82 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetProfilerLabels(t *testing.T) {
+	tracer, err := NewTracer("", "")
+	require.NoError(t, err)
+	defer tracer.Close()
+	tracer.setCPUProfilingActive(true)
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	parent := pprof.WithLabels(context.Background(), pprof.Labels("parent", "value"))
+	pprof.SetGoroutineLabels(parent)
+
+	tx := tracer.StartTransaction("name", "type")
+	ctx := ContextWithTransaction(parent, tx)
+	assert.NotContains(t, goroutineLabels(t), `"transaction.name":"name"`)
+
+	restore := SetProfilerLabels(ctx)
+	assert.Contains(t, goroutineLabels(t), `"transaction.name":"name"`)
+
+	// Ending the transaction, here on another goroutine,
+	// leaves the goroutine labels alone.
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		tx.End()
+	}()
+	<-ended
+	assert.Contains(t, goroutineLabels(t), `"transaction.name":"name"`)
+
+	restore()
+	labels := goroutineLabels(t)
+	assert.NotContains(t, labels, `"transaction.name":"name"`)
+	assert.Contains(t, labels, `labels: {"parent":"value"}`)
+}
+
+func TestSetProfilerLabelsInactive(t *testing.T) {
+	tracer, err := NewTracer("", "")
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	tx := tracer.StartTransaction("name", "type")
+	defer tx.End()
+	ctx := ContextWithTransaction(context.Background(), tx)
+	_, ok := pprof.Label(ctx, profilerLabelTransactionName)
+	assert.False(t, ok)
+	SetProfilerLabels(ctx)()
+}
+
+// goroutineLabels returns the goroutine profile in debug format,
+// which includes the labels of each goroutine.
+func goroutineLabels(t *testing.T) string {
+	var buf bytes.Buffer
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	return buf.String()
+}
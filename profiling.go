@@ -21,6 +21,8 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
+	"runtime"
 	"runtime/pprof"
 	"time"
 
@@ -30,14 +32,16 @@ import (
 type profilingState struct {
 	profileType  string
 	profileStart func(io.Writer) error
-	profileStop  func()
+	profileStop  func(io.Writer) error
 	sender       profileSender
+	dir          *profileDir
 
 	interval time.Duration
 	duration time.Duration // not relevant to all profiles
 
 	timer      *time.Timer
 	timerStart time.Time
+	running    bool
 	buf        bytes.Buffer
 	finished   chan struct{}
 }
@@ -46,7 +50,11 @@ type profilingState struct {
 // profiler type set to "cpu", and using pprof.StartCPUProfile
 // and pprof.StopCPUProfile.
 func newCPUProfilingState(sender profileSender) *profilingState {
-	return newProfilingState("cpu", pprof.StartCPUProfile, pprof.StopCPUProfile, sender)
+	profileStop := func(io.Writer) error {
+		pprof.StopCPUProfile()
+		return nil
+	}
+	return newProfilingState("cpu", pprof.StartCPUProfile, profileStop, sender)
 }
 
 // newHeapProfilingState calls newProfilingState with the
@@ -55,6 +63,45 @@ func newHeapProfilingState(sender profileSender) *profilingState {
 	return newLookupProfilingState("heap", sender)
 }
 
+// newGoroutineProfilingState calls newProfilingState with the
+// profiler type set to "goroutine", and using pprof.Lookup("goroutine").WriteTo(writer, 0).
+func newGoroutineProfilingState(sender profileSender) *profilingState {
+	return newLookupProfilingState("goroutine", sender)
+}
+
+// newMutexProfilingState calls newProfilingState with the profiler
+// type set to "mutex". For the duration of each profile, the mutex
+// profile fraction is set to fraction, and then restored to its
+// previous value before writing pprof.Lookup("mutex").
+func newMutexProfilingState(sender profileSender, fraction int) *profilingState {
+	return newSampledLookupProfilingState("mutex", func() func() {
+		if fraction <= 0 {
+			return func() {}
+		}
+		prev := runtime.SetMutexProfileFraction(fraction)
+		return func() { runtime.SetMutexProfileFraction(prev) }
+	}, sender)
+}
+
+// newBlockProfilingState calls newProfilingState with the profiler
+// type set to "block". If rate is zero, the block profile rate is left
+// unchanged, and the profile reports only what the application samples.
+// Otherwise, for the duration of each profile, the block profile rate
+// is set to rate, and then disabled before writing pprof.Lookup("block").
+//
+// The runtime does not report the current block profile rate, so it
+// cannot be restored; setting rate disables any block profiling
+// configured by the application.
+func newBlockProfilingState(sender profileSender, rate int) *profilingState {
+	return newSampledLookupProfilingState("block", func() func() {
+		if rate <= 0 {
+			return func() {}
+		}
+		runtime.SetBlockProfileRate(rate)
+		return func() { runtime.SetBlockProfileRate(0) }
+	}, sender)
+}
+
 func newLookupProfilingState(name string, sender profileSender) *profilingState {
 	profileStart := func(w io.Writer) error {
 		profile := pprof.Lookup(name)
@@ -63,7 +110,33 @@ func newLookupProfilingState(name string, sender profileSender) *profilingState
 		}
 		return profile.WriteTo(w, 0)
 	}
-	return newProfilingState("heap", profileStart, func() {}, sender)
+	profileStop := func(io.Writer) error { return nil }
+	return newProfilingState(name, profileStart, profileStop, sender)
+}
+
+// newSampledLookupProfilingState returns a profilingState for a
+// pprof.Lookup profile whose sampling must be enabled for the duration
+// of the profile. The profile is written when the profile is stopped.
+//
+// enableSampling is called when the profile is started, and returns a
+// function which is called to restore the previous sampling state when
+// the profile is stopped. If the profile duration is zero, sampling is
+// enabled only momentarily; this is useful for capturing profiles of
+// applications that enable sampling themselves.
+func newSampledLookupProfilingState(name string, enableSampling func() func(), sender profileSender) *profilingState {
+	var restoreSampling func()
+	profileStart := func(io.Writer) error {
+		if pprof.Lookup(name) == nil {
+			return errors.Errorf("no profile called %q", name)
+		}
+		restoreSampling = enableSampling()
+		return nil
+	}
+	profileStop := func(w io.Writer) error {
+		restoreSampling()
+		return pprof.Lookup(name).WriteTo(w, 0)
+	}
+	return newProfilingState(name, profileStart, profileStop, sender)
 }
 
 // newProfilingState returns a new profilingState,
@@ -72,7 +145,7 @@ func newLookupProfilingState(name string, sender profileSender) *profilingState
 func newProfilingState(
 	profileType string,
 	profileStart func(io.Writer) error,
-	profileStop func(),
+	profileStop func(io.Writer) error,
 	sender profileSender,
 ) *profilingState {
 	state := &profilingState{
@@ -90,23 +163,31 @@ func newProfilingState(
 }
 
 func (state *profilingState) updateConfig(interval, duration time.Duration) {
-	if state.sender == nil {
-		// No profile sender, no point in starting a timer.
+	if state.sender == nil && state.dir == nil {
+		// No profile sender or directory, no point in starting a timer.
 		return
 	}
 	state.duration = duration
 	if state.interval == interval {
 		return
 	}
-	if state.timerStart.IsZero() {
-		state.interval = interval
-		state.resetTimer()
+	state.interval = interval
+	if state.running {
+		// The timer will be reset with the new
+		// interval when the profile is finished.
+		return
 	}
-	// TODO(axw) handle extending/cutting short running timers once
-	// it is possible to dynamically control profiling configuration.
+	if !state.timerStart.IsZero() && !state.timer.Stop() {
+		<-state.timer.C
+	}
+	state.resetTimer()
 }
 
+// resetTimer resets the timer to fire after the configured interval,
+// or leaves it stopped if the interval is zero. resetTimer must only
+// be called when the timer is stopped or has fired and been drained.
 func (state *profilingState) resetTimer() {
+	state.running = false
 	if state.interval != 0 {
 		state.timer.Reset(state.interval)
 		state.timerStart = time.Now()
@@ -115,7 +196,8 @@ func (state *profilingState) resetTimer() {
 	}
 }
 
-// start spawns a goroutine that will capture a profile, send it using state.sender,
+// start spawns a goroutine that will capture a profile, write it to state.dir
+// and send it using state.sender, if they are non-nil,
 // and finally signal state.finished.
 //
 // start will return immediately after spawning the goroutine.
@@ -123,6 +205,7 @@ func (state *profilingState) start(ctx context.Context, logger Logger, metadata
 	// The state.duration field may be updated after the goroutine starts,
 	// by the caller, so it must be read outside the goroutine.
 	duration := state.duration
+	state.running = true
 	go func() {
 		defer func() { state.finished <- struct{}{} }()
 		if err := state.profile(ctx, duration); err != nil {
@@ -131,6 +214,19 @@ func (state *profilingState) start(ctx context.Context, logger Logger, metadata
 			}
 			return
 		}
+		if state.dir != nil {
+			profile := bytes.NewReader(state.buf.Bytes())
+			if err := state.dir.writeProfile(state.profileType, profile, time.Now()); err != nil {
+				if logger != nil {
+					logger.Errorf("failed to write %s profile: %s", state.profileType, err)
+				}
+			} else if logger != nil {
+				logger.Debugf("wrote %s profile to %s", state.profileType, state.dir.path)
+			}
+		}
+		if state.sender == nil {
+			return
+		}
 		// TODO(axw) backoff like SendStream requests
 		if err := state.sender.SendProfile(ctx, metadata, &state.buf); err != nil {
 			if logger != nil && ctx.Err() == nil {
@@ -144,22 +240,33 @@ func (state *profilingState) start(ctx context.Context, logger Logger, metadata
 	}()
 }
 
+// wait waits for an in-progress profile, if any, to finish.
+func (state *profilingState) wait() {
+	if state.running {
+		<-state.finished
+		state.running = false
+	}
+}
+
 func (state *profilingState) profile(ctx context.Context, duration time.Duration) error {
 	state.buf.Reset()
 	if err := state.profileStart(&state.buf); err != nil {
 		return errors.Wrapf(err, "failed to start %s profile", state.profileType)
 	}
-	defer state.profileStop()
 
 	if duration > 0 {
 		timer := time.NewTimer(duration)
 		defer timer.Stop()
 		select {
 		case <-ctx.Done():
+			state.profileStop(ioutil.Discard)
 			return ctx.Err()
 		case <-timer.C:
 		}
 	}
+	if err := state.profileStop(&state.buf); err != nil {
+		return errors.Wrapf(err, "failed to stop %s profile", state.profileType)
+	}
 	return nil
 }
 
@@ -20,16 +20,25 @@ package apm_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmconfig"
 	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/transport/transporttest"
 )
 
 func TestTracerCPUProfiling(t *testing.T) {
@@ -83,6 +92,235 @@ func TestTracerHeapProfiling(t *testing.T) {
 	}, info.sampleTypes)
 }
 
+func TestTracerGoroutineProfiling(t *testing.T) {
+	os.Setenv("ELASTIC_APM_GOROUTINE_PROFILE_INTERVAL", "100ms")
+	defer os.Unsetenv("ELASTIC_APM_GOROUTINE_PROFILE_INTERVAL")
+
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	info := parseProfile(waitProfile(t, tracer))
+	assert.EqualValues(t, []string{"goroutine/count"}, info.sampleTypes)
+}
+
+func TestTracerMutexProfiling(t *testing.T) {
+	os.Setenv("ELASTIC_APM_MUTEX_PROFILE_INTERVAL", "100ms")
+	os.Setenv("ELASTIC_APM_MUTEX_PROFILE_DURATION", "200ms")
+	defer os.Unsetenv("ELASTIC_APM_MUTEX_PROFILE_INTERVAL")
+	defer os.Unsetenv("ELASTIC_APM_MUTEX_PROFILE_DURATION")
+
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go contendMutex(stop)
+
+	info := parseProfile(waitProfile(t, tracer))
+	assert.EqualValues(t, []string{"contentions/count", "delay/nanoseconds"}, info.sampleTypes)
+
+	// The mutex profile fraction should be restored after profiling.
+	tracer.Close()
+	assert.Equal(t, 0, runtime.SetMutexProfileFraction(-1))
+}
+
+func TestTracerBlockProfiling(t *testing.T) {
+	os.Setenv("ELASTIC_APM_BLOCK_PROFILE_INTERVAL", "100ms")
+	os.Setenv("ELASTIC_APM_BLOCK_PROFILE_DURATION", "200ms")
+	os.Setenv("ELASTIC_APM_BLOCK_PROFILE_RATE", "10000")
+	defer os.Unsetenv("ELASTIC_APM_BLOCK_PROFILE_INTERVAL")
+	defer os.Unsetenv("ELASTIC_APM_BLOCK_PROFILE_DURATION")
+	defer os.Unsetenv("ELASTIC_APM_BLOCK_PROFILE_RATE")
+
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	info := parseProfile(waitProfile(t, tracer))
+	assert.EqualValues(t, []string{"contentions/count", "delay/nanoseconds"}, info.sampleTypes)
+}
+
+func TestTracerBlockProfilingPreservesRate(t *testing.T) {
+	os.Setenv("ELASTIC_APM_BLOCK_PROFILE_INTERVAL", "100ms")
+	os.Setenv("ELASTIC_APM_BLOCK_PROFILE_DURATION", "10ms")
+	defer os.Unsetenv("ELASTIC_APM_BLOCK_PROFILE_INTERVAL")
+	defer os.Unsetenv("ELASTIC_APM_BLOCK_PROFILE_DURATION")
+
+	// The block profile rate set by the application must not be
+	// changed by the tracer, unless ELASTIC_APM_BLOCK_PROFILE_RATE
+	// is specified.
+	runtime.SetBlockProfileRate(1)
+	defer runtime.SetBlockProfileRate(0)
+
+	tracer := apmtest.NewRecordingTracer()
+	waitProfile(t, tracer)
+	tracer.Close()
+
+	before := blockProfileEvents()
+	blockOnChannel()
+	assert.True(t, blockProfileEvents() > before)
+}
+
+// blockProfileEvents returns the total number of
+// events recorded in the block profile.
+func blockProfileEvents() int64 {
+	records := make([]runtime.BlockProfileRecord, 100)
+	for {
+		n, ok := runtime.BlockProfile(records)
+		if ok {
+			var total int64
+			for _, r := range records[:n] {
+				total += r.Count
+			}
+			return total
+		}
+		records = make([]runtime.BlockProfileRecord, n+100)
+	}
+}
+
+// blockOnChannel blocks on a channel receive, recording a
+// block profile event if block profiling is enabled.
+func blockOnChannel() {
+	ch := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(ch)
+	}()
+	<-ch
+}
+
+func TestTracerCentralConfigProfiling(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	changes := make(chan apmconfig.Change)
+	watcherFunc := apmtest.WatchConfigFunc(func(ctx context.Context, params apmconfig.WatchParams) <-chan apmconfig.Change {
+		return changes
+	})
+	tracer.SetConfigWatcher(watcherFunc)
+	changes <- apmconfig.Change{Attrs: map[string]string{"goroutine_profile_interval": "100ms"}}
+
+	info := parseProfile(waitProfile(t, tracer))
+	assert.EqualValues(t, []string{"goroutine/count"}, info.sampleTypes)
+}
+
+func TestTracerProfileDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm_profiledir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("ELASTIC_APM_PROFILE_DIR", dir)
+	os.Setenv("ELASTIC_APM_PROFILE_DIR_MAX_FILES", "2")
+	os.Setenv("ELASTIC_APM_HEAP_PROFILE_INTERVAL", "10ms")
+	defer os.Unsetenv("ELASTIC_APM_PROFILE_DIR")
+	defer os.Unsetenv("ELASTIC_APM_PROFILE_DIR_MAX_FILES")
+	defer os.Unsetenv("ELASTIC_APM_HEAP_PROFILE_INTERVAL")
+
+	// The profile directory is used even if the
+	// transport does not support sending profiles.
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{Transport: transporttest.Discard})
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	// Wait for enough profiles to be written for
+	// the retention policy to take effect.
+	time.Sleep(500 * time.Millisecond)
+	tracer.Close()
+
+	names := readProfileDir(t, dir)
+	require.Len(t, names, 2)
+	for _, name := range names {
+		assert.Regexp(t, `^heap-\d{8}T\d{6}\.\d{9}Z\.pb\.gz$`, name)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x1f, 0x8b}, data[:2]) // gzip
+	info := parseProfile(data)
+	assert.Contains(t, info.sampleTypes, "inuse_space/bytes")
+}
+
+func TestTracerProfileDirUncompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm_profiledir")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("ELASTIC_APM_PROFILE_DIR", dir)
+	os.Setenv("ELASTIC_APM_PROFILE_DIR_COMPRESS", "false")
+	os.Setenv("ELASTIC_APM_GOROUTINE_PROFILE_INTERVAL", "100ms")
+	defer os.Unsetenv("ELASTIC_APM_PROFILE_DIR")
+	defer os.Unsetenv("ELASTIC_APM_PROFILE_DIR_COMPRESS")
+	defer os.Unsetenv("ELASTIC_APM_GOROUTINE_PROFILE_INTERVAL")
+
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{Transport: transporttest.Discard})
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	timeout := time.After(10 * time.Second)
+	tick := time.Tick(50 * time.Millisecond)
+	var names []string
+	for len(names) == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for profile")
+		case <-tick:
+		}
+		names = readProfileDir(t, dir)
+	}
+	assert.Regexp(t, `^goroutine-.*\.pb$`, names[0])
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, names[0]))
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte{0x1f, 0x8b}, data[:2])
+	info := parseProfile(data)
+	assert.EqualValues(t, []string{"goroutine/count"}, info.sampleTypes)
+}
+
+// readProfileDir returns the names of the profile files in dir,
+// ignoring any temporary files.
+func readProfileDir(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), ".") {
+			names = append(names, info.Name())
+		}
+	}
+	return names
+}
+
+func waitProfile(t *testing.T, tracer *apmtest.RecordingTracer) []byte {
+	timeout := time.After(10 * time.Second)
+	tick := time.Tick(50 * time.Millisecond)
+	for {
+		if profiles := tracer.Payloads().Profiles; len(profiles) > 0 {
+			return profiles[0]
+		}
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for profile")
+		case <-tick:
+		}
+	}
+}
+
+func contendMutex(stop <-chan struct{}) {
+	var mu sync.Mutex
+	for i := 0; i < 2; i++ {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				mu.Lock()
+				time.Sleep(time.Millisecond)
+				mu.Unlock()
+			}
+		}()
+	}
+}
+
 // parseProfile parses the profile data using "go tool pprof".
 //
 // We could use github.com/google/pprof, but prefer not to add
@@ -111,15 +349,73 @@ func parseProfile(data []byte) profileInfo {
 	for scanner.Scan() {
 		if scanner.Text() == "Samples:" && scanner.Scan() {
 			info.sampleTypes = strings.Fields(scanner.Text())
-			return info
+			break
+		}
+	}
+	if info.sampleTypes == nil {
+		panic("failed to locate sample types")
+	}
+	// Samples are followed by an indented line holding
+	// their labels, if any.
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			break
+		}
+		if strings.Contains(line, ":[") {
+			info.labels = append(info.labels, strings.TrimSpace(line))
 		}
 	}
 	if err := scanner.Err(); err != nil {
 		panic(err)
 	}
-	panic("failed to locate sample types")
+	return info
 }
 
 type profileInfo struct {
 	sampleTypes []string
+	labels      []string
+}
+
+func TestTracerCPUProfilingLabels(t *testing.T) {
+	os.Setenv("ELASTIC_APM_CPU_PROFILE_INTERVAL", "100ms")
+	os.Setenv("ELASTIC_APM_CPU_PROFILE_DURATION", "1s")
+	defer os.Unsetenv("ELASTIC_APM_CPU_PROFILE_INTERVAL")
+	defer os.Unsetenv("ELASTIC_APM_CPU_PROFILE_DURATION")
+
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	timeout := time.After(10 * time.Second)
+	var profiles [][]byte
+	var labelled bool
+	for len(profiles) == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for profile")
+		default:
+		}
+		tx := tracer.StartTransaction("name", "type")
+		ctx := apm.ContextWithTransaction(context.Background(), tx)
+		restore := apm.SetProfilerLabels(ctx)
+		if value, ok := pprof.Label(ctx, "transaction.name"); ok {
+			assert.Equal(t, "name", value)
+			value, _ = pprof.Label(ctx, "transaction.id")
+			assert.Equal(t, tx.TraceContext().Span.String(), value)
+			value, _ = pprof.Label(ctx, "trace.id")
+			assert.Equal(t, tx.TraceContext().Trace.String(), value)
+			labelled = true
+		}
+		busyWork(10 * time.Millisecond)
+		restore()
+		tx.End()
+		profiles = tracer.Payloads().Profiles
+	}
+	assert.True(t, labelled)
+
+	info := parseProfile(profiles[0])
+	assert.NotEmpty(t, info.labels)
+	for _, labels := range info.labels {
+		assert.Contains(t, labels, "transaction.name:[name]")
+	}
 }
@@ -0,0 +1,525 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	elasticTraceparentHeader = "Elastic-Apm-Traceparent"
+	w3cTraceparentHeader     = "Traceparent"
+	tracestateHeader         = "Tracestate"
+	b3Header                 = "B3"
+	b3TraceIDHeader          = "X-B3-Traceid"
+	b3SpanIDHeader           = "X-B3-Spanid"
+	b3SampledHeader          = "X-B3-Sampled"
+	b3FlagsHeader            = "X-B3-Flags"
+	jaegerHeader             = "Uber-Trace-Id"
+	xrayHeader               = "X-Amzn-Trace-Id"
+)
+
+// ErrTraceContextNotFound is returned by Propagator.Extract
+// when the carrier does not contain a trace context.
+var ErrTraceContextNotFound = errors.New("trace context not found")
+
+// PropagationCarrier is the interface for reading and writing trace
+// context propagation fields, such as HTTP headers or gRPC metadata.
+//
+// Keys are case-insensitive; implementations must normalise them
+// as necessary.
+type PropagationCarrier interface {
+	// Get returns the values associated with key.
+	Get(key string) []string
+
+	// Set sets the value associated with key, replacing
+	// any existing values.
+	Set(key, value string)
+}
+
+// HTTPHeaderCarrier is a PropagationCarrier backed by http.Header.
+type HTTPHeaderCarrier http.Header
+
+// Get returns the values of the header with the given name.
+func (c HTTPHeaderCarrier) Get(key string) []string {
+	return c[textproto.CanonicalMIMEHeaderKey(key)]
+}
+
+// Set sets the value of the header with the given name.
+func (c HTTPHeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// Propagator is the interface for propagating trace context across
+// process boundaries, by injecting it into and extracting it from
+// a PropagationCarrier.
+type Propagator interface {
+	// Inject injects traceContext into carrier.
+	Inject(traceContext TraceContext, carrier PropagationCarrier)
+
+	// Extract extracts a trace context from carrier. If carrier does
+	// not contain a trace context, ErrTraceContextNotFound is returned.
+	Extract(carrier PropagationCarrier) (TraceContext, error)
+}
+
+// CompositePropagator is a Propagator which injects trace context
+// with each of its propagators, and extracts trace context with the
+// first propagator that finds a valid trace context in the carrier.
+type CompositePropagator []Propagator
+
+// Inject injects traceContext into carrier using each propagator.
+func (p CompositePropagator) Inject(traceContext TraceContext, carrier PropagationCarrier) {
+	for _, p := range p {
+		p.Inject(traceContext, carrier)
+	}
+}
+
+// Extract extracts a trace context from carrier, using the first
+// propagator which successfully extracts a trace context.
+//
+// If no propagator finds a trace context, ErrTraceContextNotFound
+// is returned; if a trace context is found but none are valid, the
+// first error is returned.
+func (p CompositePropagator) Extract(carrier PropagationCarrier) (TraceContext, error) {
+	var firstErr error
+	for _, p := range p {
+		traceContext, err := p.Extract(carrier)
+		if err == nil {
+			return traceContext, nil
+		}
+		if firstErr == nil && err != ErrTraceContextNotFound {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = ErrTraceContextNotFound
+	}
+	return TraceContext{}, firstErr
+}
+
+// W3CTraceContextPropagator is a Propagator which propagates trace context
+// with the W3C Trace-Context "traceparent" and "tracestate" headers.
+//
+// The legacy "Elastic-Apm-Traceparent" header is always extracted, taking
+// precedence over "traceparent", but is injected only if PropagateLegacyHeader
+// is true.
+type W3CTraceContextPropagator struct {
+	// PropagateLegacyHeader controls whether the legacy
+	// "Elastic-Apm-Traceparent" header is injected.
+	PropagateLegacyHeader bool
+}
+
+// Inject injects traceContext into carrier.
+func (p W3CTraceContextPropagator) Inject(traceContext TraceContext, carrier PropagationCarrier) {
+	headerValue := FormatTraceparentHeader(traceContext)
+	if p.PropagateLegacyHeader {
+		carrier.Set(elasticTraceparentHeader, headerValue)
+	}
+	carrier.Set(w3cTraceparentHeader, headerValue)
+	if tracestate := traceContext.State.String(); tracestate != "" {
+		carrier.Set(tracestateHeader, tracestate)
+	}
+}
+
+// Extract extracts a trace context from carrier.
+func (W3CTraceContextPropagator) Extract(carrier PropagationCarrier) (TraceContext, error) {
+	traceContext, err := extractTraceparentHeader(carrier, elasticTraceparentHeader)
+	if err != nil {
+		var w3cErr error
+		traceContext, w3cErr = extractTraceparentHeader(carrier, w3cTraceparentHeader)
+		if w3cErr != ErrTraceContextNotFound || err == ErrTraceContextNotFound {
+			err = w3cErr
+		}
+		if err != nil {
+			return TraceContext{}, err
+		}
+	}
+	traceContext.State = parseTracestateHeader(carrier.Get(tracestateHeader)...)
+	return traceContext, nil
+}
+
+func extractTraceparentHeader(carrier PropagationCarrier, header string) (TraceContext, error) {
+	switch values := carrier.Get(header); len(values) {
+	case 0:
+		return TraceContext{}, ErrTraceContextNotFound
+	case 1:
+		return ParseTraceparentHeader(values[0])
+	default:
+		return TraceContext{}, errors.Errorf("multiple %s headers", header)
+	}
+}
+
+// FormatTraceparentHeader formats the given trace context as a
+// W3C Trace-Context traceparent header value. The trace context's
+// TraceState is ignored.
+func FormatTraceparentHeader(c TraceContext) string {
+	const version = 0
+	return fmt.Sprintf("%02x-%032x-%016x-%02x", version, c.Trace[:], c.Span[:], c.Options)
+}
+
+// ParseTraceparentHeader parses the given W3C Trace-Context traceparent
+// header value. The returned TraceContext's TraceState field will be the
+// empty value.
+func ParseTraceparentHeader(h string) (TraceContext, error) {
+	var out TraceContext
+	if len(h) < 3 || h[2] != '-' {
+		return out, errors.Errorf("invalid traceparent header %q", h)
+	}
+	var version byte
+	if !strings.HasPrefix(h, "00") {
+		decoded, err := hex.DecodeString(h[:2])
+		if err != nil {
+			return out, errors.Wrap(err, "error decoding traceparent header version")
+		}
+		version = decoded[0]
+	}
+	h = h[3:]
+
+	switch version {
+	case 255:
+		// "Version 255 is invalid."
+		return out, errors.Errorf("traceparent header version 255 is forbidden")
+	default:
+		// "If higher version is detected - implementation SHOULD try to parse it."
+		fallthrough
+	case 0:
+		// Version 00:
+		//
+		//     version-format   = trace-id "-" span-id "-" trace-options
+		//     trace-id         = 32HEXDIG
+		//     span-id          = 16HEXDIG
+		//     trace-options    = 2HEXDIG
+		const (
+			traceIDEnd        = 32
+			spanIDStart       = traceIDEnd + 1
+			spanIDEnd         = spanIDStart + 16
+			traceOptionsStart = spanIDEnd + 1
+			traceOptionsEnd   = traceOptionsStart + 2
+		)
+		switch {
+		case len(h) < traceOptionsEnd,
+			h[traceIDEnd] != '-',
+			h[spanIDEnd] != '-',
+			version == 0 && len(h) != traceOptionsEnd,
+			version > 0 && len(h) > traceOptionsEnd && h[traceOptionsEnd] != '-':
+			return out, errors.Errorf("invalid version %d traceparent header %q", version, h)
+		}
+		if _, err := hex.Decode(out.Trace[:], []byte(h[:traceIDEnd])); err != nil {
+			return out, errors.Wrapf(err, "error decoding trace-id for version %d", version)
+		}
+		if err := out.Trace.Validate(); err != nil {
+			return out, errors.Wrap(err, "invalid trace-id")
+		}
+		if _, err := hex.Decode(out.Span[:], []byte(h[spanIDStart:spanIDEnd])); err != nil {
+			return out, errors.Wrapf(err, "error decoding span-id for version %d", version)
+		}
+		if err := out.Span.Validate(); err != nil {
+			return out, errors.Wrap(err, "invalid span-id")
+		}
+		var traceOptions [1]byte
+		if _, err := hex.Decode(traceOptions[:], []byte(h[traceOptionsStart:traceOptionsEnd])); err != nil {
+			return out, errors.Wrapf(err, "error decoding trace-options for version %d", version)
+		}
+		out.Options = TraceOptions(traceOptions[0])
+		return out, nil
+	}
+}
+
+// parseTracestateHeader parses the given tracestate header values,
+// returning an empty TraceState if any entry is malformed.
+func parseTracestateHeader(h ...string) TraceState {
+	var entries []TraceStateEntry
+	for _, h := range h {
+		for _, kv := range strings.Split(h, ",") {
+			kv = strings.TrimSpace(kv)
+			if kv == "" {
+				continue
+			}
+			equal := strings.IndexRune(kv, '=')
+			if equal == -1 {
+				return TraceState{}
+			}
+			entries = append(entries, TraceStateEntry{Key: kv[:equal], Value: kv[equal+1:]})
+		}
+	}
+	return NewTraceState(entries...)
+}
+
+// B3Propagator is a Propagator which propagates trace context with the
+// Zipkin B3 headers. Trace context is extracted from either the single
+// "b3" header, or the multiple "X-B3-*" headers, with the former taking
+// precedence.
+//
+// If the sampling decision is deferred, i.e. the sampling state is not
+// propagated, then the trace context is considered to be sampled.
+type B3Propagator struct {
+	// SingleHeader controls whether trace context is injected
+	// into the single "b3" header, rather than the multiple
+	// "X-B3-*" headers.
+	SingleHeader bool
+}
+
+// Inject injects traceContext into carrier.
+func (p B3Propagator) Inject(traceContext TraceContext, carrier PropagationCarrier) {
+	sampled := "0"
+	if traceContext.Options.Recorded() {
+		sampled = "1"
+	}
+	if p.SingleHeader {
+		carrier.Set(b3Header, fmt.Sprintf("%032x-%016x-%s", traceContext.Trace[:], traceContext.Span[:], sampled))
+		return
+	}
+	carrier.Set(b3TraceIDHeader, fmt.Sprintf("%032x", traceContext.Trace[:]))
+	carrier.Set(b3SpanIDHeader, fmt.Sprintf("%016x", traceContext.Span[:]))
+	carrier.Set(b3SampledHeader, sampled)
+}
+
+// Extract extracts a trace context from carrier.
+func (B3Propagator) Extract(carrier PropagationCarrier) (TraceContext, error) {
+	if value := firstValue(carrier, b3Header); value != "" {
+		// b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+		fields := strings.Split(value, "-")
+		if len(fields) < 2 {
+			// Only a sampling state is specified; there is no trace context.
+			return TraceContext{}, ErrTraceContextNotFound
+		}
+		var sampled string
+		if len(fields) > 2 {
+			sampled = fields[2]
+		}
+		return makeB3TraceContext(fields[0], fields[1], sampled)
+	}
+	traceID := firstValue(carrier, b3TraceIDHeader)
+	spanID := firstValue(carrier, b3SpanIDHeader)
+	if traceID == "" && spanID == "" {
+		return TraceContext{}, ErrTraceContextNotFound
+	}
+	sampled := firstValue(carrier, b3SampledHeader)
+	if firstValue(carrier, b3FlagsHeader) == "1" {
+		sampled = "d" // debug implies sampled
+	}
+	return makeB3TraceContext(traceID, spanID, sampled)
+}
+
+func makeB3TraceContext(traceID, spanID, sampled string) (TraceContext, error) {
+	var out TraceContext
+	if err := decodePaddedHex(out.Trace[:], traceID); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid B3 trace ID")
+	}
+	if err := out.Trace.Validate(); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid B3 trace ID")
+	}
+	if err := decodePaddedHex(out.Span[:], spanID); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid B3 span ID")
+	}
+	if err := out.Span.Validate(); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid B3 span ID")
+	}
+	switch sampled {
+	case "", "1", "d", "true":
+		out.Options = out.Options.WithRecorded(true)
+	case "0", "false":
+	default:
+		return TraceContext{}, errors.Errorf("invalid B3 sampling state %q", sampled)
+	}
+	return out, nil
+}
+
+// JaegerPropagator is a Propagator which propagates trace context with
+// the Jaeger "uber-trace-id" header. Jaeger baggage is not propagated.
+type JaegerPropagator struct{}
+
+// Inject injects traceContext into carrier.
+func (JaegerPropagator) Inject(traceContext TraceContext, carrier PropagationCarrier) {
+	// uber-trace-id: {trace-id}:{span-id}:{parent-span-id}:{flags}
+	//
+	// The parent span ID is deprecated, and must be set to 0.
+	var flags int
+	if traceContext.Options.Recorded() {
+		flags = 1
+	}
+	carrier.Set(jaegerHeader, fmt.Sprintf("%032x:%016x:0:%d", traceContext.Trace[:], traceContext.Span[:], flags))
+}
+
+// Extract extracts a trace context from carrier.
+func (JaegerPropagator) Extract(carrier PropagationCarrier) (TraceContext, error) {
+	value := firstValue(carrier, jaegerHeader)
+	if value == "" {
+		return TraceContext{}, ErrTraceContextNotFound
+	}
+	if unescaped, err := url.QueryUnescape(value); err == nil {
+		value = unescaped
+	}
+	fields := strings.Split(value, ":")
+	if len(fields) != 4 {
+		return TraceContext{}, errors.Errorf("invalid uber-trace-id header %q", value)
+	}
+	var out TraceContext
+	if err := decodePaddedHex(out.Trace[:], fields[0]); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid Jaeger trace ID")
+	}
+	if err := out.Trace.Validate(); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid Jaeger trace ID")
+	}
+	if err := decodePaddedHex(out.Span[:], fields[1]); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid Jaeger span ID")
+	}
+	if err := out.Span.Validate(); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid Jaeger span ID")
+	}
+	flags, err := strconv.ParseUint(fields[3], 16, 8)
+	if err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid Jaeger flags")
+	}
+	out.Options = out.Options.WithRecorded(flags&1 != 0)
+	return out, nil
+}
+
+// XRayPropagator is a Propagator which propagates trace context with
+// the AWS X-Ray "X-Amzn-Trace-Id" header.
+//
+// If the sampling decision is deferred, i.e. the "Sampled" field is
+// missing or "?", then the trace context is considered to be sampled.
+type XRayPropagator struct{}
+
+// Inject injects traceContext into carrier.
+func (XRayPropagator) Inject(traceContext TraceContext, carrier PropagationCarrier) {
+	// X-Amzn-Trace-Id: Root=1-{epoch}-{unique};Parent={span-id};Sampled={0|1}
+	//
+	// The X-Ray trace ID is made up of an 8 hex digit epoch, and a
+	// 24 hex digit unique identifier, which together form the 128-bit
+	// trace ID.
+	sampled := 0
+	if traceContext.Options.Recorded() {
+		sampled = 1
+	}
+	carrier.Set(xrayHeader, fmt.Sprintf(
+		"Root=1-%08x-%024x;Parent=%016x;Sampled=%d",
+		traceContext.Trace[:4], traceContext.Trace[4:], traceContext.Span[:], sampled,
+	))
+}
+
+// Extract extracts a trace context from carrier.
+func (XRayPropagator) Extract(carrier PropagationCarrier) (TraceContext, error) {
+	value := firstValue(carrier, xrayHeader)
+	if value == "" {
+		return TraceContext{}, ErrTraceContextNotFound
+	}
+	var root, parent, sampled string
+	for _, field := range strings.Split(value, ";") {
+		equal := strings.IndexRune(field, '=')
+		if equal == -1 {
+			continue
+		}
+		switch strings.TrimSpace(field[:equal]) {
+		case "Root":
+			root = strings.TrimSpace(field[equal+1:])
+		case "Parent":
+			parent = strings.TrimSpace(field[equal+1:])
+		case "Sampled":
+			sampled = strings.TrimSpace(field[equal+1:])
+		}
+	}
+	if root == "" || parent == "" {
+		// The trace ID may be present without a parent when a
+		// trace is started by an AWS service, such as a load
+		// balancer, which does not record a segment.
+		return TraceContext{}, ErrTraceContextNotFound
+	}
+
+	var out TraceContext
+	rootFields := strings.Split(root, "-")
+	if len(rootFields) != 3 || rootFields[0] != "1" || len(rootFields[1]) != 8 || len(rootFields[2]) != 24 {
+		return TraceContext{}, errors.Errorf("invalid X-Ray trace ID %q", root)
+	}
+	if _, err := hex.Decode(out.Trace[:], []byte(rootFields[1]+rootFields[2])); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid X-Ray trace ID")
+	}
+	if err := decodePaddedHex(out.Span[:], parent); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid X-Ray parent ID")
+	}
+	if err := out.Span.Validate(); err != nil {
+		return TraceContext{}, errors.Wrap(err, "invalid X-Ray parent ID")
+	}
+	switch sampled {
+	case "", "?", "1":
+		out.Options = out.Options.WithRecorded(true)
+	case "0":
+	default:
+		return TraceContext{}, errors.Errorf("invalid X-Ray sampling decision %q", sampled)
+	}
+	return out, nil
+}
+
+// firstValue returns the first value for key in carrier, or the empty
+// string if there is none.
+func firstValue(carrier PropagationCarrier, key string) string {
+	if values := carrier.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// decodePaddedHex decodes the hex-encoded string s into out, left-padding
+// with zeroes if s is shorter than the encoded length of out. This is used
+// for decoding IDs which may be shorter than their canonical length, e.g.
+// 64-bit B3 trace IDs.
+func decodePaddedHex(out []byte, s string) error {
+	if len(s) > 2*len(out) {
+		return errors.Errorf("%q is too long", s)
+	}
+	if len(s) < 2*len(out) {
+		s = strings.Repeat("0", 2*len(out)-len(s)) + s
+	}
+	_, err := hex.Decode(out, []byte(s))
+	return err
+}
+
+// newPropagator returns a Propagator for the given propagator names,
+// as specified in ELASTIC_APM_PROPAGATORS.
+func newPropagator(names []string, propagateLegacyHeader bool) (Propagator, error) {
+	var propagators CompositePropagator
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "tracecontext":
+			propagators = append(propagators, W3CTraceContextPropagator{PropagateLegacyHeader: propagateLegacyHeader})
+		case "b3":
+			propagators = append(propagators, B3Propagator{SingleHeader: true})
+		case "b3multi":
+			propagators = append(propagators, B3Propagator{})
+		case "jaeger":
+			propagators = append(propagators, JaegerPropagator{})
+		case "xray":
+			propagators = append(propagators, XRayPropagator{})
+		default:
+			return nil, errors.Errorf("unknown propagator %q", name)
+		}
+	}
+	if len(propagators) == 1 {
+		return propagators[0], nil
+	}
+	return propagators, nil
+}
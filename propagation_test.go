@@ -0,0 +1,232 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+)
+
+var testTraceContext = apm.TraceContext{
+	Trace:   apm.TraceID{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c},
+	Span:    apm.SpanID{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+	Options: apm.TraceOptions(0).WithRecorded(true),
+}
+
+func TestW3CTraceContextPropagator(t *testing.T) {
+	traceContext := testTraceContext
+	traceContext.State = apm.NewTraceState(apm.TraceStateEntry{Key: "vendor", Value: "value"})
+
+	header := make(http.Header)
+	apm.W3CTraceContextPropagator{}.Inject(traceContext, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{
+		"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		"Tracestate":  {"vendor=value"},
+	}, header)
+
+	extracted, err := apm.W3CTraceContextPropagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, traceContext, extracted)
+
+	header = make(http.Header)
+	apm.W3CTraceContextPropagator{PropagateLegacyHeader: true}.Inject(testTraceContext, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{
+		"Traceparent":             {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+		"Elastic-Apm-Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}, header)
+
+	// The legacy header takes precedence over the W3C header.
+	header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-0000000000000001-00")
+	extracted, err = apm.W3CTraceContextPropagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testTraceContext, extracted)
+
+	// An invalid legacy header is ignored in favour of a valid W3C header.
+	header.Set("Elastic-Apm-Traceparent", "invalid")
+	extracted, err = apm.W3CTraceContextPropagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, apm.SpanID{0, 0, 0, 0, 0, 0, 0, 1}, extracted.Span)
+
+	_, err = apm.W3CTraceContextPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{}))
+	assert.Equal(t, apm.ErrTraceContextNotFound, err)
+
+	_, err = apm.W3CTraceContextPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{
+		"Traceparent": {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}))
+	assert.EqualError(t, err, "multiple Traceparent headers")
+}
+
+func TestB3Propagator(t *testing.T) {
+	header := make(http.Header)
+	apm.B3Propagator{SingleHeader: true}.Inject(testTraceContext, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{
+		"B3": {"0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-1"},
+	}, header)
+	extracted, err := apm.B3Propagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testTraceContext, extracted)
+
+	header = make(http.Header)
+	apm.B3Propagator{}.Inject(testTraceContext, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{
+		"X-B3-Traceid": {"0af7651916cd43dd8448eb211c80319c"},
+		"X-B3-Spanid":  {"b7ad6b7169203331"},
+		"X-B3-Sampled": {"1"},
+	}, header)
+	extracted, err = apm.B3Propagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testTraceContext, extracted)
+
+	for _, test := range []struct {
+		header  http.Header
+		expect  apm.TraceContext
+		wantErr string
+	}{{
+		// 64-bit trace ID, unsampled, with parent span ID.
+		header: http.Header{"B3": {"8448eb211c80319c-b7ad6b7169203331-0-05e3ac9a4f6e3b90"}},
+		expect: apm.TraceContext{
+			Trace: apm.TraceID{8: 0x84, 9: 0x48, 10: 0xeb, 11: 0x21, 12: 0x1c, 13: 0x80, 14: 0x31, 15: 0x9c},
+			Span:  testTraceContext.Span,
+		},
+	}, {
+		// Deferred sampling decision.
+		header: http.Header{"B3": {"0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331"}},
+		expect: testTraceContext,
+	}, {
+		// Debug flag implies sampled.
+		header: http.Header{
+			"X-B3-Traceid": {"0af7651916cd43dd8448eb211c80319c"},
+			"X-B3-Spanid":  {"b7ad6b7169203331"},
+			"X-B3-Flags":   {"1"},
+		},
+		expect: testTraceContext,
+	}, {
+		header:  http.Header{"B3": {"0"}},
+		wantErr: apm.ErrTraceContextNotFound.Error(),
+	}, {
+		header:  http.Header{"B3": {"0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-x"}},
+		wantErr: `invalid B3 sampling state "x"`,
+	}, {
+		header:  http.Header{"X-B3-Traceid": {"zzz"}, "X-B3-Spanid": {"b7ad6b7169203331"}},
+		wantErr: "invalid B3 trace ID: encoding/hex: invalid byte: U+007A 'z'",
+	}} {
+		extracted, err := apm.B3Propagator{}.Extract(apm.HTTPHeaderCarrier(test.header))
+		if test.wantErr != "" {
+			assert.EqualError(t, err, test.wantErr)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, test.expect, extracted)
+	}
+}
+
+func TestJaegerPropagator(t *testing.T) {
+	header := make(http.Header)
+	apm.JaegerPropagator{}.Inject(testTraceContext, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{
+		"Uber-Trace-Id": {"0af7651916cd43dd8448eb211c80319c:b7ad6b7169203331:0:1"},
+	}, header)
+	extracted, err := apm.JaegerPropagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testTraceContext, extracted)
+
+	// IDs may be shorter than their canonical length,
+	// and the header value may be URL-encoded.
+	extracted, err = apm.JaegerPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{
+		"Uber-Trace-Id": {"abc%3Adef%3A0%3A2"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, apm.TraceContext{
+		Trace: apm.TraceID{14: 0x0a, 15: 0xbc},
+		Span:  apm.SpanID{6: 0x0d, 7: 0xef},
+	}, extracted)
+
+	_, err = apm.JaegerPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{
+		"Uber-Trace-Id": {"abc:def"},
+	}))
+	assert.EqualError(t, err, `invalid uber-trace-id header "abc:def"`)
+}
+
+func TestXRayPropagator(t *testing.T) {
+	header := make(http.Header)
+	apm.XRayPropagator{}.Inject(testTraceContext, apm.HTTPHeaderCarrier(header))
+	assert.Equal(t, http.Header{
+		"X-Amzn-Trace-Id": {"Root=1-0af76519-16cd43dd8448eb211c80319c;Parent=b7ad6b7169203331;Sampled=1"},
+	}, header)
+	extracted, err := apm.XRayPropagator{}.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testTraceContext, extracted)
+
+	extracted, err = apm.XRayPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{
+		"X-Amzn-Trace-Id": {"Root=1-0af76519-16cd43dd8448eb211c80319c; Parent=b7ad6b7169203331; Sampled=0; Lineage=a87bd80c:0"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, apm.TraceContext{Trace: testTraceContext.Trace, Span: testTraceContext.Span}, extracted)
+
+	// No parent, e.g. trace started by a load balancer.
+	_, err = apm.XRayPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{
+		"X-Amzn-Trace-Id": {"Root=1-0af76519-16cd43dd8448eb211c80319c"},
+	}))
+	assert.Equal(t, apm.ErrTraceContextNotFound, err)
+
+	_, err = apm.XRayPropagator{}.Extract(apm.HTTPHeaderCarrier(http.Header{
+		"X-Amzn-Trace-Id": {"Root=2-0af76519-16cd43dd8448eb211c80319c;Parent=b7ad6b7169203331"},
+	}))
+	assert.EqualError(t, err, `invalid X-Ray trace ID "2-0af76519-16cd43dd8448eb211c80319c"`)
+}
+
+func TestCompositePropagator(t *testing.T) {
+	propagator := apm.CompositePropagator{
+		apm.W3CTraceContextPropagator{},
+		apm.B3Propagator{},
+	}
+	header := make(http.Header)
+	propagator.Inject(testTraceContext, apm.HTTPHeaderCarrier(header))
+	assert.Contains(t, header, "Traceparent")
+	assert.Contains(t, header, "X-B3-Traceid")
+
+	// Extract uses the first propagator which finds a valid trace context.
+	header.Set("Traceparent", "invalid")
+	extracted, err := propagator.Extract(apm.HTTPHeaderCarrier(header))
+	require.NoError(t, err)
+	assert.Equal(t, testTraceContext, extracted)
+
+	header.Del("X-B3-Traceid")
+	header.Del("X-B3-Spanid")
+	_, err = propagator.Extract(apm.HTTPHeaderCarrier(header))
+	assert.EqualError(t, err, `invalid traceparent header "invalid"`)
+
+	_, err = propagator.Extract(apm.HTTPHeaderCarrier(http.Header{}))
+	assert.Equal(t, apm.ErrTraceContextNotFound, err)
+}
+
+func TestTracerPropagator(t *testing.T) {
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{Propagator: apm.JaegerPropagator{}})
+	require.NoError(t, err)
+	defer tracer.Close()
+	assert.Equal(t, apm.JaegerPropagator{}, tracer.Propagator())
+
+	tx := tracer.StartTransaction("name", "type")
+	defer tx.End()
+	assert.Equal(t, apm.JaegerPropagator{}, tx.Propagator())
+}
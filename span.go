@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	"go.elastic.co/apm/internal/wildcard"
 	"go.elastic.co/apm/stacktrace"
 )
 
@@ -112,6 +113,7 @@ func (tx *Transaction) StartSpanOptions(name, spanType string, opts SpanOptions)
 		}
 		span.stackFramesMinDuration = tx.spanFramesMinDuration
 		span.stackTraceLimit = tx.stackTraceLimit
+		span.baggageToAttach = tx.baggageToAttach
 		tx.spansCreated++
 	}
 
@@ -162,6 +164,7 @@ func (t *Tracer) StartSpan(name, spanType string, transactionID SpanID, opts Spa
 	instrumentationConfig := t.instrumentationConfig()
 	span.stackFramesMinDuration = instrumentationConfig.spanFramesMinDuration
 	span.stackTraceLimit = instrumentationConfig.stackTraceLimit
+	span.baggageToAttach = instrumentationConfig.baggageToAttach
 
 	return span
 }
@@ -370,6 +373,7 @@ type SpanData struct {
 	parentID               SpanID
 	stackFramesMinDuration time.Duration
 	stackTraceLimit        int
+	baggageToAttach        wildcard.Matchers
 	timestamp              time.Time
 	childrenTimer          childrenTimer
 
@@ -388,6 +392,10 @@ type SpanData struct {
 	// and can be set after starting the span.
 	Action string
 
+	// Outcome holds the span outcome: "success", "failure", or "unknown".
+	// This will initially be empty, and can be set before ending the span.
+	Outcome string
+
 	// Duration holds the span duration, initialized to -1.
 	//
 	// If you do not update Duration, calling Span.End will calculate the
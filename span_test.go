@@ -137,6 +137,19 @@ func TestSpanType(t *testing.T) {
 	check(spans[3], "type", "subtype", "action.figure")
 }
 
+func TestSpanOutcome(t *testing.T) {
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		span1, _ := apm.StartSpan(ctx, "name", "type")
+		span1.End()
+		span2, _ := apm.StartSpan(ctx, "name", "type")
+		span2.Outcome = "failure"
+		span2.End()
+	})
+	require.Len(t, spans, 2)
+	assert.Equal(t, "", spans[0].Outcome)
+	assert.Equal(t, "failure", spans[1].Outcome)
+}
+
 func TestTracerStartSpanIDSpecified(t *testing.T) {
 	spanID := apm.SpanID{0, 1, 2, 3, 4, 5, 6, 7}
 	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"go.elastic.co/apm/internal/apmhttputil"
 	"go.elastic.co/apm/model"
@@ -35,6 +36,9 @@ type SpanContext struct {
 	databaseRowsAffected int64
 	database             model.DatabaseSpanContext
 	http                 model.HTTPSpanContext
+	message              model.MessageContext
+	messageQueue         model.MessageQueueContext
+	messageAge           model.MessageAgeContext
 }
 
 // DatabaseSpanContext holds database span context.
@@ -64,12 +68,39 @@ type DestinationServiceSpanContext struct {
 	Resource string
 }
 
+// MessageContext holds the context of a message sent or received
+// through a messaging system.
+type MessageContext struct {
+	// QueueName holds the name of the message queue or topic.
+	QueueName string
+
+	// Age holds the time elapsed between the message being produced
+	// and received. Age is recorded only if it is greater than zero.
+	Age time.Duration
+}
+
+// build fills in out, queue, and age from m, linking queue and age
+// from out if they are set, and reports whether out has any content.
+func (m MessageContext) build(out *model.MessageContext, queue *model.MessageQueueContext, age *model.MessageAgeContext) bool {
+	*out = model.MessageContext{}
+	if m.QueueName != "" {
+		queue.Name = truncateString(m.QueueName)
+		out.Queue = queue
+	}
+	if m.Age > 0 {
+		age.Milliseconds = int64(m.Age / time.Millisecond)
+		out.Age = age
+	}
+	return out.Queue != nil || out.Age != nil
+}
+
 func (c *SpanContext) build() *model.SpanContext {
 	switch {
 	case len(c.model.Tags) != 0:
 	case c.model.Database != nil:
 	case c.model.HTTP != nil:
 	case c.model.Destination != nil:
+	case c.model.Message != nil:
 	default:
 		return nil
 	}
@@ -191,3 +222,13 @@ func (c *SpanContext) SetDestinationService(service DestinationServiceSpanContex
 	c.destination.Service = &c.destinationService
 	c.model.Destination = &c.destination
 }
+
+// SetMessage sets the message context, e.g. the name of the queue
+// or topic to which a message is sent.
+func (c *SpanContext) SetMessage(m MessageContext) {
+	if m.build(&c.message, &c.messageQueue, &c.messageAge) {
+		c.model.Message = &c.message
+	} else {
+		c.model.Message = nil
+	}
+}
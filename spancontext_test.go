@@ -50,6 +50,19 @@ func TestSpanContextSetLabel(t *testing.T) {
 	}, spans[0].Context.Tags)
 }
 
+func TestSpanContextSetMessage(t *testing.T) {
+	_, spans, _ := apmtest.WithTransaction(func(ctx context.Context) {
+		span, _ := apm.StartSpan(ctx, "name", "type")
+		span.Context.SetMessage(apm.MessageContext{QueueName: "orders"})
+		span.End()
+	})
+	require.Len(t, spans, 1)
+	require.NotNil(t, spans[0].Context)
+	assert.Equal(t, &model.MessageContext{
+		Queue: &model.MessageQueueContext{Name: "orders"},
+	}, spans[0].Context.Message)
+}
+
 func TestSpanContextSetHTTPRequest(t *testing.T) {
 	type testcase struct {
 		url string
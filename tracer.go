@@ -94,6 +94,14 @@ type TracerOptions struct {
 	// the environment variable ELASTIC_APM_CENTRAL_CONFIG=false.
 	Transport transport.Transport
 
+	// Propagator holds the Propagator to use for propagating trace context
+	// in instrumentation modules.
+	//
+	// If Propagator is nil, the propagators will be defined using the
+	// ELASTIC_APM_PROPAGATORS environment variable, or if that is not set,
+	// W3C Trace-Context propagation will be used.
+	Propagator Propagator
+
 	requestDuration       time.Duration
 	metricsInterval       time.Duration
 	maxSpans              int
@@ -103,6 +111,8 @@ type TracerOptions struct {
 	sampler               Sampler
 	sanitizedFieldNames   wildcard.Matchers
 	disabledMetrics       wildcard.Matchers
+	metricsLabelSetsLimit int
+	metricLabelSetsLimit  int
 	captureHeaders        bool
 	captureBody           CaptureBodyMode
 	spanFramesMinDuration time.Duration
@@ -112,10 +122,15 @@ type TracerOptions struct {
 	configWatcher         apmconfig.Watcher
 	breakdownMetrics      bool
 	propagateLegacyHeader bool
+	baggageToAttach       wildcard.Matchers
 	profileSender         profileSender
 	cpuProfileInterval    time.Duration
 	cpuProfileDuration    time.Duration
 	heapProfileInterval   time.Duration
+	profiling             profilingConfig
+	mutexProfileFraction  int
+	blockProfileRate      int
+	profileDir            *profileDir
 }
 
 // initDefaults updates opts with default values.
@@ -203,6 +218,12 @@ func (opts *TracerOptions) initDefaults(continueOnError bool) error {
 		centralConfigEnabled = true
 	}
 
+	metricsLabelSetsLimit, metricLabelSetsLimit, err := initialMetricsLabelSetsLimits()
+	if failed(err) {
+		metricsLabelSetsLimit = defaultMetricsLabelSetsLimit
+		metricLabelSetsLimit = defaultMetricLabelSetsLimit
+	}
+
 	breakdownMetricsEnabled, err := initialBreakdownMetricsEnabled()
 	if failed(err) {
 		breakdownMetricsEnabled = true
@@ -213,6 +234,11 @@ func (opts *TracerOptions) initDefaults(continueOnError bool) error {
 		propagateLegacyHeader = true
 	}
 
+	propagator, err := initialPropagator(propagateLegacyHeader)
+	if failed(err) {
+		propagator = W3CTraceContextPropagator{PropagateLegacyHeader: propagateLegacyHeader}
+	}
+
 	cpuProfileInterval, cpuProfileDuration, err := initialCPUProfileIntervalDuration()
 	if failed(err) {
 		cpuProfileInterval = 0
@@ -222,6 +248,33 @@ func (opts *TracerOptions) initDefaults(continueOnError bool) error {
 	if failed(err) {
 		heapProfileInterval = 0
 	}
+	var profiling profilingConfig
+	profiling.goroutineProfileInterval, err = initialGoroutineProfileInterval()
+	if failed(err) {
+		profiling.goroutineProfileInterval = 0
+	}
+	profiling.mutexProfileInterval, profiling.mutexProfileDuration, err = initialMutexProfileIntervalDuration()
+	if failed(err) {
+		profiling.mutexProfileInterval = 0
+		profiling.mutexProfileDuration = 0
+	}
+	profiling.blockProfileInterval, profiling.blockProfileDuration, err = initialBlockProfileIntervalDuration()
+	if failed(err) {
+		profiling.blockProfileInterval = 0
+		profiling.blockProfileDuration = 0
+	}
+	mutexProfileFraction, err := initialMutexProfileFraction()
+	if failed(err) {
+		mutexProfileFraction = defaultMutexProfileFraction
+	}
+	blockProfileRate, err := initialBlockProfileRate()
+	if failed(err) {
+		blockProfileRate = defaultBlockProfileRate
+	}
+	profileDir, err := initialProfileDir()
+	if failed(err) {
+		profileDir = nil
+	}
 
 	if opts.ServiceName != "" {
 		err := validateServiceName(opts.ServiceName)
@@ -246,6 +299,8 @@ func (opts *TracerOptions) initDefaults(continueOnError bool) error {
 	opts.sampler = sampler
 	opts.sanitizedFieldNames = initialSanitizedFieldNames()
 	opts.disabledMetrics = initialDisabledMetrics()
+	opts.metricsLabelSetsLimit = metricsLabelSetsLimit
+	opts.metricLabelSetsLimit = metricLabelSetsLimit
 	opts.breakdownMetrics = breakdownMetricsEnabled
 	opts.captureHeaders = captureHeaders
 	opts.captureBody = captureBody
@@ -254,6 +309,10 @@ func (opts *TracerOptions) initDefaults(continueOnError bool) error {
 	opts.active = active
 	opts.recording = recording
 	opts.propagateLegacyHeader = propagateLegacyHeader
+	opts.baggageToAttach = initialBaggageToAttach()
+	if opts.Propagator == nil {
+		opts.Propagator = propagator
+	}
 	if opts.Transport == nil {
 		opts.Transport = transport.Default
 	}
@@ -264,9 +323,15 @@ func (opts *TracerOptions) initDefaults(continueOnError bool) error {
 	}
 	if ps, ok := opts.Transport.(profileSender); ok {
 		opts.profileSender = ps
+	}
+	opts.profileDir = profileDir
+	if opts.profileSender != nil || opts.profileDir != nil {
 		opts.cpuProfileInterval = cpuProfileInterval
 		opts.cpuProfileDuration = cpuProfileDuration
 		opts.heapProfileInterval = heapProfileInterval
+		opts.profiling = profiling
+		opts.mutexProfileFraction = mutexProfileFraction
+		opts.blockProfileRate = blockProfileRate
 	}
 
 	serviceName, serviceVersion, serviceEnvironment := initialService()
@@ -312,18 +377,31 @@ type Tracer struct {
 	process *model.Process
 	system  *model.System
 
-	active            int32
-	bufferSize        int
-	metricsBufferSize int
-	closing           chan struct{}
-	closed            chan struct{}
-	forceFlush        chan chan<- struct{}
-	forceSendMetrics  chan chan<- struct{}
-	configCommands    chan tracerConfigCommand
-	configWatcher     chan apmconfig.Watcher
-	events            chan tracerEvent
-	breakdownMetrics  *breakdownMetrics
-	profileSender     profileSender
+	active             int32
+	bufferSize         int
+	metricsBufferSize  int
+	closing            chan struct{}
+	closed             chan struct{}
+	forceFlush         chan chan<- struct{}
+	forceSendMetrics   chan chan<- struct{}
+	configCommands     chan tracerConfigCommand
+	configWatcher      chan apmconfig.Watcher
+	events             chan tracerEvent
+	breakdownMetrics   *breakdownMetrics
+	transactionMetrics *transactionMetrics
+	customMetrics      customMetrics
+	profileSender      profileSender
+	profileDir         *profileDir
+	propagator         Propagator
+
+	// mutexProfileFraction and blockProfileRate hold the sampling
+	// rates set while capturing mutex and block profiles.
+	mutexProfileFraction int
+	blockProfileRate     int
+
+	// cpuProfiling is set to 1 while a CPU profile is being
+	// captured, and is accessed atomically.
+	cpuProfiling int32
 
 	statsMu sync.Mutex
 	stats   TracerStats
@@ -373,21 +451,27 @@ func NewTracerOptions(opts TracerOptions) (*Tracer, error) {
 
 func newTracer(opts TracerOptions) *Tracer {
 	t := &Tracer{
-		Transport:         opts.Transport,
-		process:           &currentProcess,
-		system:            &localSystem,
-		closing:           make(chan struct{}),
-		closed:            make(chan struct{}),
-		forceFlush:        make(chan chan<- struct{}),
-		forceSendMetrics:  make(chan chan<- struct{}),
-		configCommands:    make(chan tracerConfigCommand),
-		configWatcher:     make(chan apmconfig.Watcher),
-		events:            make(chan tracerEvent, tracerEventChannelCap),
-		active:            1,
-		breakdownMetrics:  newBreakdownMetrics(),
-		bufferSize:        opts.bufferSize,
-		metricsBufferSize: opts.metricsBufferSize,
-		profileSender:     opts.profileSender,
+		Transport:          opts.Transport,
+		process:            &currentProcess,
+		system:             &localSystem,
+		closing:            make(chan struct{}),
+		closed:             make(chan struct{}),
+		forceFlush:         make(chan chan<- struct{}),
+		forceSendMetrics:   make(chan chan<- struct{}),
+		configCommands:     make(chan tracerConfigCommand),
+		configWatcher:      make(chan apmconfig.Watcher),
+		events:             make(chan tracerEvent, tracerEventChannelCap),
+		active:             1,
+		breakdownMetrics:   newBreakdownMetrics(),
+		transactionMetrics: newTransactionMetrics(),
+		bufferSize:         opts.bufferSize,
+		metricsBufferSize:  opts.metricsBufferSize,
+		profileSender:      opts.profileSender,
+		profileDir:         opts.profileDir,
+		propagator:         opts.Propagator,
+
+		mutexProfileFraction: opts.mutexProfileFraction,
+		blockProfileRate:     opts.blockProfileRate,
 		instrumentationConfigInternal: &instrumentationConfig{
 			local: make(map[string]func(*instrumentationConfigValues)),
 		},
@@ -423,6 +507,28 @@ func newTracer(opts TracerOptions) *Tracer {
 	t.setLocalInstrumentationConfig(envUseElasticTraceparentHeader, func(cfg *instrumentationConfigValues) {
 		cfg.propagateLegacyHeader = opts.propagateLegacyHeader
 	})
+	t.setLocalInstrumentationConfig(envBaggageToAttach, func(cfg *instrumentationConfigValues) {
+		cfg.baggageToAttach = opts.baggageToAttach
+	})
+	if logger, ok := apmlog.DefaultLogger.(apmlog.LevelLogger); ok {
+		// Record the default logger's initial level, so that
+		// it may be restored if the level is changed centrally.
+		logLevel := logger.Level().String()
+		t.setLocalInstrumentationConfig(envLogLevel, func(cfg *instrumentationConfigValues) {
+			cfg.logLevel = logLevel
+		})
+	}
+	for _, envKey := range []string{
+		envGoroutineProfileInterval,
+		envMutexProfileInterval, envMutexProfileDuration,
+		envBlockProfileInterval, envBlockProfileDuration,
+	} {
+		local := *opts.profiling.durationField(envKey)
+		field := envKey
+		t.setLocalInstrumentationConfig(envKey, func(cfg *instrumentationConfigValues) {
+			*cfg.profiling.durationField(field) = local
+		})
+	}
 
 	if !opts.active {
 		t.active = 0
@@ -436,14 +542,17 @@ func newTracer(opts TracerOptions) *Tracer {
 		cfg.cpuProfileInterval = opts.cpuProfileInterval
 		cfg.cpuProfileDuration = opts.cpuProfileDuration
 		cfg.heapProfileInterval = opts.heapProfileInterval
+		cfg.profiling = t.instrumentationConfig().profiling
 		cfg.metricsInterval = opts.metricsInterval
 		cfg.requestDuration = opts.requestDuration
 		cfg.requestSize = opts.requestSize
 		cfg.sanitizedFieldNames = opts.sanitizedFieldNames
 		cfg.disabledMetrics = opts.disabledMetrics
+		cfg.metricsLabelSetsLimit = opts.metricsLabelSetsLimit
+		cfg.metricLabelSetsLimit = opts.metricLabelSetsLimit
 		cfg.preContext = defaultPreContext
 		cfg.postContext = defaultPostContext
-		cfg.metricsGatherers = []MetricsGatherer{newBuiltinMetricsGatherer(t)}
+		cfg.metricsGatherers = []MetricsGatherer{newBuiltinMetricsGatherer(t), &t.customMetrics}
 		if apmlog.DefaultLogger != nil {
 			cfg.logger = apmlog.DefaultLogger
 		}
@@ -467,9 +576,12 @@ type tracerConfig struct {
 	preContext, postContext int
 	sanitizedFieldNames     wildcard.Matchers
 	disabledMetrics         wildcard.Matchers
+	metricsLabelSetsLimit   int
+	metricLabelSetsLimit    int
 	cpuProfileDuration      time.Duration
 	cpuProfileInterval      time.Duration
 	heapProfileInterval     time.Duration
+	profiling               profilingConfig
 }
 
 type tracerConfigCommand func(*tracerConfig)
@@ -526,6 +638,13 @@ func (t *Tracer) ShouldPropagateLegacyHeader() bool {
 	return t.instrumentationConfig().propagateLegacyHeader
 }
 
+// Propagator returns the Propagator that instrumentation should use
+// for propagating trace context, as configured with TracerOptions.Propagator
+// or the ELASTIC_APM_PROPAGATORS environment variable.
+func (t *Tracer) Propagator() Propagator {
+	return t.propagator
+}
+
 // SetRequestDuration sets the maximum amount of time to keep a request open
 // to the APM server for streaming data before closing the stream and starting
 // a new request.
@@ -567,6 +686,48 @@ func (t *Tracer) SetLogger(logger Logger) {
 	})
 }
 
+// SetLogLevel sets the minimum level of messages logged by the tracer,
+// which must be one of "debug", "info", "warn", "error", or "off".
+//
+// SetLogLevel only has an effect if the tracer's logger supports changing
+// levels, as the logger configured with ELASTIC_APM_LOG_FILE does. As that
+// logger is shared by all tracers in the process, changing its level will
+// affect them all.
+//
+// Configuration via Kibana takes precedence over local configuration, so
+// if the log level has been configured via Kibana, this call will not have
+// any effect until/unless that configuration has been removed.
+func (t *Tracer) SetLogLevel(level string) error {
+	logLevel, err := apmlog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	t.setLocalInstrumentationConfig(envLogLevel, func(cfg *instrumentationConfigValues) {
+		cfg.logLevel = logLevel.String()
+	})
+	t.sendConfigCommand(func(cfg *tracerConfig) {
+		// Consult t.instrumentationConfig() as local config may not be in effect,
+		// or there may have been a concurrent change to instrumentation config.
+		setLoggerLevel(cfg.logger, t.instrumentationConfig().logLevel)
+	})
+	return nil
+}
+
+// setLoggerLevel sets the level of logger to level, if logger
+// supports changing levels and level is non-empty.
+func setLoggerLevel(logger WarningLogger, level string) {
+	if level == "" {
+		return
+	}
+	levelLogger, ok := logger.(apmlog.LevelLogger)
+	if !ok {
+		return
+	}
+	if logLevel, err := apmlog.ParseLevel(level); err == nil {
+		levelLogger.SetLevel(logLevel)
+	}
+}
+
 // SetSanitizedFieldNames sets the wildcard patterns that will be used to
 // match cookie and form field names for sanitization. Fields matching any
 // of the the supplied patterns will have their values redacted. If
@@ -696,6 +857,24 @@ func (t *Tracer) SetStackTraceLimit(limit int) {
 	})
 }
 
+// SetBaggageToAttach sets the wildcard patterns for baggage keys which
+// should be recorded as labels on transactions and spans. Matching baggage
+// members are recorded with the label key "baggage.<key>".
+//
+// By default, no baggage members are recorded as labels.
+func (t *Tracer) SetBaggageToAttach(patterns ...string) {
+	var matchers wildcard.Matchers
+	if len(patterns) != 0 {
+		matchers = make(wildcard.Matchers, len(patterns))
+		for i, p := range patterns {
+			matchers[i] = configutil.ParseWildcardPattern(p)
+		}
+	}
+	t.setLocalInstrumentationConfig(envBaggageToAttach, func(cfg *instrumentationConfigValues) {
+		cfg.baggageToAttach = matchers
+	})
+}
+
 // SetCaptureHeaders enables or disables capturing of HTTP headers.
 func (t *Tracer) SetCaptureHeaders(capture bool) {
 	t.setLocalInstrumentationConfig(envMaxSpans, func(cfg *instrumentationConfigValues) {
@@ -746,7 +925,7 @@ func (t *Tracer) loop() {
 	var metadata []byte
 	var gracePeriod time.Duration = -1
 	var flushed chan<- struct{}
-	var requestBufTransactions, requestBufSpans, requestBufErrors, requestBufMetricsets uint64
+	var requestBufTransactions, requestBufSpans, requestBufErrors, requestBufLogs, requestBufMetricsets uint64
 	zlibWriter, _ := zlib.NewWriterLevel(&requestBuf, zlib.BestSpeed)
 	zlibFlushed := true
 	zlibClosed := false
@@ -779,7 +958,7 @@ func (t *Tracer) loop() {
 		}
 	}()
 
-	var breakdownMetricsLimitWarningLogged bool
+	var metricsLimitWarningsLogged metricsLimitWarnings
 	var stats TracerStats
 	var metrics Metrics
 	var sentMetrics chan<- struct{}
@@ -803,6 +982,16 @@ func (t *Tracer) loop() {
 
 	cpuProfilingState := newCPUProfilingState(t.profileSender)
 	heapProfilingState := newHeapProfilingState(t.profileSender)
+	goroutineProfilingState := newGoroutineProfilingState(t.profileSender)
+	mutexProfilingState := newMutexProfilingState(t.profileSender, t.mutexProfileFraction)
+	blockProfilingState := newBlockProfilingState(t.profileSender, t.blockProfileRate)
+	profilingStates := []*profilingState{
+		cpuProfilingState, heapProfilingState, goroutineProfilingState,
+		mutexProfilingState, blockProfilingState,
+	}
+	for _, state := range profilingStates {
+		state.dir = t.profileDir
+	}
 
 	var cfg tracerConfig
 	buffer := ringbuffer.New(t.bufferSize)
@@ -814,6 +1003,8 @@ func (t *Tracer) loop() {
 			stats.SpansDropped++
 		case transactionBlockTag:
 			stats.TransactionsDropped++
+		case logBlockTag:
+			stats.LogsDropped++
 		}
 	}
 	modelWriter := modelWriter{
@@ -830,15 +1021,20 @@ func (t *Tracer) loop() {
 		}
 		cmd(&cfg)
 		var metricsInterval, cpuProfileInterval, cpuProfileDuration, heapProfileInterval time.Duration
+		var profiling profilingConfig
 		if cfg.recording {
 			metricsInterval = cfg.metricsInterval
 			cpuProfileInterval = cfg.cpuProfileInterval
 			cpuProfileDuration = cfg.cpuProfileDuration
 			heapProfileInterval = cfg.heapProfileInterval
+			profiling = cfg.profiling
 		}
 
 		cpuProfilingState.updateConfig(cpuProfileInterval, cpuProfileDuration)
 		heapProfilingState.updateConfig(heapProfileInterval, 0)
+		goroutineProfilingState.updateConfig(profiling.goroutineProfileInterval, 0)
+		mutexProfilingState.updateConfig(profiling.mutexProfileInterval, profiling.mutexProfileDuration)
+		blockProfilingState.updateConfig(profiling.blockProfileInterval, profiling.blockProfileDuration)
 		if !gatheringMetrics && metricsInterval != oldMetricsInterval {
 			if metricsTimerStart.IsZero() {
 				if metricsInterval > 0 {
@@ -869,6 +1065,12 @@ func (t *Tracer) loop() {
 		case <-t.closing:
 			cancelContext() // informs transport that EOF is expected
 			iochanReader.CloseRead(io.EOF)
+			// Wait for in-progress profiles to stop, so that
+			// the profiling rates are restored by the time
+			// Close returns.
+			for _, state := range profilingStates {
+				state.wait()
+			}
 			return
 		case cmd := <-t.configCommands:
 			handleTracerConfigCommand(cmd)
@@ -909,19 +1111,17 @@ func (t *Tracer) loop() {
 				t.updateRemoteConfig(cfg.logger, lastConfigChange, change.Attrs)
 				lastConfigChange = change.Attrs
 				handleTracerConfigCommand(func(cfg *tracerConfig) {
-					cfg.recording = t.instrumentationConfig().recording
+					instrumentationConfig := t.instrumentationConfig()
+					cfg.recording = instrumentationConfig.recording
+					cfg.profiling = instrumentationConfig.profiling
+					setLoggerLevel(cfg.logger, instrumentationConfig.logLevel)
 				})
 			}
 			continue
 		case event := <-t.events:
 			switch event.eventType {
 			case transactionEvent:
-				if !t.breakdownMetrics.recordTransaction(event.tx.TransactionData) {
-					if !breakdownMetricsLimitWarningLogged && cfg.logger != nil {
-						cfg.logger.Warningf("%s", breakdownMetricsLimitWarning)
-						breakdownMetricsLimitWarningLogged = true
-					}
-				}
+				t.recordTransactionMetrics(event.tx.TransactionData, &cfg, &metricsLimitWarningsLogged)
 				modelWriter.writeTransaction(event.tx.Transaction, event.tx.TransactionData)
 			case spanEvent:
 				modelWriter.writeSpan(event.span.Span, event.span.SpanData)
@@ -929,6 +1129,8 @@ func (t *Tracer) loop() {
 				modelWriter.writeError(event.err)
 				// Flush the buffer to transmit the error immediately.
 				flushRequest = true
+			case logEvent:
+				modelWriter.writeLog(event.log)
 			}
 		case <-requestTimer.C:
 			requestTimerActive = false
@@ -947,6 +1149,13 @@ func (t *Tracer) loop() {
 				gatherMetrics = !gatheringMetrics
 			}
 		case <-gatheredMetrics:
+			if metrics.overflowed > 0 {
+				stats.MetricsOverflowed += metrics.overflowed
+				if !metricsLimitWarningsLogged.labelSets && cfg.logger != nil {
+					cfg.logger.Warningf("%s", metricsLabelSetsLimitWarning)
+					metricsLimitWarningsLogged.labelSets = true
+				}
+			}
 			modelWriter.writeMetrics(&metrics)
 			gatheringMetrics = false
 			flushRequest = true
@@ -955,30 +1164,41 @@ func (t *Tracer) loop() {
 				metricsTimer.Reset(cfg.metricsInterval)
 			}
 		case <-cpuProfilingState.timer.C:
+			t.setCPUProfilingActive(true)
 			cpuProfilingState.start(ctx, cfg.logger, t.metadataReader())
 		case <-cpuProfilingState.finished:
+			t.setCPUProfilingActive(false)
 			cpuProfilingState.resetTimer()
 		case <-heapProfilingState.timer.C:
 			heapProfilingState.start(ctx, cfg.logger, t.metadataReader())
 		case <-heapProfilingState.finished:
 			heapProfilingState.resetTimer()
+		case <-goroutineProfilingState.timer.C:
+			goroutineProfilingState.start(ctx, cfg.logger, t.metadataReader())
+		case <-goroutineProfilingState.finished:
+			goroutineProfilingState.resetTimer()
+		case <-mutexProfilingState.timer.C:
+			mutexProfilingState.start(ctx, cfg.logger, t.metadataReader())
+		case <-mutexProfilingState.finished:
+			mutexProfilingState.resetTimer()
+		case <-blockProfilingState.timer.C:
+			blockProfilingState.start(ctx, cfg.logger, t.metadataReader())
+		case <-blockProfilingState.finished:
+			blockProfilingState.resetTimer()
 		case flushed = <-t.forceFlush:
 			// Drain any objects buffered in the channels.
 			for n := len(t.events); n > 0; n-- {
 				event := <-t.events
 				switch event.eventType {
 				case transactionEvent:
-					if !t.breakdownMetrics.recordTransaction(event.tx.TransactionData) {
-						if !breakdownMetricsLimitWarningLogged && cfg.logger != nil {
-							cfg.logger.Warningf("%s", breakdownMetricsLimitWarning)
-							breakdownMetricsLimitWarningLogged = true
-						}
-					}
+					t.recordTransactionMetrics(event.tx.TransactionData, &cfg, &metricsLimitWarningsLogged)
 					modelWriter.writeTransaction(event.tx.Transaction, event.tx.TransactionData)
 				case spanEvent:
 					modelWriter.writeSpan(event.span.Span, event.span.SpanData)
 				case errorEvent:
 					modelWriter.writeError(event.err)
+				case logEvent:
+					modelWriter.writeLog(event.log)
 				}
 			}
 			if !requestActive && buffer.Len() == 0 && metricsBuffer.Len() == 0 {
@@ -1005,6 +1225,7 @@ func (t *Tracer) loop() {
 				stats.TransactionsSent += requestBufTransactions
 				stats.SpansSent += requestBufSpans
 				stats.ErrorsSent += requestBufErrors
+				stats.LogsSent += requestBufLogs
 				if cfg.logger != nil {
 					s := func(n uint64) string {
 						if n != 1 {
@@ -1013,10 +1234,11 @@ func (t *Tracer) loop() {
 						return ""
 					}
 					cfg.logger.Debugf(
-						"sent request with %d transaction%s, %d span%s, %d error%s, %d metricset%s",
+						"sent request with %d transaction%s, %d span%s, %d error%s, %d log%s, %d metricset%s",
 						requestBufTransactions, s(requestBufTransactions),
 						requestBufSpans, s(requestBufSpans),
 						requestBufErrors, s(requestBufErrors),
+						requestBufLogs, s(requestBufLogs),
 						requestBufMetricsets, s(requestBufMetricsets),
 					)
 				}
@@ -1049,6 +1271,7 @@ func (t *Tracer) loop() {
 			requestBufTransactions = 0
 			requestBufSpans = 0
 			requestBufErrors = 0
+			requestBufLogs = 0
 			requestBufMetricsets = 0
 			if requestTimerActive {
 				if !requestTimer.Stop() {
@@ -1068,6 +1291,8 @@ func (t *Tracer) loop() {
 		if gatherMetrics {
 			gatheringMetrics = true
 			metrics.disabled = cfg.disabledMetrics
+			metrics.labelSetsLimit = cfg.metricsLabelSetsLimit
+			metrics.metricLabelSetsLimit = cfg.metricLabelSetsLimit
 			t.gatherMetrics(ctx, cfg.metricsGatherers, &metrics, cfg.logger, gatheredMetrics)
 			if cfg.logger != nil {
 				cfg.logger.Debugf("gathering metrics")
@@ -1118,6 +1343,8 @@ func (t *Tracer) loop() {
 						requestBufSpans++
 					case errorBlockTag:
 						requestBufErrors++
+					case logBlockTag:
+						requestBufLogs++
 					}
 					zlibWriter.Write([]byte("\n"))
 					zlibFlushed = false
@@ -1156,6 +1383,31 @@ func (t *Tracer) loop() {
 	}
 }
 
+// metricsLimitWarnings records which metrics limit warnings have been
+// logged, so each is logged at most once.
+type metricsLimitWarnings struct {
+	breakdown   bool
+	transaction bool
+	labelSets   bool
+}
+
+// recordTransactionMetrics records breakdown and transaction metrics for td,
+// logging a warning the first time either of the metrics limits is reached.
+func (t *Tracer) recordTransactionMetrics(td *TransactionData, cfg *tracerConfig, logged *metricsLimitWarnings) {
+	if !t.breakdownMetrics.recordTransaction(td) {
+		if !logged.breakdown && cfg.logger != nil {
+			cfg.logger.Warningf("%s", breakdownMetricsLimitWarning)
+			logged.breakdown = true
+		}
+	}
+	if !t.transactionMetrics.recordTransaction(td) {
+		if !logged.transaction && cfg.logger != nil {
+			cfg.logger.Warningf("%s", transactionMetricsLimitWarning)
+			logged.transaction = true
+		}
+	}
+}
+
 // jsonRequestMetadata returns a JSON-encoded metadata object that features
 // at the head of every request body. This is called exactly once, when the
 // first request is made.
@@ -1221,6 +1473,7 @@ const (
 	transactionEvent tracerEventType = iota
 	spanEvent
 	errorEvent
+	logEvent
 )
 
 type tracerEvent struct {
@@ -1229,6 +1482,9 @@ type tracerEvent struct {
 	// err is set only if eventType == errorEvent.
 	err *ErrorData
 
+	// log is set only if eventType == logEvent.
+	log *model.LogEvent
+
 	// tx is set only if eventType == transactionEvent.
 	tx struct {
 		*Transaction
@@ -26,6 +26,13 @@ type TracerStats struct {
 	TransactionsDropped uint64
 	SpansSent           uint64
 	SpansDropped        uint64
+	LogsSent            uint64
+	LogsDropped         uint64
+
+	// MetricsOverflowed holds the number of metric samples which were
+	// folded into an overflow series, due to the metric label set
+	// limits being reached.
+	MetricsOverflowed uint64
 }
 
 // TracerStatsErrors holds error statistics for a Tracer.
@@ -49,4 +56,7 @@ func (s *TracerStats) accumulate(rhs TracerStats) {
 	s.SpansDropped += rhs.SpansDropped
 	s.TransactionsSent += rhs.TransactionsSent
 	s.TransactionsDropped += rhs.TransactionsDropped
+	s.LogsSent += rhs.LogsSent
+	s.LogsDropped += rhs.LogsDropped
+	s.MetricsOverflowed += rhs.MetricsOverflowed
 }
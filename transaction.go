@@ -23,6 +23,8 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"go.elastic.co/apm/internal/wildcard"
 )
 
 // StartTransaction returns a new Transaction with the specified
@@ -66,7 +68,9 @@ func (t *Tracer) StartTransactionOptions(name, transactionType string, opts Tran
 	tx.stackTraceLimit = instrumentationConfig.stackTraceLimit
 	tx.Context.captureHeaders = instrumentationConfig.captureHeaders
 	tx.propagateLegacyHeader = instrumentationConfig.propagateLegacyHeader
+	tx.baggageToAttach = instrumentationConfig.baggageToAttach
 	tx.breakdownMetricsEnabled = t.breakdownMetrics.enabled
+	tx.links = append(tx.links, opts.Links...)
 
 	var root bool
 	if opts.TraceContext.Trace.Validate() == nil {
@@ -155,6 +159,22 @@ type TransactionOptions struct {
 	// Start is the start time of the transaction. If this has the
 	// zero value, time.Now() will be used instead.
 	Start time.Time
+
+	// Links holds links to spans or transactions that are causally
+	// related to the new transaction, but are not its parent. For
+	// example, a transaction processing a batch of messages may be
+	// linked to the spans that produced each of the messages.
+	Links []SpanLink
+}
+
+// SpanLink identifies a span or transaction, possibly in another trace,
+// which is linked to a transaction.
+type SpanLink struct {
+	// Trace identifies the trace containing the linked span.
+	Trace TraceID
+
+	// Span identifies the linked span or transaction.
+	Span SpanID
 }
 
 // Transaction describes an event occurring in the monitored service.
@@ -203,6 +223,16 @@ func (tx *Transaction) ShouldPropagateLegacyHeader() bool {
 	return tx.propagateLegacyHeader
 }
 
+// Propagator returns the Propagator that instrumentation should use for
+// propagating the trace context of tx, or its spans, to other services.
+// See Tracer.Propagator.
+func (tx *Transaction) Propagator() Propagator {
+	if tx == nil || tx.tracer == nil {
+		return W3CTraceContextPropagator{}
+	}
+	return tx.tracer.Propagator()
+}
+
 // EnsureParent returns the span ID for for tx's parent, generating a
 // parent span ID if one has not already been set and tx has not been
 // ended. If tx is nil or has been ended, a zero (invalid) SpanID is
@@ -281,6 +311,7 @@ func (tx *Transaction) enqueue() {
 	default:
 		// Enqueuing a transaction should never block.
 		tx.tracer.breakdownMetrics.recordTransaction(tx.TransactionData)
+		tx.tracer.transactionMetrics.recordTransaction(tx.TransactionData)
 
 		// TODO(axw) use an atomic operation to increment.
 		tx.tracer.statsMu.Lock()
@@ -322,12 +353,25 @@ type TransactionData struct {
 	// Result holds the transaction result.
 	Result string
 
+	// Outcome holds the transaction outcome: "success", "failure",
+	// or "unknown".
+	//
+	// If Outcome is empty, the outcome recorded in transaction metrics
+	// will be derived from the HTTP response status code, if any.
+	Outcome string
+
+	// FaaS holds Function-as-a-Service details for the transaction,
+	// recorded when the transaction represents a function invocation.
+	FaaS FaaS
+
 	recording               bool
 	maxSpans                int
 	spanFramesMinDuration   time.Duration
 	stackTraceLimit         int
 	breakdownMetricsEnabled bool
 	propagateLegacyHeader   bool
+	baggageToAttach         wildcard.Matchers
+	links                   []SpanLink
 	timestamp               time.Time
 
 	mu            sync.Mutex
@@ -341,12 +385,46 @@ type TransactionData struct {
 	parentSpan SpanID
 }
 
+// outcome returns td.Outcome if it is non-empty, and otherwise derives
+// the outcome from the HTTP response status code recorded in td.Context.
+func (td *TransactionData) outcome() string {
+	if td.Outcome != "" {
+		return td.Outcome
+	}
+	switch statusCode := td.Context.response.StatusCode; {
+	case statusCode >= 500:
+		return "failure"
+	case statusCode > 0:
+		return "success"
+	}
+	return "unknown"
+}
+
+// FaaS holds details of a Function-as-a-Service invocation.
+type FaaS struct {
+	// Execution holds the request ID of the function invocation.
+	Execution string
+
+	// Coldstart indicates whether the invocation was the first one
+	// handled by the function instance.
+	Coldstart bool
+
+	// TriggerType holds the type of the trigger that caused the
+	// function to be invoked, e.g. "http", "pubsub" or "datasource".
+	TriggerType string
+
+	// TriggerRequestID holds the ID of the request or event that
+	// triggered the invocation, if any.
+	TriggerRequestID string
+}
+
 // reset resets the TransactionData back to its zero state and places it back
 // into the transaction pool.
 func (td *TransactionData) reset(tracer *Tracer) {
 	*td = TransactionData{
 		Context:     td.Context,
 		Duration:    -1,
+		links:       td.links[:0],
 		rand:        td.rand,
 		spanTimings: td.spanTimings,
 	}
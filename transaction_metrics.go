@@ -0,0 +1,277 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.elastic.co/apm/model"
+)
+
+const (
+	// transactionMetricsLimit is the maximum number of transaction
+	// metric groups to accumulate per reporting period. Metrics are
+	// grouped by {transactionType, transactionName, result, outcome}
+	// tuples.
+	transactionMetricsLimit = 1000
+
+	// transactionMetricsChunkSize is the number of transaction metric
+	// groups allocated at a time, up to transactionMetricsLimit.
+	transactionMetricsChunkSize = 100
+
+	// transactionDurationBuckets is the number of buckets in each
+	// transaction duration histogram, including the overflow bucket.
+	transactionDurationBuckets = 64
+
+	// Transaction metric names.
+	transactionDurationHistogramMetricName = "transaction.duration.histogram"
+	transactionCountMetricName             = "transaction.count"
+)
+
+var (
+	transactionMetricsLimitWarning = fmt.Sprintf(`
+The limit of %d transaction metricsets has been reached, no new metricsets will be created.
+Try to name your transactions so that there are less distinct transaction names.`[1:],
+		transactionMetricsLimit,
+	)
+
+	// transactionDurationBucketBounds holds the inclusive upper bounds
+	// of the transaction duration histogram buckets, in microseconds.
+	// The bounds grow by a factor of sqrt(2), starting at 1us. The
+	// final bucket has no upper bound.
+	transactionDurationBucketBounds [transactionDurationBuckets - 1]float64
+
+	// transactionDurationBucketValues holds the values reported for
+	// each transaction duration histogram bucket, in microseconds.
+	// We report the midpoint of each bucket, and the lower bound of
+	// the overflow bucket.
+	transactionDurationBucketValues [transactionDurationBuckets]float64
+)
+
+func init() {
+	var lower float64
+	for i := range transactionDurationBucketBounds {
+		upper := math.Pow(math.Sqrt2, float64(i))
+		transactionDurationBucketBounds[i] = upper
+		transactionDurationBucketValues[i] = lower + (upper-lower)/2
+		lower = upper
+	}
+	transactionDurationBucketValues[transactionDurationBuckets-1] = lower
+}
+
+// transactionMetrics holds a pair of transaction metrics maps, which
+// record transaction duration histograms for every ended transaction,
+// whether sampled or not.
+//
+// Like breakdownMetrics, the "active" map accumulates new metrics, and
+// is swapped with the "inactive" map just prior to when metrics gathering
+// begins.
+type transactionMetrics struct {
+	mu               sync.RWMutex
+	active, inactive *transactionMetricsMap
+}
+
+func newTransactionMetrics() *transactionMetrics {
+	return &transactionMetrics{
+		active:   newTransactionMetricsMap(),
+		inactive: newTransactionMetricsMap(),
+	}
+}
+
+type transactionMetricsMap struct {
+	mu      sync.RWMutex
+	entries int
+	m       map[uint64][]*transactionMetricsMapEntry
+
+	// chunks holds the entry storage. Chunks are allocated on
+	// demand and retained for reuse; they are never reallocated,
+	// so pointers to entries remain valid.
+	chunks [][]transactionMetricsMapEntry
+}
+
+func newTransactionMetricsMap() *transactionMetricsMap {
+	return &transactionMetricsMap{
+		m: make(map[uint64][]*transactionMetricsMapEntry),
+	}
+}
+
+type transactionMetricsMapEntry struct {
+	// counts must be the first field, to ensure it is 64-bit
+	// aligned for atomic operations.
+	counts [transactionDurationBuckets]uint64
+	transactionMetricsKey
+}
+
+// transactionMetricsKey identifies a transaction group for recording
+// transaction metrics.
+type transactionMetricsKey struct {
+	transactionType   string
+	transactionName   string
+	transactionResult string
+	outcome           string
+}
+
+func (k transactionMetricsKey) hash() uint64 {
+	h := newFnv1a()
+	h.add(k.transactionType)
+	h.add(k.transactionName)
+	h.add(k.transactionResult)
+	h.add(k.outcome)
+	return uint64(h)
+}
+
+// recordTransaction records metrics for td into m.
+//
+// recordTransaction returns true if the metrics were recorded,
+// and false if they were not recorded due to the limit being
+// reached.
+func (m *transactionMetrics) recordTransaction(td *TransactionData) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	k := transactionMetricsKey{
+		transactionType:   td.Type,
+		transactionName:   td.Name,
+		transactionResult: td.Result,
+		outcome:           td.outcome(),
+	}
+	return m.active.record(k, transactionDurationBucket(td.Duration))
+}
+
+// transactionDurationBucket returns the index of the histogram
+// bucket for a transaction with the given duration.
+func transactionDurationBucket(d time.Duration) int {
+	return sort.SearchFloat64s(transactionDurationBucketBounds[:], durationMicros(d))
+}
+
+// record increments the count of the given bucket for the
+// transaction group identified by k.
+func (m *transactionMetricsMap) record(k transactionMetricsKey, bucket int) bool {
+	hash := k.hash()
+	m.mu.RLock()
+	entries, ok := m.m[hash]
+	m.mu.RUnlock()
+	var offset int
+	if ok {
+		for offset = range entries {
+			if entries[offset].transactionMetricsKey == k {
+				atomic.AddUint64(&entries[offset].counts[bucket], 1)
+				return true
+			}
+		}
+		offset++ // where to start searching with the write lock below
+	}
+
+	m.mu.Lock()
+	entries, ok = m.m[hash]
+	if ok {
+		for i := range entries[offset:] {
+			if entries[offset+i].transactionMetricsKey == k {
+				m.mu.Unlock()
+				atomic.AddUint64(&entries[offset+i].counts[bucket], 1)
+				return true
+			}
+		}
+	}
+	if m.entries >= transactionMetricsLimit {
+		m.mu.Unlock()
+		return false
+	}
+	chunk := m.entries / transactionMetricsChunkSize
+	if chunk == len(m.chunks) {
+		m.chunks = append(m.chunks, make([]transactionMetricsMapEntry, transactionMetricsChunkSize))
+	}
+	entry := &m.chunks[chunk][m.entries%transactionMetricsChunkSize]
+	entry.transactionMetricsKey = k
+	entry.counts[bucket] = 1
+	m.m[hash] = append(entries, entry)
+	m.entries++
+	m.mu.Unlock()
+	return true
+}
+
+// gather is called by builtinMetricsGatherer to gather transaction metrics.
+func (m *transactionMetrics) gather(out *Metrics) {
+	// Hold m.mu only long enough to swap m.active and m.inactive.
+	// After swapping we do not need to hold m.mu, since nothing
+	// concurrently accesses m.inactive while the gatherer is
+	// iterating over it.
+	m.mu.Lock()
+	m.active, m.inactive = m.inactive, m.active
+	m.mu.Unlock()
+
+	for hash, entries := range m.inactive.m {
+		for _, entry := range entries {
+			metrics := entry.metrics()
+			for name := range metrics.Samples {
+				if out.disabled.MatchAny(name) {
+					delete(metrics.Samples, name)
+				}
+			}
+			if len(metrics.Samples) > 0 {
+				out.transactionGroupMetrics = append(out.transactionGroupMetrics, metrics)
+			}
+			*entry = transactionMetricsMapEntry{} // release strings, reset counts
+		}
+		delete(m.inactive.m, hash)
+	}
+	m.inactive.entries = 0
+}
+
+// metrics returns a model.Metrics holding the transaction count and
+// duration histogram, omitting any empty buckets.
+func (e *transactionMetricsMapEntry) metrics() *model.Metrics {
+	var n int
+	for _, count := range e.counts {
+		if count > 0 {
+			n++
+		}
+	}
+	var total uint64
+	values := make([]float64, 0, n)
+	counts := make([]uint64, 0, n)
+	for i, count := range e.counts {
+		if count > 0 {
+			values = append(values, transactionDurationBucketValues[i])
+			counts = append(counts, count)
+			total += count
+		}
+	}
+	return &model.Metrics{
+		Transaction: model.MetricsTransaction{
+			Type:    e.transactionType,
+			Name:    e.transactionName,
+			Result:  e.transactionResult,
+			Outcome: e.outcome,
+		},
+		Samples: map[string]model.Metric{
+			transactionCountMetricName: {
+				Value: float64(total),
+			},
+			transactionDurationHistogramMetricName: {
+				Type:   "histogram",
+				Values: values,
+				Counts: counts,
+			},
+		},
+	}
+}
@@ -0,0 +1,140 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package apm_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.elastic.co/apm"
+	"go.elastic.co/apm/apmtest"
+	"go.elastic.co/apm/model"
+	"go.elastic.co/apm/transport/transporttest"
+)
+
+func TestTransactionMetrics(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	// Transaction metrics are recorded regardless of sampling.
+	tracer.SetSampler(apm.NewRatioSampler(0))
+
+	for _, d := range []time.Duration{time.Millisecond, time.Millisecond, time.Second} {
+		tx := tracer.StartTransaction("GET /", "request")
+		tx.Result = "HTTP 2xx"
+		tx.Outcome = "success"
+		tx.Duration = d
+		tx.End()
+	}
+	tx := tracer.StartTransaction("GET /", "request")
+	tx.Result = "HTTP 5xx"
+	tx.Context.SetHTTPStatusCode(503) // outcome derived from status code
+	tx.Duration = time.Millisecond
+	tx.End()
+
+	tracer.Flush(nil)
+	tracer.SendMetrics(nil)
+
+	metrics := payloadsTransactionMetrics(transport)
+	require.Len(t, metrics, 2)
+	if metrics[0].Transaction.Result != "HTTP 2xx" {
+		metrics[0], metrics[1] = metrics[1], metrics[0]
+	}
+	assert.Equal(t, model.MetricsTransaction{
+		Type:    "request",
+		Name:    "GET /",
+		Result:  "HTTP 2xx",
+		Outcome: "success",
+	}, metrics[0].Transaction)
+	assert.Equal(t, model.MetricsTransaction{
+		Type:    "request",
+		Name:    "GET /",
+		Result:  "HTTP 5xx",
+		Outcome: "failure",
+	}, metrics[1].Transaction)
+
+	assert.Equal(t, model.Metric{Value: 3}, metrics[0].Samples["transaction.count"])
+	histogram := metrics[0].Samples["transaction.duration.histogram"]
+	assert.Equal(t, "histogram", histogram.Type)
+	assert.Equal(t, []uint64{2, 1}, histogram.Counts)
+	require.Len(t, histogram.Values, 2)
+	assert.InEpsilon(t, 1000, histogram.Values[0], 0.3)
+	assert.InEpsilon(t, 1000000, histogram.Values[1], 0.3)
+
+	assert.Equal(t, model.Metric{Value: 1}, metrics[1].Samples["transaction.count"])
+	assert.Equal(t, []uint64{1}, metrics[1].Samples["transaction.duration.histogram"].Counts)
+
+	// Metrics are reset after each interval.
+	transport.ResetPayloads()
+	tracer.SendMetrics(nil)
+	assert.Empty(t, payloadsTransactionMetrics(transport))
+}
+
+func TestTransactionMetricsLimit(t *testing.T) {
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	var logger apmtest.RecordLogger
+	tracer.SetLogger(&logger)
+
+	for i := 0; i < 3000; i++ {
+		tx := tracer.StartTransaction(fmt.Sprintf("%d", i), "request")
+		tx.End()
+	}
+	tracer.Flush(nil)
+	tracer.SendMetrics(nil)
+
+	var warnings []apmtest.LogRecord
+	for _, record := range logger.Records {
+		if record.Level == "warning" && strings.Contains(record.Message, "transaction metricsets") {
+			warnings = append(warnings, record)
+		}
+	}
+	require.Len(t, warnings, 1)
+	assert.Regexp(t, "The limit of 1000 transaction metricsets (.|\n)*", warnings[0].Message)
+	assert.Len(t, payloadsTransactionMetrics(transport), 1000)
+}
+
+func TestTransactionMetricsDisabled(t *testing.T) {
+	os.Setenv("ELASTIC_APM_DISABLE_METRICS", "transaction.*")
+	defer os.Unsetenv("ELASTIC_APM_DISABLE_METRICS")
+
+	tracer, transport := transporttest.NewRecorderTracer()
+	defer tracer.Close()
+
+	tracer.StartTransaction("name", "type").End()
+	tracer.Flush(nil)
+	tracer.SendMetrics(nil)
+	assert.Empty(t, payloadsTransactionMetrics(transport))
+}
+
+func payloadsTransactionMetrics(t *transporttest.RecorderTransport) []model.Metrics {
+	var ms []model.Metrics
+	for _, m := range t.Payloads().Metrics {
+		if _, ok := m.Samples["transaction.duration.histogram"]; ok {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
@@ -156,6 +156,56 @@ func TestTransactionContextNotSampled(t *testing.T) {
 	assert.Nil(t, payloads.Transactions[0].Context)
 }
 
+func TestTransactionFaaS(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	tx1 := tracer.StartTransaction("name", "type")
+	tx1.End()
+	tx2 := tracer.StartTransaction("name", "type")
+	tx2.FaaS = apm.FaaS{
+		Execution:        "execution-id",
+		Coldstart:        true,
+		TriggerType:      "http",
+		TriggerRequestID: "request-id",
+	}
+	tx2.End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 2)
+	assert.Nil(t, payloads.Transactions[0].FaaS)
+	assert.Equal(t, &model.FaaS{
+		Execution: "execution-id",
+		Coldstart: true,
+		Trigger: model.FaaSTrigger{
+			Type:      "http",
+			RequestID: "request-id",
+		},
+	}, payloads.Transactions[1].FaaS)
+}
+
+func TestTransactionLinks(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+
+	links := []apm.SpanLink{
+		{Trace: apm.TraceID{1}, Span: apm.SpanID{2}},
+		{Trace: apm.TraceID{3}, Span: apm.SpanID{4}},
+	}
+	tracer.StartTransactionOptions("name", "type", apm.TransactionOptions{Links: links}).End()
+	tracer.StartTransaction("name", "type").End()
+	tracer.Flush(nil)
+
+	payloads := tracer.Payloads()
+	require.Len(t, payloads.Transactions, 2)
+	assert.Equal(t, []model.SpanLink{
+		{TraceID: model.TraceID{1}, SpanID: model.SpanID{2}},
+		{TraceID: model.TraceID{3}, SpanID: model.SpanID{4}},
+	}, payloads.Transactions[0].Links)
+	assert.Nil(t, payloads.Transactions[1].Links)
+}
+
 func TestTransactionNotRecording(t *testing.T) {
 	tracer := apmtest.NewRecordingTracer()
 	defer tracer.Close()
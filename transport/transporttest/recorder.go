@@ -114,6 +114,7 @@ func (r *RecorderTransport) record(ctx context.Context, stream io.Reader) error
 	for {
 		var payload struct {
 			Error       *model.Error       `json:"error"`
+			Log         *model.LogEvent    `json:"log"`
 			Metrics     *model.Metrics     `json:"metricset"`
 			Span        *model.Span        `json:"span"`
 			Transaction *model.Transaction `json:"transaction"`
@@ -128,6 +129,8 @@ func (r *RecorderTransport) record(ctx context.Context, stream io.Reader) error
 		switch {
 		case payload.Error != nil:
 			r.payloads.Errors = append(r.payloads.Errors, *payload.Error)
+		case payload.Log != nil:
+			r.payloads.Logs = append(r.payloads.Logs, *payload.Log)
 		case payload.Metrics != nil:
 			r.payloads.Metrics = append(r.payloads.Metrics, *payload.Metrics)
 		case payload.Span != nil:
@@ -184,6 +187,7 @@ func contextDone(ctx context.Context) bool {
 // Payloads holds the recorded payloads.
 type Payloads struct {
 	Errors       []model.Error
+	Logs         []model.LogEvent
 	Metrics      []model.Metrics
 	Spans        []model.Span
 	Transactions []model.Transaction
@@ -192,7 +196,7 @@ type Payloads struct {
 
 // Len returns the number of recorded payloads.
 func (p *Payloads) Len() int {
-	return len(p.Transactions) + len(p.Errors) + len(p.Metrics)
+	return len(p.Transactions) + len(p.Errors) + len(p.Logs) + len(p.Metrics)
 }
 
 type metadata struct {